@@ -4,6 +4,7 @@ package args
 import (
 	"bytes"
 	"errors"
+	"math"
 	"strconv"
 	"strings"
 
@@ -58,6 +59,101 @@ func AsSimpleString(arg interface{}) string {
 	}
 }
 
+// AppendAsBytes appends the byte slice form of args to the dst slice. The
+// conversion of each argument matches Info.AppendTo, i.e. the
+// conversions performed by redigo when writing arguments to the connection.
+// Every appended byte slice is a new allocation. nil is appended for
+// arguments which cannot be converted.
+func AppendAsBytes(dst [][]byte, args []interface{}) [][]byte {
+	for _, arg := range args {
+		info := Parse(arg)
+
+		bytesArg, err := info.AppendTo(nil)
+		if err != nil {
+			bytesArg = nil
+		} else if bytesArg == nil {
+			bytesArg = []byte{}
+		}
+
+		dst = append(dst, bytesArg)
+	}
+
+	return dst
+}
+
+// Clone returns a copy of arg. String and byte slice arguments are copied
+// into new allocations, so that the returned value does not share memory with
+// arg. All other arguments are returned unchanged.
+func Clone(arg interface{}) interface{} {
+	switch typedArg := arg.(type) {
+	case string:
+		return string(append([]byte(nil), typedArg...))
+	case []byte:
+		if typedArg == nil {
+			return typedArg
+		}
+		return append(make([]byte, 0, len(typedArg)), typedArg...)
+	default:
+		return arg
+	}
+}
+
+// Equal reports whether a and b have the same Redis wire representation,
+// i.e. whether redigo writes the same string to the connection for both
+// arguments. For example, the string "5" and the int 5 are equal. Arguments
+// which cannot be converted are never equal.
+func Equal(a, b interface{}) bool {
+	aInfo := Parse(a)
+	bInfo := Parse(b)
+
+	var aArray, bArray [32]byte
+	aBytes, err := aInfo.AppendTo(aArray[:0])
+	if err != nil {
+		return false
+	}
+	bBytes, err := bInfo.AppendTo(bArray[:0])
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(aBytes, bBytes)
+}
+
+// Normalize converts arg to its canonical form. Arguments implementing
+// redis.Argument are unwrapped. Integers and booleans (0 or 1) become int64,
+// floats become float64. Unsigned integers not fitting into an int64 remain
+// uint64. Strings and byte slices are returned unchanged, as are all other
+// arguments.
+func Normalize(arg interface{}) interface{} {
+	info := Parse(arg)
+
+	switch info.Type {
+	case TypeString:
+		return info.StringValue()
+	case TypeBytes:
+		return info.BytesValue()
+	case TypeInt:
+		return info.Int64Value()
+	case TypeUint:
+		if value := info.Uint64Value(); value <= math.MaxInt64 {
+			return int64(value)
+		}
+		return info.Uint64Value()
+	case TypeFloat:
+		return info.Float64Value()
+	case TypeBool:
+		if info.BoolValue() {
+			return int64(1)
+		} else {
+			return int64(0)
+		}
+	case TypeNil:
+		return nil
+	default:
+		return info.UnwrappedArg
+	}
+}
+
 // Error variables related to Info and associated functions.
 var (
 	ErrInvalidTypeForOperation = errors.New("invalid type for the operation")
@@ -136,7 +232,7 @@ func parseRecursive(info *Info) {
 		info.Type = TypeNil
 	case redis.Argument:
 		info.WrappingLevel++
-		info.UnwrappedArg = arg
+		info.UnwrappedArg = arg.RedisArg()
 
 		parseRecursive(info)
 	}
@@ -213,6 +309,36 @@ func (i *Info) IsStringLike() bool {
 	return i.Type == TypeString || i.Type == TypeBytes
 }
 
+// Len returns the length of the argument described by i if i is a String or
+// Bytes argument. The returned bool is false for all other arguments. In
+// contrast to ConvertToRedisString, Len does not allocate.
+func (i *Info) Len() (int, bool) {
+	switch i.Type {
+	case TypeString:
+		return len(i.StringValue()), true
+	case TypeBytes:
+		return len(i.BytesValue()), true
+	default:
+		return 0, false
+	}
+}
+
+// IsNumeric returns true iff i describes either an Int, Uint or Float
+// argument.
+func (i *Info) IsNumeric() bool {
+	return i.Type == TypeInt || i.Type == TypeUint || i.Type == TypeFloat
+}
+
+// IsNil returns true iff i describes a Nil argument.
+func (i *Info) IsNil() bool {
+	return i.Type == TypeNil
+}
+
+// IsBoolean returns true iff i describes a Bool argument.
+func (i *Info) IsBoolean() bool {
+	return i.Type == TypeBool
+}
+
 // EqualEither checks string equality of i to either tString OR tBytes using
 // whichever matches the type of i.
 func (i *Info) EqualEither(tString string, tBytes []byte) bool {
@@ -300,10 +426,18 @@ func (i *Info) ConvertToRedisBytesString() ([]byte, error) {
 }
 
 // AppendRedisBytesString appends the argument described by i in the form of a
-// byte slice. Depending on the type of i, a conversion might be performed.
-// All conversions match the conversions performed by redigo when writing
-// arguments to the connection.
+// byte slice.
+//
+// Deprecated: Use AppendTo instead.
 func (i *Info) AppendRedisBytesString(buf []byte) ([]byte, error) {
+	return i.AppendTo(buf)
+}
+
+// AppendTo appends the argument described by i in its serialised form, i.e.
+// as written to the connection, to buf. Depending on the type of i, a
+// conversion might be performed. All conversions match the conversions
+// performed by redigo when writing arguments to the connection.
+func (i *Info) AppendTo(buf []byte) ([]byte, error) {
 	switch i.Type {
 	case TypeString:
 		return append(buf, i.StringValue()...), nil
@@ -387,3 +521,31 @@ func (i *Info) ConvertToUint() (uint64, error) {
 		return 0, ErrInvalidTypeForOperation
 	}
 }
+
+// ConvertToFloat returns the argument described by i in the form of a
+// float64. Depending on the type of i, a conversion might be performed. Any
+// error occuring during conversion is returned.
+func (i *Info) ConvertToFloat() (float64, error) {
+	switch i.Type {
+	case TypeString:
+		return strconv.ParseFloat(i.StringValue(), 64)
+	case TypeBytes:
+		return strconv.ParseFloat(string(i.BytesValue()), 64)
+	case TypeInt:
+		return float64(i.Int64Value()), nil
+	case TypeUint:
+		return float64(i.Uint64Value()), nil
+	case TypeFloat:
+		return i.Float64Value(), nil
+	case TypeBool:
+		if i.BoolValue() {
+			return 1, nil
+		} else {
+			return 0, nil
+		}
+	case TypeNil:
+		return 0, ErrInvalidTypeForOperation
+	default:
+		return 0, ErrInvalidTypeForOperation
+	}
+}
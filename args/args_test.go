@@ -0,0 +1,59 @@
+package args
+
+import (
+	"testing"
+)
+
+// argument is a redis.Argument wrapping arg.
+type argument struct {
+	arg interface{}
+}
+
+func (a argument) RedisArg() interface{} {
+	return a.arg
+}
+
+func TestInfoTypePredicates(t *testing.T) {
+	tests := []struct {
+		arg        interface{}
+		nil        bool
+		boolean    bool
+		stringLike bool
+		numeric    bool
+	}{
+		{arg: "value", stringLike: true},
+		{arg: []byte("value"), stringLike: true},
+		{arg: 1, numeric: true},
+		{arg: int64(-1), numeric: true},
+		{arg: uint8(1), numeric: true},
+		{arg: 1.5, numeric: true},
+		{arg: float32(1.5), numeric: true},
+		{arg: complex(1, 2)},
+		{arg: true, boolean: true},
+		{arg: false, boolean: true},
+		{arg: nil, nil: true},
+		{arg: struct{}{}},
+		{arg: argument{nil}, nil: true},
+		{arg: argument{true}, boolean: true},
+		{arg: argument{argument{false}}, boolean: true},
+		{arg: argument{"value"}, stringLike: true},
+		{arg: argument{1}, numeric: true},
+	}
+
+	for _, test := range tests {
+		info := Parse(test.arg)
+
+		if info.IsNil() != test.nil {
+			t.Errorf("Parse(%#v).IsNil() = %t, want %t", test.arg, info.IsNil(), test.nil)
+		}
+		if info.IsBoolean() != test.boolean {
+			t.Errorf("Parse(%#v).IsBoolean() = %t, want %t", test.arg, info.IsBoolean(), test.boolean)
+		}
+		if info.IsStringLike() != test.stringLike {
+			t.Errorf("Parse(%#v).IsStringLike() = %t, want %t", test.arg, info.IsStringLike(), test.stringLike)
+		}
+		if info.IsNumeric() != test.numeric {
+			t.Errorf("Parse(%#v).IsNumeric() = %t, want %t", test.arg, info.IsNumeric(), test.numeric)
+		}
+	}
+}
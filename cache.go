@@ -1,7 +1,9 @@
 package rewledis
 
 import (
+	"container/list"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -20,8 +22,11 @@ type cacheEntry struct {
 	RWMutex     sync.RWMutex
 	WrittenAt   time.Time
 	DoneLoading <-chan struct{}
-	State       CacheEntryState
-	Type        LedisType
+	// Version is set to the next value of the cache's version counter
+	// whenever State and Type are set, see nextVersion.
+	Version uint64
+	State   CacheEntryState
+	Type    LedisType
 }
 
 type CacheEntryData struct {
@@ -29,6 +34,7 @@ type CacheEntryData struct {
 	WrittenAt   time.Time
 	Key         string
 	DoneLoading <-chan struct{}
+	Version     uint64
 	State       CacheEntryState
 	Type        LedisType
 }
@@ -41,9 +47,20 @@ func (c *CacheEntryData) Refresh() {
 	c.entry.RWMutex.RUnlock()
 }
 
+// Age returns the time elapsed since the entry was last written.
+func (c *CacheEntryData) Age() time.Duration {
+	return time.Since(c.WrittenAt)
+}
+
+// IsStale returns true if the entry was last written more than maxAge ago.
+func (c *CacheEntryData) IsStale(maxAge time.Duration) bool {
+	return c.Age() > maxAge
+}
+
 func (c *CacheEntryData) copyFrom(entry *cacheEntry) {
 	c.WrittenAt = c.entry.WrittenAt
 	c.DoneLoading = c.entry.DoneLoading
+	c.Version = c.entry.Version
 	c.State = c.entry.State
 	c.Type = c.entry.Type
 }
@@ -52,6 +69,16 @@ type CacheEntrySetter struct {
 	entry       *cacheEntry
 	Key         string
 	doneLoading chan struct{}
+	// versions is the version counter of the cache, see nextVersion.
+	versions *uint64
+}
+
+// nextVersion increments the version counter versions and returns the new
+// value. Each cache maintains a single counter for all its entries. Versions
+// are therefore never repeated for a key, even if its entry is removed and
+// created again.
+func nextVersion(versions *uint64) uint64 {
+	return atomic.AddUint64(versions, 1)
 }
 
 // Set sets the Type field of an entry along with the State and WrittenAt
@@ -60,6 +87,9 @@ type CacheEntrySetter struct {
 // CacheEntryStateDeleted or CacheEntryStateError and indicates the success
 // state of the type resolution. If state is not CacheEntryStateExists the
 // keyType value is ignored.
+//
+// If the loading process has already been aborted because the loading
+// timeout of the cache expired, Set has no effect.
 func (c *CacheEntrySetter) Set(state CacheEntryState, keyType LedisType) {
 	if c.doneLoading == nil {
 		// Panic now. Otherwise close() on the channel would panic, this gives
@@ -77,26 +107,382 @@ func (c *CacheEntrySetter) Set(state CacheEntryState, keyType LedisType) {
 		panic("CacheEntryState: Set() called with invalid state parameter")
 	}
 
+	doneLoading := c.doneLoading
+	c.doneLoading = nil
+
 	c.entry.RWMutex.Lock()
 
+	if c.entry.DoneLoading != doneLoading {
+		// The loading process has been aborted and doneLoading closed.
+		c.entry.RWMutex.Unlock()
+		return
+	}
+
 	c.entry.WrittenAt = time.Now()
 	c.entry.DoneLoading = nil
+	c.entry.Version = nextVersion(c.versions)
 	c.entry.State = state
 	c.entry.Type = keyType
 
 	c.entry.RWMutex.Unlock()
 
-	close(c.doneLoading)
+	close(doneLoading)
+}
 
-	c.doneLoading = nil
+// abortLoadingAfter sets entry to the Error state if the loading process
+// signalled by doneLoading has not completed after timeout. This ensures
+// that waiters are not blocked indefinitely by a setter which is never set.
+func abortLoadingAfter(entry *cacheEntry, doneLoading chan struct{}, timeout time.Duration, versions *uint64) {
+	timer := time.NewTimer(timeout)
+
+	select {
+	case <-doneLoading:
+		timer.Stop()
+		return
+	case <-timer.C:
+	}
+
+	entry.RWMutex.Lock()
+
+	if entry.DoneLoading != doneLoading {
+		// Set has been called in the meantime.
+		entry.RWMutex.Unlock()
+		return
+	}
+
+	entry.WrittenAt = time.Now()
+	entry.DoneLoading = nil
+	entry.Version = nextVersion(versions)
+	entry.State = CacheEntryStateError
+	entry.Type = LedisTypeNone
+
+	entry.RWMutex.Unlock()
+
+	close(doneLoading)
 }
 
+// Cache stores type information about keys. Entries are scoped by the index
+// of the LedisDB database the key resides in.
+//
+// If MaxEntries is set, the number of entries is bounded. Once the cache
+// holds more than MaxEntries entries, the least recently used entry is
+// evicted. As the LRU list has to be kept consistent with the entries, all
+// operations on a bounded cache are serialised.
 type Cache struct {
+	// versions is the version counter of all entries, see nextVersion. It is
+	// the first field to guarantee 64-bit alignment for atomic operations.
+	versions uint64
+
+	// LoadingTimeout is the maximum duration an entry stays in the Loading
+	// state. If a CacheEntrySetter is not set within LoadingTimeout, the
+	// entry is set to the Error state. 0 means no timeout. LoadingTimeout
+	// must not be changed once the cache is in use.
+	LoadingTimeout time.Duration
+	// NegativeTTL is the duration for which entries in the Deleted state,
+	// i.e. keys found not to exist, are used. Within NegativeTTL, such keys
+	// are reported as not existing without resolving them again. Writes
+	// creating the key which do not update the cache may go unnoticed during
+	// this period. 0 disables negative caching, Deleted entries are always
+	// resolved again. NegativeTTL must not be changed once the cache is in
+	// use.
+	NegativeTTL time.Duration
+	// MaxEntries is the maximum number of entries stored across all
+	// databases. 0 means no limit. MaxEntries must not be changed once the
+	// cache is in use.
+	MaxEntries int
+
+	// dbCache maps database indices (int) to the entries of that database
+	// (*cacheEntries).
+	dbCache sync.Map
+
+	// lru contains the entries ordered by access time. Its mutex is held
+	// while entries are added to or removed from dbCache, so that the LRU
+	// list always reflects the entries stored. lru is only used if MaxEntries
+	// is set.
+	lru cacheLRU
+}
+
+// CacheStats contains statistics about the entries of a Cache, see
+// Cache.Stats.
+type CacheStats struct {
+	// Databases is the number of databases for which entries are stored.
+	Databases int
+	// Entries is the number of entries across all databases.
+	Entries int
+	// EntriesByState counts the entries in each CacheEntryState.
+	EntriesByState map[CacheEntryState]int
+}
+
+// lruKey identifies an entry of Cache.
+type lruKey struct {
+	DB  int
+	Key string
+}
+
+// cacheLRU orders the entries of a bounded cache by access time. The caller
+// is responsible for locking Mutex.
+type cacheLRU struct {
+	Mutex sync.Mutex
+	// list contains a lruKey for each entry. The most recently used entry is
+	// at the front.
+	list list.List
+	// elements maps lruKey values to their elements in list.
+	elements map[lruKey]*list.Element
+}
+
+// Touch marks the entry identified by entryKey as most recently used.
+func (l *cacheLRU) Touch(entryKey lruKey) {
+	if l.elements == nil {
+		l.elements = make(map[lruKey]*list.Element)
+	}
+
+	if element, ok := l.elements[entryKey]; ok {
+		l.list.MoveToFront(element)
+		return
+	}
+
+	l.elements[entryKey] = l.list.PushFront(entryKey)
+}
+
+// Forget removes the entry identified by entryKey.
+func (l *cacheLRU) Forget(entryKey lruKey) {
+	if element, ok := l.elements[entryKey]; ok {
+		l.list.Remove(element)
+		delete(l.elements, entryKey)
+	}
+}
+
+// Evict removes the least recently used entry, if more than maxEntries
+// entries are stored. The key of the removed entry is returned, the bool is
+// false if no entry was removed.
+func (l *cacheLRU) Evict(maxEntries int) (lruKey, bool) {
+	if l.list.Len() <= maxEntries {
+		return lruKey{}, false
+	}
+
+	entryKey := l.list.Remove(l.list.Back()).(lruKey)
+	delete(l.elements, entryKey)
+
+	return entryKey, true
+}
+
+// Len returns the number of entries stored.
+func (l *cacheLRU) Len() int {
+	return l.list.Len()
+}
+
+// Clear removes all entries.
+func (l *cacheLRU) Clear() {
+	l.list.Init()
+	l.elements = nil
+}
+
+// Clear removes all entries of all databases from the cache. Ongoing loading
+// procedures are not affected, their results are discarded.
+func (c *Cache) Clear() {
+	c.lockLRU()
+	defer c.unlockLRU()
+
+	c.dbCache.Range(func(db, _ interface{}) bool {
+		c.dbCache.Delete(db)
+		return true
+	})
+
+	if c.MaxEntries > 0 {
+		c.lru.Clear()
+	}
+}
+
+// lockLRU locks the LRU list, if the cache is bounded. It must be held while
+// calling touch or forget and while modifying the entries of a bounded cache.
+func (c *Cache) lockLRU() {
+	if c.MaxEntries > 0 {
+		c.lru.Mutex.Lock()
+	}
+}
+
+// unlockLRU unlocks the LRU list, if the cache is bounded.
+func (c *Cache) unlockLRU() {
+	if c.MaxEntries > 0 {
+		c.lru.Mutex.Unlock()
+	}
+}
+
+// touch marks the entry for key in database db as most recently used. If the
+// number of entries exceeds MaxEntries, the least recently used entries are
+// evicted. The caller must hold the LRU lock, see lockLRU.
+func (c *Cache) touch(db int, key string) {
+	if c.MaxEntries <= 0 {
+		return
+	}
+
+	c.lru.Touch(lruKey{DB: db, Key: key})
+
+	for {
+		evictedKey, ok := c.lru.Evict(c.MaxEntries)
+		if !ok {
+			break
+		}
+
+		c.entries(evictedKey.DB).Invalidate(evictedKey.Key)
+	}
+}
+
+// forget removes the entry for key in database db from the LRU list. The
+// caller must hold the LRU lock, see lockLRU.
+func (c *Cache) forget(db int, key string) {
+	if c.MaxEntries <= 0 {
+		return
+	}
+
+	c.lru.Forget(lruKey{DB: db, Key: key})
+}
+
+// entries returns the entries of database db.
+func (c *Cache) entries(db int) *cacheEntries {
+	entriesIntf, ok := c.dbCache.Load(db)
+	if !ok {
+		entriesIntf, _ = c.dbCache.LoadOrStore(db, &cacheEntries{
+			versions:       &c.versions,
+			loadingTimeout: c.LoadingTimeout,
+			negativeTTL:    c.NegativeTTL,
+		})
+	}
+
+	return entriesIntf.(*cacheEntries)
+}
+
+// LoadType returns the type of key in database db, if the entry of key is in
+// the Exists state.
+func (c *Cache) LoadType(db int, key string) (LedisType, bool) {
+	c.lockLRU()
+	defer c.unlockLRU()
+
+	keyType, ok := c.entries(db).LoadType(key)
+	if ok {
+		c.touch(db, key)
+	}
+
+	return keyType, ok
+}
+
+// TrySetEntry tries to update the entry for the given key in database db with given state
+// and keyType information. TrySetEntry does not wait for an ongoing loading
+// procedure and returns without setting in this case. TrySetEntry returns
+// true, if the entry was updated and false if not.
+func (c *Cache) TrySetEntry(db int, key string, state CacheEntryState, keyType LedisType) bool {
+	c.lockLRU()
+	defer c.unlockLRU()
+
+	ok := c.entries(db).TrySetEntry(key, state, keyType)
+	if ok {
+		c.touch(db, key)
+	}
+
+	return ok
+}
+
+// LoadVersion returns the current version of the entry for the given key in
+// database db. The version changes whenever the entry is set. Versions are
+// taken from a counter shared by all entries of the cache, so a version is
+// never reused, even after the entry has been invalidated or evicted. 0 is
+// returned if no entry exists for the key.
+func (c *Cache) LoadVersion(db int, key string) uint64 {
+	return c.entries(db).LoadVersion(key)
+}
+
+// SetIfVersion updates the entry for the given key in database db with given
+// state and keyType information only if the current version of the entry
+// equals version, see LoadVersion. This prevents overwriting more recent
+// information with stale information observed earlier. As TrySetEntry,
+// SetIfVersion does not wait for an ongoing loading procedure. SetIfVersion
+// returns true, if the entry was updated and false if not.
+func (c *Cache) SetIfVersion(db int, key string, state CacheEntryState, keyType LedisType, version uint64) bool {
+	c.lockLRU()
+	defer c.unlockLRU()
+
+	ok := c.entries(db).SetIfVersion(key, state, keyType, version)
+	if ok {
+		c.touch(db, key)
+	}
+
+	return ok
+}
+
+// LoadOrCreateEntry is the primary access method for the cache. For a given
+// key in database db, it returns either entry data or a setter. If a setter is returned, the
+// caller must fulfill the setter by calling .Set().
+//
+// The bool returned indicates whether the entry exists and is in the Loading
+// or Exists state or in the Deleted state and younger than NegativeTTL (this
+// means the returned CacheEntryData is valid). If the bool is false, the
+// returned CacheEntrySetter is valid.
+func (c *Cache) LoadOrCreateEntry(db int, key string) (CacheEntryData, CacheEntrySetter, bool) {
+	c.lockLRU()
+	defer c.unlockLRU()
+
+	entryData, entrySetter, exists := c.entries(db).LoadOrCreateEntry(key)
+	c.touch(db, key)
+
+	return entryData, entrySetter, exists
+}
+
+// Invalidate removes the entry for the given key in database db. An ongoing
+// loading procedure is not affected, its result is discarded.
+func (c *Cache) Invalidate(db int, key string) {
+	c.lockLRU()
+	defer c.unlockLRU()
+
+	c.entries(db).Invalidate(key)
+	c.forget(db, key)
+}
+
+// Stats collects statistics about the entries of the cache. The cache may be
+// modified concurrently, in this case the statistics are not exact.
+func (c *Cache) Stats() CacheStats {
+	stats := CacheStats{
+		EntriesByState: make(map[CacheEntryState]int),
+	}
+
+	c.dbCache.Range(func(_, entriesIntf interface{}) bool {
+		stats.Databases++
+		entriesIntf.(*cacheEntries).addStats(&stats)
+		return true
+	})
+
+	return stats
+}
+
+// Snapshot returns a copy of all entries of database db, including entries
+// in the Loading state. The snapshot is meant for debugging and inspecting
+// the cache's state. As entries are scoped by database, a snapshot covers a
+// single database.
+func (c *Cache) Snapshot(db int) map[string]CacheEntryData {
+	return c.entries(db).Snapshot()
+}
+
+// cacheEntries stores the entries of a single database (or a shard of a
+// database). It implements the operations of Cache for a single database.
+type cacheEntries struct {
 	entries sync.Map
+	// versions is the version counter of the cache, see nextVersion.
+	versions *uint64
+	// loadingTimeout is the LoadingTimeout of the Cache, see there.
+	loadingTimeout time.Duration
+	// negativeTTL is the NegativeTTL of the Cache, see there.
+	negativeTTL time.Duration
+}
+
+// watchLoading starts a background goroutine aborting the loading process
+// signalled by doneLoading after the loading timeout, if one is set.
+func (e *cacheEntries) watchLoading(entry *cacheEntry, doneLoading chan struct{}) {
+	if e.loadingTimeout > 0 {
+		go abortLoadingAfter(entry, doneLoading, e.loadingTimeout, e.versions)
+	}
 }
 
-func (c *Cache) LoadType(key string) (LedisType, bool) {
-	entry, ok := c.loadEntry(key)
+func (e *cacheEntries) LoadType(key string) (LedisType, bool) {
+	entry, ok := e.loadEntry(key)
 	if !ok {
 		return LedisTypeNone, false
 	}
@@ -115,11 +501,7 @@ func (c *Cache) LoadType(key string) (LedisType, bool) {
 	return keyType, true
 }
 
-// TrySetEntry tries to update the entry for the given key with given state
-// and keyType information. TrySetEntry does not wait for an ongoing loading
-// procedure and returns without setting in this case. TrySetEntry returns
-// true, if the entry was updated and false if not.
-func (c *Cache) TrySetEntry(key string, state CacheEntryState, keyType LedisType) bool {
+func (e *cacheEntries) TrySetEntry(key string, state CacheEntryState, keyType LedisType) bool {
 	switch state {
 	case CacheEntryStateExists:
 	case CacheEntryStateDeleted:
@@ -130,28 +512,29 @@ func (c *Cache) TrySetEntry(key string, state CacheEntryState, keyType LedisType
 		panic("Cache: TrySetEntry() called with invalid state parameter")
 	}
 
-	entry, ok := c.loadEntry(key)
+	entry, ok := e.loadEntry(key)
 	if ok {
-		return c.trySetEntry(entry, state, keyType)
+		return e.trySetEntry(entry, state, keyType)
 	}
 
 	entry = &cacheEntry{
 		DoneLoading: nil,
+		Version:     nextVersion(e.versions),
 		State:       state,
 		Type:        keyType,
 		WrittenAt:   time.Now(),
 	}
 
-	loadedEntryIntf, loaded := c.entries.LoadOrStore(key, entry)
+	loadedEntryIntf, loaded := e.entries.LoadOrStore(key, entry)
 	if loaded {
 		entry := loadedEntryIntf.(*cacheEntry)
-		return c.trySetEntry(entry, state, keyType)
+		return e.trySetEntry(entry, state, keyType)
 	}
 
 	return true
 }
 
-func (c *Cache) trySetEntry(entry *cacheEntry, state CacheEntryState, keyType LedisType) bool {
+func (e *cacheEntries) trySetEntry(entry *cacheEntry, state CacheEntryState, keyType LedisType) bool {
 	entry.RWMutex.Lock()
 
 	if entry.State == CacheEntryStateLoading {
@@ -160,6 +543,7 @@ func (c *Cache) trySetEntry(entry *cacheEntry, state CacheEntryState, keyType Le
 		return false
 	} else {
 		entry.DoneLoading = nil
+		entry.Version = nextVersion(e.versions)
 		entry.State = state
 		entry.Type = keyType
 		entry.WrittenAt = time.Now()
@@ -170,17 +554,73 @@ func (c *Cache) trySetEntry(entry *cacheEntry, state CacheEntryState, keyType Le
 	}
 }
 
-// LoadOrCreateEntry is the primary access method for the cache. For a given
-// key, it returns either entry data or a setter. If a setter is returned, the
-// caller must fulfill the setter by calling .Set().
-//
-// The bool returned indicates whether the entry exists and is in the Loading
-// or Exists state (this means the returned CacheEntryData is valid). If the
-// bool is false, the returned CacheEntrySetter is valid.
-func (c *Cache) LoadOrCreateEntry(key string) (CacheEntryData, CacheEntrySetter, bool) {
-	entry, ok := c.loadEntry(key)
+func (e *cacheEntries) LoadVersion(key string) uint64 {
+	entry, ok := e.loadEntry(key)
+	if !ok {
+		return 0
+	}
+
+	entry.RWMutex.RLock()
+
+	version := entry.Version
+
+	entry.RWMutex.RUnlock()
+
+	return version
+}
+
+func (e *cacheEntries) SetIfVersion(key string, state CacheEntryState, keyType LedisType, version uint64) bool {
+	switch state {
+	case CacheEntryStateExists:
+	case CacheEntryStateDeleted:
+		keyType = LedisTypeNone
+	case CacheEntryStateError:
+		keyType = LedisTypeNone
+	default:
+		panic("Cache: SetIfVersion() called with invalid state parameter")
+	}
+
+	entry, ok := e.loadEntry(key)
+	if !ok {
+		if version != 0 {
+			return false
+		}
+
+		entry = &cacheEntry{
+			DoneLoading: nil,
+			Version:     nextVersion(e.versions),
+			State:       state,
+			Type:        keyType,
+			WrittenAt:   time.Now(),
+		}
+
+		_, loaded := e.entries.LoadOrStore(key, entry)
+		return !loaded
+	}
+
+	entry.RWMutex.Lock()
+
+	if entry.State == CacheEntryStateLoading || entry.Version != version {
+		entry.RWMutex.Unlock()
+
+		return false
+	}
+
+	entry.DoneLoading = nil
+	entry.Version = nextVersion(e.versions)
+	entry.State = state
+	entry.Type = keyType
+	entry.WrittenAt = time.Now()
+
+	entry.RWMutex.Unlock()
+
+	return true
+}
+
+func (e *cacheEntries) LoadOrCreateEntry(key string) (CacheEntryData, CacheEntrySetter, bool) {
+	entry, ok := e.loadEntry(key)
 	if ok {
-		return c.prepareEntry(key, entry)
+		return e.prepareEntry(key, entry)
 	}
 
 	doneLoading := make(chan struct{})
@@ -190,25 +630,43 @@ func (c *Cache) LoadOrCreateEntry(key string) (CacheEntryData, CacheEntrySetter,
 		Type:        LedisTypeNone,
 	}
 
-	loadedEntryIntf, loaded := c.entries.LoadOrStore(key, entry)
+	loadedEntryIntf, loaded := e.entries.LoadOrStore(key, entry)
 	if loaded {
 		entry := loadedEntryIntf.(*cacheEntry)
-		return c.prepareEntry(key, entry)
+		return e.prepareEntry(key, entry)
 	}
 
+	e.watchLoading(entry, doneLoading)
+
 	return CacheEntryData{}, CacheEntrySetter{
 		entry:       entry,
 		Key:         key,
 		doneLoading: doneLoading,
+		versions:    e.versions,
 	}, false
 }
 
-func (c *Cache) prepareEntry(key string, entry *cacheEntry) (
+// isUsable returns true if entry can be returned by LoadOrCreateEntry
+// without starting a new loading process. This is the case for entries in
+// the Exists or Loading state and for entries in the Deleted state which are
+// younger than the negative TTL. entry must be locked by the caller.
+func (e *cacheEntries) isUsable(entry *cacheEntry) bool {
+	switch entry.State {
+	case CacheEntryStateExists, CacheEntryStateLoading:
+		return true
+	case CacheEntryStateDeleted:
+		return e.negativeTTL > 0 && time.Since(entry.WrittenAt) <= e.negativeTTL
+	default:
+		return false
+	}
+}
+
+func (e *cacheEntries) prepareEntry(key string, entry *cacheEntry) (
 	CacheEntryData, CacheEntrySetter, bool,
 ) {
 	entry.RWMutex.RLock()
 
-	if entry.State == CacheEntryStateExists || entry.State == CacheEntryStateLoading {
+	if e.isUsable(entry) {
 		entryData := CacheEntryData{
 			entry: entry,
 			Key:   key,
@@ -224,7 +682,7 @@ func (c *Cache) prepareEntry(key string, entry *cacheEntry) (
 		entry.RWMutex.Lock()
 
 		// Re-check the state
-		if entry.State == CacheEntryStateExists || entry.State == CacheEntryStateLoading {
+		if e.isUsable(entry) {
 			entryData := CacheEntryData{
 				entry: entry,
 				Key:   key,
@@ -243,17 +701,20 @@ func (c *Cache) prepareEntry(key string, entry *cacheEntry) (
 
 			entry.RWMutex.Unlock()
 
+			e.watchLoading(entry, doneLoading)
+
 			return CacheEntryData{}, CacheEntrySetter{
 				entry:       entry,
 				Key:         key,
 				doneLoading: doneLoading,
+				versions:    e.versions,
 			}, false
 		}
 	}
 }
 
-func (c *Cache) loadEntry(key string) (*cacheEntry, bool) {
-	entryIntf, ok := c.entries.Load(key)
+func (e *cacheEntries) loadEntry(key string) (*cacheEntry, bool) {
+	entryIntf, ok := e.entries.Load(key)
 	if !ok {
 		return nil, false
 	}
@@ -262,3 +723,43 @@ func (c *Cache) loadEntry(key string) (*cacheEntry, bool) {
 
 	return entry, true
 }
+
+func (e *cacheEntries) Invalidate(key string) {
+	e.entries.Delete(key)
+}
+
+func (e *cacheEntries) addStats(stats *CacheStats) {
+	e.entries.Range(func(_, entryIntf interface{}) bool {
+		entry := entryIntf.(*cacheEntry)
+
+		entry.RWMutex.RLock()
+		state := entry.State
+		entry.RWMutex.RUnlock()
+
+		stats.Entries++
+		stats.EntriesByState[state]++
+
+		return true
+	})
+}
+
+func (e *cacheEntries) Snapshot() map[string]CacheEntryData {
+	snapshot := make(map[string]CacheEntryData)
+
+	e.entries.Range(func(keyIntf, entryIntf interface{}) bool {
+		key := keyIntf.(string)
+		entry := entryIntf.(*cacheEntry)
+
+		entryData := CacheEntryData{
+			entry: entry,
+			Key:   key,
+		}
+		entryData.Refresh()
+
+		snapshot[key] = entryData
+
+		return true
+	})
+
+	return snapshot
+}
@@ -0,0 +1,75 @@
+package rewledis
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestCacheSetIfVersionAfterInvalidate(t *testing.T) {
+	cache := &Cache{}
+
+	cache.TrySetEntry(0, "key", CacheEntryStateExists, LedisTypeKV)
+	staleVersion := cache.LoadVersion(0, "key")
+
+	cache.Invalidate(0, "key")
+	cache.TrySetEntry(0, "key", CacheEntryStateExists, LedisTypeHash)
+
+	if version := cache.LoadVersion(0, "key"); version == staleVersion {
+		t.Fatalf("LoadVersion after re-creating the entry = %d, the version of the invalidated entry", version)
+	}
+
+	if cache.SetIfVersion(0, "key", CacheEntryStateDeleted, LedisTypeNone, staleVersion) {
+		t.Error("SetIfVersion with the version of an invalidated entry succeeded")
+	}
+	if keyType, ok := cache.LoadType(0, "key"); !ok || keyType != LedisTypeHash {
+		t.Errorf("LoadType = %v, %t, want %v, true", keyType, ok, LedisTypeHash)
+	}
+
+	version := cache.LoadVersion(0, "key")
+	if !cache.SetIfVersion(0, "key", CacheEntryStateDeleted, LedisTypeNone, version) {
+		t.Error("SetIfVersion with the current version failed")
+	}
+}
+
+func TestCacheLRUConsistentUnderConcurrency(t *testing.T) {
+	const maxEntries = 8
+
+	cache := &Cache{
+		MaxEntries: maxEntries,
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < 1000; j++ {
+				key := strconv.Itoa((i + j) % 32)
+
+				switch j % 3 {
+				case 0:
+					_, setter, exists := cache.LoadOrCreateEntry(0, key)
+					if !exists {
+						setter.Set(CacheEntryStateExists, LedisTypeKV)
+					}
+				case 1:
+					cache.TrySetEntry(0, key, CacheEntryStateExists, LedisTypeSet)
+				case 2:
+					cache.Invalidate(0, key)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	stats := cache.Stats()
+	if stats.Entries > maxEntries {
+		t.Errorf("cache holds %d entries, want at most %d", stats.Entries, maxEntries)
+	}
+	if stats.Entries != cache.lru.Len() || stats.Entries != len(cache.lru.elements) {
+		t.Errorf("cache holds %d entries, LRU list holds %d elements, LRU map %d",
+			stats.Entries, cache.lru.Len(), len(cache.lru.elements))
+	}
+}
@@ -0,0 +1,53 @@
+package rewledis
+
+// clusterSlotsCount is the number of hash slots of Redis Cluster.
+const clusterSlotsCount = 16384
+
+// crc16Table is the lookup table of the CRC16 variant (XMODEM, polynomial
+// 0x1021) used by Redis Cluster for computing hash slots.
+var crc16Table = func() (table [256]uint16) {
+	for i := range table {
+		crc := uint16(i) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return
+}()
+
+// crc16 computes the CRC16 (XMODEM) checksum of data.
+func crc16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc = crc<<8 ^ crc16Table[byte(crc>>8)^b]
+	}
+	return crc
+}
+
+// clusterKeySlot returns the hash slot of key in the same way as Redis
+// Cluster does. If key contains a hash tag, i.e. a non-empty substring
+// between the first "{" and the following "}", only the hash tag is hashed.
+func clusterKeySlot(key []byte) int {
+	for start := 0; start < len(key); start++ {
+		if key[start] != '{' {
+			continue
+		}
+
+		for end := start + 1; end < len(key); end++ {
+			if key[end] == '}' {
+				if end > start+1 {
+					key = key[start+1 : end]
+				}
+				break
+			}
+		}
+		break
+	}
+
+	return int(crc16(key)) & (clusterSlotsCount - 1)
+}
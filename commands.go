@@ -1,7 +1,7 @@
 package rewledis
 
 import (
-	"strings"
+	"sync"
 )
 
 // RedisCommand variables describing the Redis commands operating on
@@ -15,6 +15,7 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "APPEND key value",
+		Complexity:    "O(1)",
 	}
 
 	RedisCommandBITCOUNT = RedisCommand{
@@ -23,6 +24,8 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "BITCOUNT key [start end]",
+		Complexity:    "O(N)",
+		ReadOnly:      true,
 	}
 
 	// BITFIELD command is not implemented in LedisDB.
@@ -41,33 +44,38 @@ var (
 	RedisCommandBITOP = RedisCommand{
 		Name:          "BITOP",
 		KeyType:       RedisTypeString,
-		KeyExtractor:  ArgsFromIndex(1),
+		KeyExtractor:  BitopArgsExtractor,
 		TransformFunc: NoneTransformer(),
 		Syntax:        "BITOP operation destkey key [key ...]",
+		Complexity:    "O(N)",
 	}
 
 	RedisCommandBITPOS = RedisCommand{
 		Name:          "BITPOS",
 		KeyType:       RedisTypeString,
 		KeyExtractor:  ArgsAtIndices(0),
-		TransformFunc: NoneTransformer(),
-		Syntax:        "BITPOS key bit [start] [end]",
+		TransformFunc: BitposCommandTransformer,
+		Syntax:        "BITPOS key bit [start [end [BYTE|BIT]]]",
+		Complexity:    "O(N)",
+		ReadOnly:      true,
 	}
 
 	RedisCommandDECR = RedisCommand{
 		Name:          "DECR",
 		KeyType:       RedisTypeString,
 		KeyExtractor:  ArgsAtIndices(0),
-		TransformFunc: NoneTransformer(),
+		TransformFunc: WriteThroughTransformer(LedisTypeKV),
 		Syntax:        "DECR key",
+		Complexity:    "O(1)",
 	}
 
 	RedisCommandDECRBY = RedisCommand{
 		Name:          "DECRBY",
 		KeyType:       RedisTypeString,
 		KeyExtractor:  ArgsAtIndices(0),
-		TransformFunc: NoneTransformer(),
+		TransformFunc: WriteThroughTransformer(LedisTypeKV),
 		Syntax:        "DECRBY key decrement",
+		Complexity:    "O(1)",
 	}
 
 	RedisCommandGET = RedisCommand{
@@ -76,6 +84,8 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "GET key",
+		Complexity:    "O(1)",
+		ReadOnly:      true,
 	}
 
 	RedisCommandGETBIT = RedisCommand{
@@ -84,14 +94,19 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "GETBIT key offset",
+		Complexity:    "O(1)",
+		ReadOnly:      true,
 	}
 
+	// SUBSTR is a deprecated alias of GETRANGE, see redisCommandAliases.
 	RedisCommandGETRANGE = RedisCommand{
 		Name:          "GETRANGE",
 		KeyType:       RedisTypeString,
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "GETRANGE key start end",
+		Complexity:    "O(N)",
+		ReadOnly:      true,
 	}
 
 	RedisCommandGETSET = RedisCommand{
@@ -100,22 +115,25 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "GETSET key value",
+		Complexity:    "O(1)",
 	}
 
 	RedisCommandINCR = RedisCommand{
 		Name:          "INCR",
 		KeyType:       RedisTypeString,
 		KeyExtractor:  ArgsAtIndices(0),
-		TransformFunc: NoneTransformer(),
+		TransformFunc: WriteThroughTransformer(LedisTypeKV),
 		Syntax:        "INCR key",
+		Complexity:    "O(1)",
 	}
 
 	RedisCommandINCRBY = RedisCommand{
 		Name:          "INCRBY",
 		KeyType:       RedisTypeString,
 		KeyExtractor:  ArgsAtIndices(0),
-		TransformFunc: NoneTransformer(),
+		TransformFunc: WriteThroughTransformer(LedisTypeKV),
 		Syntax:        "INCRBY key increment",
+		Complexity:    "O(1)",
 	}
 
 	// INCRBYFLOAT command is not implemented in LedisDB.
@@ -126,6 +144,8 @@ var (
 		KeyExtractor:  ArgsFromIndex(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "MGET key [key ...]",
+		Complexity:    "O(N)",
+		ReadOnly:      true,
 	}
 
 	RedisCommandMSET = RedisCommand{
@@ -134,6 +154,7 @@ var (
 		KeyExtractor:  ArgsFromIndex(0, 1),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "MSET key value [key value ...]",
+		Complexity:    "O(N)",
 	}
 
 	// MSETNX command is not implemented in LedisDB.
@@ -146,6 +167,7 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: SetCommandTransformer,
 		Syntax:        "SET key value [expiration EX seconds|PX milliseconds] [NX|XX]",
+		Complexity:    "O(1)",
 	}
 
 	RedisCommandSETBIT = RedisCommand{
@@ -154,6 +176,7 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "SETBIT key offset value",
+		Complexity:    "O(1)",
 	}
 
 	RedisCommandSETEX = RedisCommand{
@@ -162,6 +185,7 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "SETEX key seconds value",
+		Complexity:    "O(1)",
 	}
 
 	RedisCommandSETNX = RedisCommand{
@@ -170,6 +194,7 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "SETNX key value",
+		Complexity:    "O(1)",
 	}
 
 	RedisCommandSETRANGE = RedisCommand{
@@ -178,6 +203,7 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "SETRANGE key offset value",
+		Complexity:    "O(1)",
 	}
 
 	RedisCommandSTRLEN = RedisCommand{
@@ -186,6 +212,8 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "STRLEN key",
+		Complexity:    "O(1)",
+		ReadOnly:      true,
 	}
 )
 
@@ -200,6 +228,7 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "HDEL key field [field ...]",
+		Complexity:    "O(N)",
 	}
 
 	RedisCommandHEXISTS = RedisCommand{
@@ -208,6 +237,8 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "HEXISTS key field",
+		Complexity:    "O(1)",
+		ReadOnly:      true,
 	}
 
 	RedisCommandHGET = RedisCommand{
@@ -216,6 +247,8 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "HGET key field",
+		Complexity:    "O(1)",
+		ReadOnly:      true,
 	}
 
 	RedisCommandHGETALL = RedisCommand{
@@ -224,6 +257,8 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "HGETALL key",
+		Complexity:    "O(N)",
+		ReadOnly:      true,
 	}
 
 	RedisCommandHINCRBY = RedisCommand{
@@ -232,6 +267,7 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "HINCRBY key field increment",
+		Complexity:    "O(1)",
 	}
 
 	// HINCRBYFLOAT command is not implemented in LedisDB.
@@ -242,6 +278,8 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "HKEYS key",
+		Complexity:    "O(N)",
+		ReadOnly:      true,
 	}
 
 	RedisCommandHLEN = RedisCommand{
@@ -250,6 +288,8 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "HLEN key",
+		Complexity:    "O(1)",
+		ReadOnly:      true,
 	}
 
 	RedisCommandHMGET = RedisCommand{
@@ -258,6 +298,8 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "HMGET key field [field ...]",
+		Complexity:    "O(N)",
+		ReadOnly:      true,
 	}
 
 	RedisCommandHMSET = RedisCommand{
@@ -266,6 +308,17 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "HMSET key field value [field value ...]",
+		Complexity:    "O(N)",
+	}
+
+	RedisCommandHRANDFIELD = RedisCommand{
+		Name:          "HRANDFIELD",
+		KeyType:       RedisTypeHash,
+		KeyExtractor:  ArgsAtIndices(0),
+		TransformFunc: HrandfieldCommandTransformer,
+		Syntax:        "HRANDFIELD key [count [WITHVALUES]]",
+		Complexity:    "O(N)",
+		ReadOnly:      true,
 	}
 
 	RedisCommandHSET = RedisCommand{
@@ -274,6 +327,7 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "HSET key field value",
+		Complexity:    "O(N)",
 	}
 
 	// HSETNX command is not implemented in LedisDB.
@@ -286,14 +340,18 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "HVALS key",
+		Complexity:    "O(N)",
+		ReadOnly:      true,
 	}
 
 	RedisCommandHSCAN = RedisCommand{
 		Name:          "HSCAN",
 		KeyType:       RedisTypeHash,
 		KeyExtractor:  ArgsAtIndices(0),
-		TransformFunc: NoneTransformer(),
+		TransformFunc: HscanCommandTransformer,
 		Syntax:        "HSCAN key cursor [MATCH pattern] [COUNT count]",
+		Complexity:    "O(1) per call, O(N) for a complete iteration",
+		ReadOnly:      true,
 	}
 )
 
@@ -302,28 +360,40 @@ var (
 //
 //     https://redis.io/commands#list
 var (
+	RedisCommandBLMPOP = RedisCommand{
+		Name:          "BLMPOP",
+		KeyType:       RedisTypeList,
+		KeyExtractor:  ArgsFromNumKeys(1),
+		TransformFunc: NoEmulationTransformer("use the non-blocking LMPOP instead"),
+		Syntax:        "BLMPOP timeout numkeys key [key ...] LEFT|RIGHT [COUNT count]",
+		Complexity:    "O(N+M)",
+	}
+
 	RedisCommandBLPOP = RedisCommand{
 		Name:          "BLPOP",
 		KeyType:       RedisTypeList,
 		KeyExtractor:  ArgsFromUntilIndex(0, -1),
-		TransformFunc: NoneTransformer(),
+		TransformFunc: BlpopCommandTransformer,
 		Syntax:        "BLPOP key [key ...] timeout",
+		Complexity:    "O(N)",
 	}
 
 	RedisCommandBRPOP = RedisCommand{
 		Name:          "BRPOP",
 		KeyType:       RedisTypeList,
 		KeyExtractor:  ArgsFromUntilIndex(0, -1),
-		TransformFunc: NoneTransformer(),
+		TransformFunc: BlpopCommandTransformer,
 		Syntax:        "BRPOP key [key ...] timeout",
+		Complexity:    "O(N)",
 	}
 
 	RedisCommandBRPOPLPUSH = RedisCommand{
 		Name:          "BRPOPLPUSH",
 		KeyType:       RedisTypeList,
 		KeyExtractor:  ArgsAtIndices(0, 1),
-		TransformFunc: NoneTransformer(),
+		TransformFunc: BrpoplpushCommandTransformer,
 		Syntax:        "BRPOPLPUSH source destination timeout",
+		Complexity:    "O(1)",
 	}
 
 	RedisCommandLINDEX = RedisCommand{
@@ -332,6 +402,8 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "LINDEX key index",
+		Complexity:    "O(N)",
+		ReadOnly:      true,
 	}
 
 	// LINSERT command is not implemented in LedisDB.
@@ -342,14 +414,26 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "LLEN key index",
+		Complexity:    "O(1)",
+		ReadOnly:      true,
+	}
+
+	RedisCommandLMPOP = RedisCommand{
+		Name:          "LMPOP",
+		KeyType:       RedisTypeList,
+		KeyExtractor:  ArgsFromNumKeys(0),
+		TransformFunc: LmpopCommandTransformer,
+		Syntax:        "LMPOP numkeys key [key ...] LEFT|RIGHT [COUNT count]",
+		Complexity:    "O(N+M)",
 	}
 
 	RedisCommandLPOP = RedisCommand{
 		Name:          "LPOP",
 		KeyType:       RedisTypeList,
 		KeyExtractor:  ArgsAtIndices(0),
-		TransformFunc: NoneTransformer(),
-		Syntax:        "LPOP key",
+		TransformFunc: LpopCommandTransformer,
+		Syntax:        "LPOP key [count]",
+		Complexity:    "O(N)",
 	}
 
 	RedisCommandLPUSH = RedisCommand{
@@ -358,6 +442,7 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "LPUSH key value [value ...]",
+		Complexity:    "O(N)",
 	}
 
 	RedisCommandLRANGE = RedisCommand{
@@ -366,6 +451,8 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "LRANGE key start stop",
+		Complexity:    "O(S+N)",
+		ReadOnly:      true,
 	}
 
 	RedisCommandLREM = RedisCommand{
@@ -374,6 +461,7 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: LremCommandTransformer,
 		Syntax:        "LREM key count value",
+		Complexity:    "O(N+M)",
 	}
 
 	// LPUSHX command is not implemented in LedisDB.
@@ -386,14 +474,16 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "LTRIM key start stop",
+		Complexity:    "O(N)",
 	}
 
 	RedisCommandRPOP = RedisCommand{
 		Name:          "RPOP",
 		KeyType:       RedisTypeList,
 		KeyExtractor:  ArgsAtIndices(0),
-		TransformFunc: NoneTransformer(),
-		Syntax:        "RPOP key",
+		TransformFunc: RpopCommandTransformer,
+		Syntax:        "RPOP key [count]",
+		Complexity:    "O(N)",
 	}
 
 	RedisCommandRPOPLPUSH = RedisCommand{
@@ -402,6 +492,7 @@ var (
 		KeyExtractor:  ArgsAtIndices(0, 1),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "RPOPLPUSH source destination",
+		Complexity:    "O(1)",
 	}
 
 	RedisCommandRPUSH = RedisCommand{
@@ -410,6 +501,7 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "RPUSH key value [value ...]",
+		Complexity:    "O(N)",
 	}
 
 	// RPUSHX command is not implemented in LedisDB.
@@ -426,6 +518,7 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "SADD key member [member ...]",
+		Complexity:    "O(N)",
 	}
 
 	RedisCommandSCARD = RedisCommand{
@@ -434,6 +527,8 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "SCARD key",
+		Complexity:    "O(1)",
+		ReadOnly:      true,
 	}
 
 	RedisCommandSDIFF = RedisCommand{
@@ -442,6 +537,8 @@ var (
 		KeyExtractor:  ArgsFromIndex(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "SDIFF key [key ...]",
+		Complexity:    "O(N)",
+		ReadOnly:      true,
 	}
 
 	RedisCommandSDIFFSTORE = RedisCommand{
@@ -450,6 +547,7 @@ var (
 		KeyExtractor:  ArgsFromIndex(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "SDIFFSTORE destination key [key ...]",
+		Complexity:    "O(N)",
 	}
 
 	RedisCommandSINTER = RedisCommand{
@@ -458,6 +556,18 @@ var (
 		KeyExtractor:  ArgsFromIndex(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "SINTER key [key ...]",
+		Complexity:    "O(N*M)",
+		ReadOnly:      true,
+	}
+
+	RedisCommandSINTERCARD = RedisCommand{
+		Name:          "SINTERCARD",
+		KeyType:       RedisTypeSet,
+		KeyExtractor:  ArgsFromNumKeys(0),
+		TransformFunc: SintercardCommandTransformer,
+		Syntax:        "SINTERCARD numkeys key [key ...] [LIMIT limit]",
+		Complexity:    "O(N*M)",
+		ReadOnly:      true,
 	}
 
 	RedisCommandSINTERSTORE = RedisCommand{
@@ -466,6 +576,7 @@ var (
 		KeyExtractor:  ArgsFromIndex(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "SINTERSTORE destination key [key ...]",
+		Complexity:    "O(N*M)",
 	}
 
 	RedisCommandSISMEMBER = RedisCommand{
@@ -474,6 +585,8 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "SISMEMBER key member",
+		Complexity:    "O(1)",
+		ReadOnly:      true,
 	}
 
 	RedisCommandSMEMBERS = RedisCommand{
@@ -482,6 +595,8 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "SMEMBERS key",
+		Complexity:    "O(N)",
+		ReadOnly:      true,
 	}
 
 	// SMOVE command is not implemented in LedisDB.
@@ -496,14 +611,17 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "SREM key member [member ...]",
+		Complexity:    "O(N)",
 	}
 
 	RedisCommandSSCAN = RedisCommand{
 		Name:          "SSCAN",
 		KeyType:       RedisTypeSet,
 		KeyExtractor:  ArgsAtIndices(0),
-		TransformFunc: NoneTransformer(),
+		TransformFunc: SscanCommandTransformer,
 		Syntax:        "SSCAN key cursor [MATCH pattern] [COUNT count]",
+		Complexity:    "O(1) per call, O(N) for a complete iteration",
+		ReadOnly:      true,
 	}
 
 	RedisCommandSUNION = RedisCommand{
@@ -512,6 +630,8 @@ var (
 		KeyExtractor:  ArgsFromIndex(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "SUNION key [key ...]",
+		Complexity:    "O(N)",
+		ReadOnly:      true,
 	}
 
 	RedisCommandSUNIONSTORE = RedisCommand{
@@ -520,6 +640,7 @@ var (
 		KeyExtractor:  ArgsFromIndex(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "SUNIONSTORE destination key [key ...]",
+		Complexity:    "O(N)",
 	}
 )
 
@@ -528,6 +649,15 @@ var (
 //
 //     https://redis.io/commands#sorted_set
 var (
+	RedisCommandBZMPOP = RedisCommand{
+		Name:          "BZMPOP",
+		KeyType:       RedisTypeZSet,
+		KeyExtractor:  ArgsFromNumKeys(1),
+		TransformFunc: NoEmulationTransformer("use the non-blocking ZMPOP instead"),
+		Syntax:        "BZMPOP timeout numkeys key [key ...] MIN|MAX [COUNT count]",
+		Complexity:    "O(K)+O(M*log(N))",
+	}
+
 	// BZPOPMIN command is not implemented in LedisDB.
 
 	// BZPOPMAX command is not implemented in LedisDB.
@@ -538,6 +668,7 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: ZaddCommandTransformer,
 		Syntax:        "ZADD key [NX|XX] [CH] [INCR] score member [score member ...]",
+		Complexity:    "O(log(N))",
 	}
 
 	RedisCommandZCARD = RedisCommand{
@@ -546,6 +677,8 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "ZCARD key",
+		Complexity:    "O(1)",
+		ReadOnly:      true,
 	}
 
 	RedisCommandZCOUNT = RedisCommand{
@@ -554,6 +687,8 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "ZCOUNT key min max",
+		Complexity:    "O(log(N))",
+		ReadOnly:      true,
 	}
 
 	RedisCommandZINCRBY = RedisCommand{
@@ -562,6 +697,7 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "ZINCRBY key increment member",
+		Complexity:    "O(log(N))",
 	}
 
 	// RedisCommandZINTERSTORE contains information about the ZINTERSTORE Redis
@@ -576,6 +712,7 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "ZINTERSTORE destination numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM|MIN|MAX]",
+		Complexity:    "O(N*K)+O(M*log(M))",
 	}
 
 	RedisCommandZLEXCOUNT = RedisCommand{
@@ -584,11 +721,38 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "ZLEXCOUNT key min max",
+		Complexity:    "O(log(N))",
+		ReadOnly:      true,
+	}
+
+	RedisCommandZMPOP = RedisCommand{
+		Name:          "ZMPOP",
+		KeyType:       RedisTypeZSet,
+		KeyExtractor:  ArgsFromNumKeys(0),
+		TransformFunc: ZmpopCommandTransformer,
+		Syntax:        "ZMPOP numkeys key [key ...] MIN|MAX [COUNT count]",
+		Complexity:    "O(K)+O(M*log(N))",
 	}
 
-	// ZPOPMAX command is not implemented in LedisDB.
+	// ZPOPMAX is not implemented in LedisDB, it is emulated using a lua script.
+	RedisCommandZPOPMAX = RedisCommand{
+		Name:          "ZPOPMAX",
+		KeyType:       RedisTypeZSet,
+		KeyExtractor:  ArgsAtIndices(0),
+		TransformFunc: ZpopmaxCommandTransformer,
+		Syntax:        "ZPOPMAX key [count]",
+		Complexity:    "O(log(N)*M)",
+	}
 
-	// ZPOPMIN command is not implemented in LedisDB.
+	// ZPOPMIN is not implemented in LedisDB, it is emulated using a lua script.
+	RedisCommandZPOPMIN = RedisCommand{
+		Name:          "ZPOPMIN",
+		KeyType:       RedisTypeZSet,
+		KeyExtractor:  ArgsAtIndices(0),
+		TransformFunc: ZpopminCommandTransformer,
+		Syntax:        "ZPOPMIN key [count]",
+		Complexity:    "O(log(N)*M)",
+	}
 
 	RedisCommandZRANGE = RedisCommand{
 		Name:          "ZRANGE",
@@ -596,14 +760,18 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "ZRANGE key start stop [WITHSCORES]",
+		Complexity:    "O(log(N)+M)",
+		ReadOnly:      true,
 	}
 
 	RedisCommandZRANGEBYLEX = RedisCommand{
 		Name:          "ZRANGEBYLEX",
 		KeyType:       RedisTypeZSet,
 		KeyExtractor:  ArgsAtIndices(0),
-		TransformFunc: NoneTransformer(),
+		TransformFunc: ZrangebylexCommandTransformer,
 		Syntax:        "ZRANGEBYLEX key min max [LIMIT offset count]",
+		Complexity:    "O(log(N)+M)",
+		ReadOnly:      true,
 	}
 
 	RedisCommandZRANGEBYSCORE = RedisCommand{
@@ -612,6 +780,8 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "ZRANGEBYSCORE key min max [WITHSCORES] [LIMIT offset count]",
+		Complexity:    "O(log(N)+M)",
+		ReadOnly:      true,
 	}
 
 	RedisCommandZRANK = RedisCommand{
@@ -620,6 +790,8 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "ZRANK key member",
+		Complexity:    "O(log(N))",
+		ReadOnly:      true,
 	}
 
 	RedisCommandZREM = RedisCommand{
@@ -628,6 +800,7 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "ZREM key member [member ...]",
+		Complexity:    "O(M*log(N))",
 	}
 
 	RedisCommandZREMRANGEBYLEX = RedisCommand{
@@ -636,6 +809,7 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "ZREMRANGEBYLEX key min max",
+		Complexity:    "O(log(N)+M)",
 	}
 
 	RedisCommandZREMRANGEBYRANK = RedisCommand{
@@ -644,6 +818,7 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "ZREMRANGEBYRANK key start stop",
+		Complexity:    "O(log(N)+M)",
 	}
 
 	RedisCommandZREMRANGEBYSCORE = RedisCommand{
@@ -652,6 +827,7 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "ZREMRANGEBYSCORE key min max",
+		Complexity:    "O(log(N)+M)",
 	}
 
 	RedisCommandZREVRANGE = RedisCommand{
@@ -660,9 +836,23 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "ZREVRANGE key start stop [WITHSCORES]",
+		Complexity:    "O(log(N)+M)",
+		ReadOnly:      true,
 	}
 
-	// ZREVRANGEBYLEX command is not implemented in LedisDB.
+	// RedisCommandZREVRANGEBYLEX contains information about the
+	// ZREVRANGEBYLEX Redis command.
+	// ZREVRANGEBYLEX is not implemented in LedisDB, it is emulated using
+	// ZRANGEBYLEX.
+	RedisCommandZREVRANGEBYLEX = RedisCommand{
+		Name:          "ZREVRANGEBYLEX",
+		KeyType:       RedisTypeZSet,
+		KeyExtractor:  ArgsAtIndices(0),
+		TransformFunc: ZrevrangebylexCommandTransformer,
+		Syntax:        "ZREVRANGEBYLEX key max min [LIMIT offset count]",
+		Complexity:    "O(log(N)+M)",
+		ReadOnly:      true,
+	}
 
 	RedisCommandZREVRANGEBYSCORE = RedisCommand{
 		Name:          "ZREVRANGEBYSCORE",
@@ -670,6 +860,8 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "ZREVRANGEBYSCORE key max min [WITHSCORES] [LIMIT offset count]",
+		Complexity:    "O(log(N)+M)",
+		ReadOnly:      true,
 	}
 
 	RedisCommandZREVRANK = RedisCommand{
@@ -678,14 +870,18 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "ZREVRANK key member",
+		Complexity:    "O(log(N))",
+		ReadOnly:      true,
 	}
 
 	RedisCommandZSCAN = RedisCommand{
 		Name:          "ZSCAN",
 		KeyType:       RedisTypeZSet,
 		KeyExtractor:  ArgsAtIndices(0),
-		TransformFunc: NoneTransformer(),
+		TransformFunc: ZscanCommandTransformer,
 		Syntax:        "ZSCAN key cursor [MATCH pattern] [COUNT count]",
+		Complexity:    "O(1) per call, O(N) for a complete iteration",
+		ReadOnly:      true,
 	}
 
 	RedisCommandZSCORE = RedisCommand{
@@ -694,6 +890,8 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "ZSCORE key member",
+		Complexity:    "O(1)",
+		ReadOnly:      true,
 	}
 
 	// RedisCommandZUNIONSTORE contains information about the ZUNIONSTORE Redis
@@ -708,6 +906,231 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "ZUNIONSTORE destination numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM|MIN|MAX]",
+		Complexity:    "O(N)+O(M*log(M))",
+	}
+)
+
+// RedisCommand variables describing the Redis commands operating on
+// geospatial indices. Geospatial indices are stored as sorted sets
+// (RedisTypeZSet).
+//
+//     https://redis.io/commands#geo
+var (
+	RedisCommandGEOADD = RedisCommand{
+		Name:          "GEOADD",
+		KeyType:       RedisTypeZSet,
+		KeyExtractor:  ArgsAtIndices(0),
+		TransformFunc: GeoaddCommandTransformer,
+		Syntax:        "GEOADD key longitude latitude member [longitude latitude member ...]",
+		Complexity:    "O(log(N))",
+	}
+
+	RedisCommandGEODIST = RedisCommand{
+		Name:          "GEODIST",
+		KeyType:       RedisTypeZSet,
+		KeyExtractor:  ArgsAtIndices(0),
+		TransformFunc: GeodistCommandTransformer,
+		Syntax:        "GEODIST key member1 member2 [m|km|ft|mi]",
+		Complexity:    "O(1)",
+		ReadOnly:      true,
+	}
+
+	// RedisCommandGEORADIUS contains information about the GEORADIUS Redis
+	// command.
+	// GEORADIUS is not implemented by all builds of LedisDB. The command is
+	// emulated by default, see GeoRadiusCommandTransformer.
+	RedisCommandGEORADIUS = RedisCommand{
+		Name:          "GEORADIUS",
+		KeyType:       RedisTypeZSet,
+		KeyExtractor:  ArgsAtIndices(0),
+		TransformFunc: GeoRadiusCommandTransformer,
+		Syntax:        "GEORADIUS key longitude latitude radius m|km|ft|mi [WITHCOORD] [WITHDIST] [WITHHASH] [COUNT count [ANY]] [ASC|DESC] [STORE key] [STOREDIST key]",
+		Complexity:    "O(N) (emulated by scanning the sorted set)",
+	}
+)
+
+// RedisCommand variables describing the Redis commands operating on
+// HyperLogLogs. HyperLogLogs are not implemented in LedisDB, they are emulated
+// using sets (RedisTypeSet). The emulation counts exactly instead of
+// probabilistically, see PfaddCommandTransformer.
+//
+//     https://redis.io/commands#hyperloglog
+var (
+	RedisCommandPFADD = RedisCommand{
+		Name:          "PFADD",
+		KeyType:       RedisTypeSet,
+		KeyExtractor:  ArgsAtIndices(0),
+		TransformFunc: PfaddCommandTransformer,
+		Syntax:        "PFADD key [element [element ...]]",
+		Complexity:    "O(N) (emulated using SADD)",
+	}
+
+	RedisCommandPFCOUNT = RedisCommand{
+		Name:          "PFCOUNT",
+		KeyType:       RedisTypeSet,
+		KeyExtractor:  ArgsFromIndex(0),
+		TransformFunc: PfcountCommandTransformer,
+		Syntax:        "PFCOUNT key [key ...]",
+		Complexity:    "O(1), O(N) for multiple keys (emulated using SCARD and SUNION)",
+		ReadOnly:      true,
+	}
+
+	RedisCommandPFMERGE = RedisCommand{
+		Name:          "PFMERGE",
+		KeyType:       RedisTypeSet,
+		KeyExtractor:  ArgsFromIndex(0),
+		TransformFunc: PfmergeCommandTransformer,
+		Syntax:        "PFMERGE destkey sourcekey [sourcekey ...]",
+		Complexity:    "O(N) (emulated using SUNIONSTORE)",
+	}
+)
+
+// RedisCommand variables describing the Redis commands operating on streams.
+// Streams are not implemented in LedisDB. XADD and XLEN are emulated using
+// sorted sets, see XaddCommandTransformer. All other commands are registered
+// in order to return a descriptive error, see StreamNotSupportedTransformer.
+//
+//     https://redis.io/commands#stream
+var (
+	RedisCommandXACK = RedisCommand{
+		Name:          "XACK",
+		KeyType:       RedisTypeGeneric,
+		KeyExtractor:  ArgsAtIndices(0),
+		TransformFunc: StreamNotSupportedTransformer,
+		Syntax:        "XACK key group ID [ID ...]",
+		Complexity:    "O(N)",
+	}
+
+	RedisCommandXADD = RedisCommand{
+		Name:          "XADD",
+		KeyType:       RedisTypeZSet,
+		KeyExtractor:  ArgsAtIndices(0),
+		TransformFunc: XaddCommandTransformer,
+		Syntax:        "XADD key [NOMKSTREAM] [MAXLEN|MINID [=|~] threshold [LIMIT count]] *|ID field value [field value ...]",
+		Complexity:    "O(log(N)) (emulated using ZADD)",
+	}
+
+	RedisCommandXAUTOCLAIM = RedisCommand{
+		Name:          "XAUTOCLAIM",
+		KeyType:       RedisTypeGeneric,
+		KeyExtractor:  ArgsAtIndices(0),
+		TransformFunc: StreamNotSupportedTransformer,
+		Syntax:        "XAUTOCLAIM key group consumer min-idle-time start [COUNT count] [JUSTID]",
+		Complexity:    "O(1)",
+	}
+
+	RedisCommandXCLAIM = RedisCommand{
+		Name:          "XCLAIM",
+		KeyType:       RedisTypeGeneric,
+		KeyExtractor:  ArgsAtIndices(0),
+		TransformFunc: StreamNotSupportedTransformer,
+		Syntax:        "XCLAIM key group consumer min-idle-time ID [ID ...] [IDLE ms] [TIME unix-time-milliseconds] [RETRYCOUNT count] [FORCE] [JUSTID]",
+		Complexity:    "O(log(N))",
+	}
+
+	RedisCommandXDEL = RedisCommand{
+		Name:          "XDEL",
+		KeyType:       RedisTypeGeneric,
+		KeyExtractor:  ArgsAtIndices(0),
+		TransformFunc: StreamNotSupportedTransformer,
+		Syntax:        "XDEL key ID [ID ...]",
+		Complexity:    "O(N)",
+	}
+
+	RedisCommandXGROUP = RedisCommand{
+		Name:          "XGROUP",
+		KeyType:       RedisTypeGeneric,
+		KeyExtractor:  ArgsAtIndices(),
+		TransformFunc: StreamNotSupportedTransformer,
+		Syntax:        "XGROUP subcommand [arg ...]",
+		Complexity:    "O(1)",
+	}
+
+	RedisCommandXINFO = RedisCommand{
+		Name:          "XINFO",
+		KeyType:       RedisTypeGeneric,
+		KeyExtractor:  ArgsAtIndices(),
+		TransformFunc: StreamNotSupportedTransformer,
+		Syntax:        "XINFO subcommand [arg ...]",
+		Complexity:    "O(N)",
+		ReadOnly:      true,
+	}
+
+	RedisCommandXLEN = RedisCommand{
+		Name:          "XLEN",
+		KeyType:       RedisTypeZSet,
+		KeyExtractor:  ArgsAtIndices(0),
+		TransformFunc: XlenCommandTransformer,
+		Syntax:        "XLEN key",
+		Complexity:    "O(1)",
+		ReadOnly:      true,
+	}
+
+	RedisCommandXPENDING = RedisCommand{
+		Name:          "XPENDING",
+		KeyType:       RedisTypeGeneric,
+		KeyExtractor:  ArgsAtIndices(0),
+		TransformFunc: StreamNotSupportedTransformer,
+		Syntax:        "XPENDING key group [[IDLE min-idle-time] start end count [consumer]]",
+		Complexity:    "O(N)",
+		ReadOnly:      true,
+	}
+
+	RedisCommandXRANGE = RedisCommand{
+		Name:          "XRANGE",
+		KeyType:       RedisTypeGeneric,
+		KeyExtractor:  ArgsAtIndices(0),
+		TransformFunc: StreamNotSupportedTransformer,
+		Syntax:        "XRANGE key start end [COUNT count]",
+		Complexity:    "O(N)",
+		ReadOnly:      true,
+	}
+
+	RedisCommandXREAD = RedisCommand{
+		Name:          "XREAD",
+		KeyType:       RedisTypeGeneric,
+		KeyExtractor:  ArgsAtIndices(),
+		TransformFunc: StreamNotSupportedTransformer,
+		Syntax:        "XREAD [COUNT count] [BLOCK milliseconds] STREAMS key [key ...] ID [ID ...]",
+		Complexity:    "O(N)",
+		ReadOnly:      true,
+	}
+
+	RedisCommandXREADGROUP = RedisCommand{
+		Name:          "XREADGROUP",
+		KeyType:       RedisTypeGeneric,
+		KeyExtractor:  ArgsAtIndices(),
+		TransformFunc: StreamNotSupportedTransformer,
+		Syntax:        "XREADGROUP GROUP group consumer [COUNT count] [BLOCK milliseconds] [NOACK] STREAMS key [key ...] ID [ID ...]",
+		Complexity:    "O(M)",
+	}
+
+	RedisCommandXREVRANGE = RedisCommand{
+		Name:          "XREVRANGE",
+		KeyType:       RedisTypeGeneric,
+		KeyExtractor:  ArgsAtIndices(0),
+		TransformFunc: StreamNotSupportedTransformer,
+		Syntax:        "XREVRANGE key end start [COUNT count]",
+		Complexity:    "O(N)",
+		ReadOnly:      true,
+	}
+
+	RedisCommandXSETID = RedisCommand{
+		Name:          "XSETID",
+		KeyType:       RedisTypeGeneric,
+		KeyExtractor:  ArgsAtIndices(0),
+		TransformFunc: StreamNotSupportedTransformer,
+		Syntax:        "XSETID key last-id [ENTRIESADDED entries-added] [MAXDELETEDID max-deleted-id]",
+		Complexity:    "O(1)",
+	}
+
+	RedisCommandXTRIM = RedisCommand{
+		Name:          "XTRIM",
+		KeyType:       RedisTypeGeneric,
+		KeyExtractor:  ArgsAtIndices(0),
+		TransformFunc: StreamNotSupportedTransformer,
+		Syntax:        "XTRIM key MAXLEN|MINID [=|~] threshold [LIMIT count]",
+		Complexity:    "O(N)",
 	}
 )
 
@@ -730,7 +1153,8 @@ var (
 			},
 			Aggregation: AggregationSum,
 		}),
-		Syntax: "DEL key [key ...]",
+		Syntax:     "DEL key [key ...]",
+		Complexity: "O(N)",
 	}
 
 	RedisCommandDUMP = RedisCommand{
@@ -747,7 +1171,9 @@ var (
 			},
 			Aggregation: AggregationFirst,
 		}),
-		Syntax: "DUMP key",
+		Syntax:     "DUMP key",
+		Complexity: "O(1)+O(N*M)",
+		ReadOnly:   true,
 	}
 
 	RedisCommandEXISTS = RedisCommand{
@@ -765,14 +1191,16 @@ var (
 			Debulk:      true,
 			Aggregation: AggregationSum,
 		}),
-		Syntax: "EXISTS key [key ...]",
+		Syntax:     "EXISTS key [key ...]",
+		Complexity: "O(N)",
+		ReadOnly:   true,
 	}
 
 	RedisCommandEXPIRE = RedisCommand{
 		Name:         "EXPIRE",
 		KeyType:      RedisTypeGeneric,
 		KeyExtractor: ArgsAtIndices(0),
-		TransformFunc: TypeSpecificBulkTransformer(&TypeSpecificBulkTransformerConfig{
+		TransformFunc: ExpireTransformer(&TypeSpecificBulkTransformerConfig{
 			Commands: TypeSpecificCommands{
 				KV:   "EXPIRE",
 				List: "LEXPIRE",
@@ -783,14 +1211,15 @@ var (
 			Aggregation:         AggregationSum,
 			AppendArgsExtractor: ArgsAtIndices(1),
 		}),
-		Syntax: "EXPIRE key seconds",
+		Syntax:     "EXPIRE key seconds",
+		Complexity: "O(1)",
 	}
 
 	RedisCommandEXPIREAT = RedisCommand{
 		Name:         "EXPIREAT",
 		KeyType:      RedisTypeGeneric,
 		KeyExtractor: ArgsAtIndices(0),
-		TransformFunc: TypeSpecificBulkTransformer(&TypeSpecificBulkTransformerConfig{
+		TransformFunc: ExpireatTransformer(&TypeSpecificBulkTransformerConfig{
 			Commands: TypeSpecificCommands{
 				KV:   "EXPIREAT",
 				List: "LEXPIREAT",
@@ -801,32 +1230,46 @@ var (
 			Aggregation:         AggregationSum,
 			AppendArgsExtractor: ArgsAtIndices(1),
 		}),
-		Syntax: "EXPIREAT key timestamp",
+		Syntax:     "EXPIREAT key timestamp",
+		Complexity: "O(1)",
 	}
 
 	// KEYS command is not implemented in LedisDB.
 
 	// MIGRATE command is not implemented in LedisDB.
 
-	// MOVE command is not implemented in LedisDB.
+	// RedisCommandMOVE contains information about the MOVE Redis command.
+	// MOVE is not implemented in LedisDB, the command is emulated by rewledis
+	// using DUMP and RESTORE.
+	RedisCommandMOVE = RedisCommand{
+		Name:          "MOVE",
+		KeyType:       RedisTypeGeneric,
+		KeyExtractor:  ArgsAtIndices(0),
+		TransformFunc: MoveCommandTransformer,
+		Syntax:        "MOVE key db",
+		Complexity:    "O(N) (emulated using DUMP and RESTORE)",
+	}
 
-	// OBJECT command is not implemented in LedisDB.
+	// RedisCommandOBJECT contains information about the OBJECT Redis command.
+	// OBJECT is not implemented in LedisDB, the sub-commands are emulated by
+	// rewledis.
+	RedisCommandOBJECT = RedisCommand{
+		Name:          "OBJECT",
+		KeyType:       RedisTypeGeneric,
+		KeyExtractor:  ArgsFromIndex(1),
+		TransformFunc: ObjectCommandTransformer,
+		Syntax:        "OBJECT subcommand [arguments [arguments ...]]",
+		Complexity:    "O(1), O(N) for ENCODING of sets",
+		ReadOnly:      true,
+	}
 
 	RedisCommandPERSIST = RedisCommand{
-		Name:         "PERSIST",
-		KeyType:      RedisTypeGeneric,
-		KeyExtractor: ArgsAtIndices(0),
-		TransformFunc: TypeSpecificBulkTransformer(&TypeSpecificBulkTransformerConfig{
-			Commands: TypeSpecificCommands{
-				KV:   "PERSIST",
-				List: "LPERSIST",
-				Hash: "HPERSIST",
-				Set:  "SPERSIST",
-				ZSet: "ZPERSIST",
-			},
-			Aggregation: AggregationSum,
-		}),
-		Syntax: "PERSIST key",
+		Name:          "PERSIST",
+		KeyType:       RedisTypeGeneric,
+		KeyExtractor:  ArgsAtIndices(0),
+		TransformFunc: PersistCommandTransformer,
+		Syntax:        "PERSIST key",
+		Complexity:    "O(1)",
 	}
 
 	// PEXPIRE command is not implemented in LedisDB.
@@ -847,21 +1290,16 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: RestoreCommandTransformer,
 		Syntax:        "RESTORE key ttl serialized-value [REPLACE] [ABSTTL] [IDLETIME seconds] [FREQ frequency]",
+		Complexity:    "O(1)+O(N*M)",
 	}
 
 	RedisCommandSORT = RedisCommand{
-		Name:         "SORT",
-		KeyType:      RedisTypeGeneric,
-		KeyExtractor: ArgsAtIndices(0),
-		TransformFunc: TypeSpecificBulkTransformer(&TypeSpecificBulkTransformerConfig{
-			Commands: TypeSpecificCommands{
-				List: "XLSORT",
-				Set:  "XSSORT",
-				ZSet: "XZSORT",
-			},
-			Aggregation: AggregationFirst,
-		}),
-		Syntax: "SORT key [BY pattern] [LIMIT offset count] [GET pattern [GET pattern ...]] [ASC|DESC] [ALPHA] [STORE destination]",
+		Name:          "SORT",
+		KeyType:       RedisTypeGeneric,
+		KeyExtractor:  ArgsAtIndices(0),
+		TransformFunc: SortCommandTransformer,
+		Syntax:        "SORT key [BY pattern] [LIMIT offset count] [GET pattern [GET pattern ...]] [ASC|DESC] [ALPHA] [STORE destination]",
+		Complexity:    "O(N+M*log(M))",
 	}
 
 	// TOUCH command is not implemented in LedisDB.
@@ -880,7 +1318,9 @@ var (
 			},
 			Aggregation: AggregationSum,
 		}),
-		Syntax: "TTL key",
+		Syntax:     "TTL key",
+		Complexity: "O(1)",
+		ReadOnly:   true,
 	}
 
 	// TYPE command is not implemented in LedisDB.
@@ -889,11 +1329,20 @@ var (
 
 	// WAIT command is not implemented in LedisDB.
 
-	// SCAN command is not implemented in LedisDB.
-	// However XSCAN is available. By adding an additional caching structure
-	// to Rewriter, SCAN could be emulated in most circumstances. XSCAN
+	// RedisCommandSCAN contains information about the SCAN Redis command.
+	// SCAN is not implemented in LedisDB. However XSCAN is available. XSCAN
 	// requires specifying the type of the keyspace to be scanned in addition
-	// to the parameters required by Redis.
+	// to the parameters required by Redis. SCAN is emulated by scanning all
+	// keyspaces one after another, see ScanCursorState.
+	RedisCommandSCAN = RedisCommand{
+		Name:          "SCAN",
+		KeyType:       RedisTypeGeneric,
+		KeyExtractor:  ArgsAtIndices(),
+		TransformFunc: ScanCommandTransformer,
+		Syntax:        "SCAN cursor [MATCH pattern] [COUNT count]",
+		Complexity:    "O(1) per call, O(N) for a complete iteration",
+		ReadOnly:      true,
+	}
 )
 
 // RedisCommand variables describing the Redis commands for performing
@@ -907,6 +1356,7 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: TransactionTransformer,
 		Syntax:        "DISCARD",
+		Complexity:    "O(N)",
 	}
 
 	RedisCommandEXEC = RedisCommand{
@@ -915,6 +1365,7 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: TransactionTransformer,
 		Syntax:        "EXEC",
+		Complexity:    "Depends on commands in the transaction",
 	}
 
 	RedisCommandMULTI = RedisCommand{
@@ -923,6 +1374,7 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: TransactionTransformer,
 		Syntax:        "MULTI",
+		Complexity:    "O(1)",
 	}
 
 	RedisCommandUNWATCH = RedisCommand{
@@ -931,6 +1383,7 @@ var (
 		KeyExtractor:  ArgsAtIndices(0),
 		TransformFunc: TransactionTransformer,
 		Syntax:        "UNWATCH",
+		Complexity:    "O(1)",
 	}
 
 	RedisCommandWATCH = RedisCommand{
@@ -939,6 +1392,7 @@ var (
 		KeyExtractor:  ArgsFromIndex(0),
 		TransformFunc: TransactionTransformer,
 		Syntax:        "WATCH key [key ...]",
+		Complexity:    "O(N)",
 	}
 )
 
@@ -1001,6 +1455,63 @@ var (
 // SLAVEOF host port [RESTART] [READONLY]
 // SYNC logid
 // TIME
+
+	RedisCommandCLIENT = RedisCommand{
+		Name:          "CLIENT",
+		KeyType:       RedisTypeGeneric,
+		KeyExtractor:  ArgsAtIndices(),
+		TransformFunc: ClientCommandTransformer,
+		Syntax:        "CLIENT subcommand [arg ...]",
+		Complexity:    "Depends on subcommand",
+	}
+
+	RedisCommandCOMMAND = RedisCommand{
+		Name:          "COMMAND",
+		KeyType:       RedisTypeGeneric,
+		KeyExtractor:  ArgsAtIndices(),
+		TransformFunc: CommandCommandTransformer,
+		Syntax:        "COMMAND INFO command-name [command-name ...]",
+		Complexity:    "O(N)",
+	}
+
+	RedisCommandDEBUG = RedisCommand{
+		Name:          "DEBUG",
+		KeyType:       RedisTypeGeneric,
+		KeyExtractor:  ArgsAtIndices(),
+		TransformFunc: DebugCommandTransformer,
+		Syntax:        "DEBUG subcommand [arg ...]",
+		Complexity:    "Depends on subcommand",
+	}
+
+	RedisCommandFAILOVER = RedisCommand{
+		Name:          "FAILOVER",
+		KeyType:       RedisTypeGeneric,
+		KeyExtractor:  ArgsAtIndices(),
+		TransformFunc: FailoverCommandTransformer,
+		Syntax:        "FAILOVER [TO host port [FORCE]] [ABORT] [TIMEOUT milliseconds]",
+		Complexity:    "O(1)",
+	}
+
+	RedisCommandINFO = RedisCommand{
+		Name:          "INFO",
+		KeyType:       RedisTypeGeneric,
+		KeyExtractor:  ArgsAtIndices(),
+		TransformFunc: InfoCommandTransformer,
+		Syntax:        "INFO [section [section ...]]",
+		Complexity:    "O(1)",
+	}
+
+	// RedisCommandLOLWUT contains information about the LOLWUT Redis command.
+	// LOLWUT is not implemented in LedisDB, the command is emulated by
+	// rewledis.
+	RedisCommandLOLWUT = RedisCommand{
+		Name:          "LOLWUT",
+		KeyType:       RedisTypeGeneric,
+		KeyExtractor:  ArgsAtIndices(),
+		TransformFunc: LolwutCommandTransformer,
+		Syntax:        "LOLWUT [VERSION version]",
+		Complexity:    "O(1)",
+	}
 )
 
 // RedisCommand variables describing the Redis commands for managing the
@@ -1014,6 +1525,7 @@ var (
 		KeyExtractor:  ArgsAtIndices(),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "AUTH password",
+		Complexity:    "O(N)",
 	}
 
 	RedisCommandECHO = RedisCommand{
@@ -1022,6 +1534,7 @@ var (
 		KeyExtractor:  ArgsAtIndices(),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "ECHO message",
+		Complexity:    "O(1)",
 	}
 
 	RedisCommandPING = RedisCommand{
@@ -1030,6 +1543,7 @@ var (
 		KeyExtractor:  ArgsAtIndices(),
 		TransformFunc: PingCommandTransformer,
 		Syntax:        "PING [message]",
+		Complexity:    "O(1)",
 	}
 
 	// QUIT command is not implemented in LedisDB.
@@ -1040,9 +1554,33 @@ var (
 		KeyExtractor:  ArgsAtIndices(),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "SELECT index",
+		Complexity:    "O(1)",
+	}
+
+	RedisCommandSWAPDB = RedisCommand{
+		Name:          "SWAPDB",
+		KeyType:       RedisTypeGeneric,
+		KeyExtractor:  ArgsAtIndices(),
+		TransformFunc: SwapdbCommandTransformer,
+		Syntax:        "SWAPDB index1 index2",
+		Complexity:    "O(1)",
 	}
+)
 
-	// SWAPDB command is not implemented in LedisDB.
+// RedisCommand variables describing the Redis commands for Redis Cluster.
+// LedisDB does not support clustering, only sub-commands which can be
+// computed locally are emulated.
+//
+//     https://redis.io/commands#cluster
+var (
+	RedisCommandCLUSTER = RedisCommand{
+		Name:          "CLUSTER",
+		KeyType:       RedisTypeGeneric,
+		KeyExtractor:  ArgsAtIndices(),
+		TransformFunc: ClusterCommandTransformer,
+		Syntax:        "CLUSTER subcommand [arguments [arguments ...]]",
+		Complexity:    "O(N) for KEYSLOT",
+	}
 )
 
 // RedisCommand variables describing the Redis commands for working with lua
@@ -1056,6 +1594,7 @@ var (
 		KeyExtractor:  ArgsAtIndices(),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "EVAL script numkeys key [key ...] arg [arg ...]",
+		Complexity:    "Depends on the script",
 	}
 
 	RedisCommandEVALSHA = RedisCommand{
@@ -1064,6 +1603,7 @@ var (
 		KeyExtractor:  ArgsAtIndices(),
 		TransformFunc: NoneTransformer(),
 		Syntax:        "EVALSHA sha1 numkeys key [key ...] arg [arg ...]",
+		Complexity:    "Depends on the script",
 	}
 
 	RedisCommandSCRIPT = RedisCommand{
@@ -1072,6 +1612,42 @@ var (
 		KeyExtractor:  ArgsAtIndices(),
 		TransformFunc: ScriptCommandTransformer,
 		Syntax:        "SCRIPT subcommand [arg ...]",
+		Complexity:    "Depends on subcommand",
+	}
+)
+
+// RedisCommand variables describing the Redis commands for working with
+// functions. Functions were introduced in Redis 7.0 and are not supported by
+// LedisDB.
+//
+//     https://redis.io/commands#scripting
+var (
+	RedisCommandFCALL = RedisCommand{
+		Name:          "FCALL",
+		KeyType:       RedisTypeGeneric,
+		KeyExtractor:  ArgsFromNumKeys(1),
+		TransformFunc: FunctionNotSupportedTransformer,
+		Syntax:        "FCALL function numkeys [key [key ...]] [arg [arg ...]]",
+		Complexity:    "Depends on the function",
+	}
+
+	RedisCommandFCALL_RO = RedisCommand{
+		Name:          "FCALL_RO",
+		KeyType:       RedisTypeGeneric,
+		KeyExtractor:  ArgsFromNumKeys(1),
+		TransformFunc: FunctionNotSupportedTransformer,
+		Syntax:        "FCALL_RO function numkeys [key [key ...]] [arg [arg ...]]",
+		Complexity:    "Depends on the function",
+		ReadOnly:      true,
+	}
+
+	RedisCommandFUNCTION = RedisCommand{
+		Name:          "FUNCTION",
+		KeyType:       RedisTypeGeneric,
+		KeyExtractor:  ArgsAtIndices(),
+		TransformFunc: FunctionNotSupportedTransformer,
+		Syntax:        "FUNCTION subcommand [arg ...]",
+		Complexity:    "Depends on subcommand",
 	}
 )
 
@@ -1085,210 +1661,372 @@ var (
 		KeyExtractor:  ArgsAtIndices(),
 		TransformFunc: UnsafeCommandTransformer,
 		Syntax:        "UNSAFE subcommand [arg ...]",
+		Complexity:    "Depends on the command",
 	}
 )
 
+// redisCommands contains all RedisCommand variables of the package. It is
+// used to populate redisCommandsByName.
+var redisCommands = []*RedisCommand{
+	&RedisCommandAPPEND,
+	&RedisCommandBITCOUNT,
+	&RedisCommandBITOP,
+	&RedisCommandBITPOS,
+	&RedisCommandDECR,
+	&RedisCommandDECRBY,
+	&RedisCommandGET,
+	&RedisCommandGETBIT,
+	&RedisCommandGETRANGE,
+	&RedisCommandGETSET,
+	&RedisCommandINCR,
+	&RedisCommandINCRBY,
+	&RedisCommandMGET,
+	&RedisCommandMSET,
+	&RedisCommandSET,
+	&RedisCommandSETBIT,
+	&RedisCommandSETEX,
+	&RedisCommandSETNX,
+	&RedisCommandSETRANGE,
+	&RedisCommandSTRLEN,
+	&RedisCommandHDEL,
+	&RedisCommandHEXISTS,
+	&RedisCommandHGET,
+	&RedisCommandHGETALL,
+	&RedisCommandHINCRBY,
+	&RedisCommandHKEYS,
+	&RedisCommandHLEN,
+	&RedisCommandHMGET,
+	&RedisCommandHMSET,
+	&RedisCommandHRANDFIELD,
+	&RedisCommandHSET,
+	&RedisCommandHVALS,
+	&RedisCommandHSCAN,
+	&RedisCommandBLMPOP,
+	&RedisCommandBLPOP,
+	&RedisCommandBRPOP,
+	&RedisCommandBRPOPLPUSH,
+	&RedisCommandLINDEX,
+	&RedisCommandLLEN,
+	&RedisCommandLMPOP,
+	&RedisCommandLPOP,
+	&RedisCommandLPUSH,
+	&RedisCommandLRANGE,
+	&RedisCommandLREM,
+	&RedisCommandLTRIM,
+	&RedisCommandRPOP,
+	&RedisCommandRPOPLPUSH,
+	&RedisCommandRPUSH,
+	&RedisCommandSADD,
+	&RedisCommandSCARD,
+	&RedisCommandSDIFF,
+	&RedisCommandSDIFFSTORE,
+	&RedisCommandSINTER,
+	&RedisCommandSINTERCARD,
+	&RedisCommandSINTERSTORE,
+	&RedisCommandSISMEMBER,
+	&RedisCommandSMEMBERS,
+	&RedisCommandSREM,
+	&RedisCommandSSCAN,
+	&RedisCommandSUNION,
+	&RedisCommandSUNIONSTORE,
+	&RedisCommandBZMPOP,
+	&RedisCommandZADD,
+	&RedisCommandZCARD,
+	&RedisCommandZCOUNT,
+	&RedisCommandZINCRBY,
+	&RedisCommandZINTERSTORE,
+	&RedisCommandZLEXCOUNT,
+	&RedisCommandZMPOP,
+	&RedisCommandZPOPMAX,
+	&RedisCommandZPOPMIN,
+	&RedisCommandZRANGE,
+	&RedisCommandZRANGEBYLEX,
+	&RedisCommandZRANGEBYSCORE,
+	&RedisCommandZRANK,
+	&RedisCommandZREM,
+	&RedisCommandZREMRANGEBYLEX,
+	&RedisCommandZREMRANGEBYRANK,
+	&RedisCommandZREMRANGEBYSCORE,
+	&RedisCommandZREVRANGE,
+	&RedisCommandZREVRANGEBYLEX,
+	&RedisCommandZREVRANGEBYSCORE,
+	&RedisCommandZREVRANK,
+	&RedisCommandZSCAN,
+	&RedisCommandZSCORE,
+	&RedisCommandZUNIONSTORE,
+	&RedisCommandGEOADD,
+	&RedisCommandGEODIST,
+	&RedisCommandGEORADIUS,
+	&RedisCommandPFADD,
+	&RedisCommandPFCOUNT,
+	&RedisCommandPFMERGE,
+	&RedisCommandXACK,
+	&RedisCommandXADD,
+	&RedisCommandXAUTOCLAIM,
+	&RedisCommandXCLAIM,
+	&RedisCommandXDEL,
+	&RedisCommandXGROUP,
+	&RedisCommandXINFO,
+	&RedisCommandXLEN,
+	&RedisCommandXPENDING,
+	&RedisCommandXRANGE,
+	&RedisCommandXREAD,
+	&RedisCommandXREADGROUP,
+	&RedisCommandXREVRANGE,
+	&RedisCommandXSETID,
+	&RedisCommandXTRIM,
+	&RedisCommandDEL,
+	&RedisCommandDUMP,
+	&RedisCommandEXISTS,
+	&RedisCommandEXPIRE,
+	&RedisCommandEXPIREAT,
+	&RedisCommandMOVE,
+	&RedisCommandOBJECT,
+	&RedisCommandPERSIST,
+	&RedisCommandRESTORE,
+	&RedisCommandSCAN,
+	&RedisCommandSORT,
+	&RedisCommandTTL,
+	&RedisCommandDISCARD,
+	&RedisCommandEXEC,
+	&RedisCommandMULTI,
+	&RedisCommandUNWATCH,
+	&RedisCommandWATCH,
+	&RedisCommandCLIENT,
+	&RedisCommandCOMMAND,
+	&RedisCommandDEBUG,
+	&RedisCommandFAILOVER,
+	&RedisCommandINFO,
+	&RedisCommandLOLWUT,
+	&RedisCommandAUTH,
+	&RedisCommandECHO,
+	&RedisCommandPING,
+	&RedisCommandSELECT,
+	&RedisCommandSWAPDB,
+	&RedisCommandCLUSTER,
+	&RedisCommandEVAL,
+	&RedisCommandEVALSHA,
+	&RedisCommandSCRIPT,
+	&RedisCommandFCALL,
+	&RedisCommandFCALL_RO,
+	&RedisCommandFUNCTION,
+	&RedisCommandUNSAFE,
+}
+
+// redisCommandArities contains the minimum and maximum number of arguments
+// (excluding the command name) of each RedisCommand variable of the package.
+// A maximum of -1 means that the number of arguments is not bounded. init
+// wraps the TransformFunc of each command using WithArityCheck.
+var redisCommandArities = map[string][2]int{
+	"APPEND":           {2, 2},
+	"BITCOUNT":         {1, 4},
+	"BITOP":            {3, -1},
+	"BITPOS":           {2, 5},
+	"DECR":             {1, 1},
+	"DECRBY":           {2, 2},
+	"GET":              {1, 1},
+	"GETBIT":           {2, 2},
+	"GETRANGE":         {3, 3},
+	"GETSET":           {2, 2},
+	"INCR":             {1, 1},
+	"INCRBY":           {2, 2},
+	"MGET":             {1, -1},
+	"MSET":             {2, -1},
+	"SET":              {2, -1},
+	"SETBIT":           {3, 3},
+	"SETEX":            {3, 3},
+	"SETNX":            {2, 2},
+	"SETRANGE":         {3, 3},
+	"STRLEN":           {1, 1},
+	"HDEL":             {2, -1},
+	"HEXISTS":          {2, 2},
+	"HGET":             {2, 2},
+	"HGETALL":          {1, 1},
+	"HINCRBY":          {3, 3},
+	"HKEYS":            {1, 1},
+	"HLEN":             {1, 1},
+	"HMGET":            {2, -1},
+	"HMSET":            {3, -1},
+	"HRANDFIELD":       {1, 3},
+	"HSET":             {3, -1},
+	"HVALS":            {1, 1},
+	"HSCAN":            {2, -1},
+	"BLMPOP":           {4, -1},
+	"BLPOP":            {2, -1},
+	"BRPOP":            {2, -1},
+	"BRPOPLPUSH":       {3, 3},
+	"LINDEX":           {2, 2},
+	"LLEN":             {1, 1},
+	"LMPOP":            {3, -1},
+	"LPOP":             {1, 2},
+	"LPUSH":            {2, -1},
+	"LRANGE":           {3, 3},
+	"LREM":             {3, 3},
+	"LTRIM":            {3, 3},
+	"RPOP":             {1, 2},
+	"RPOPLPUSH":        {2, 2},
+	"RPUSH":            {2, -1},
+	"SADD":             {2, -1},
+	"SCARD":            {1, 1},
+	"SDIFF":            {1, -1},
+	"SDIFFSTORE":       {2, -1},
+	"SINTER":           {1, -1},
+	"SINTERCARD":       {2, -1},
+	"SINTERSTORE":      {2, -1},
+	"SISMEMBER":        {2, 2},
+	"SMEMBERS":         {1, 1},
+	"SREM":             {2, -1},
+	"SSCAN":            {2, -1},
+	"SUNION":           {1, -1},
+	"SUNIONSTORE":      {2, -1},
+	"BZMPOP":           {4, -1},
+	"ZADD":             {3, -1},
+	"ZCARD":            {1, 1},
+	"ZCOUNT":           {3, 3},
+	"ZINCRBY":          {3, 3},
+	"ZINTERSTORE":      {3, -1},
+	"ZLEXCOUNT":        {3, 3},
+	"ZMPOP":            {3, -1},
+	"ZPOPMAX":          {1, 2},
+	"ZPOPMIN":          {1, 2},
+	"ZRANGE":           {3, -1},
+	"ZRANGEBYLEX":      {3, 6},
+	"ZRANGEBYSCORE":    {3, -1},
+	"ZRANK":            {2, 3},
+	"ZREM":             {2, -1},
+	"ZREMRANGEBYLEX":   {3, 3},
+	"ZREMRANGEBYRANK":  {3, 3},
+	"ZREMRANGEBYSCORE": {3, 3},
+	"ZREVRANGE":        {3, 4},
+	"ZREVRANGEBYLEX":   {3, 6},
+	"ZREVRANGEBYSCORE": {3, -1},
+	"ZREVRANK":         {2, 3},
+	"ZSCAN":            {2, -1},
+	"ZSCORE":           {2, 2},
+	"ZUNIONSTORE":      {3, -1},
+	"GEOADD":           {4, -1},
+	"GEODIST":          {3, 4},
+	"GEORADIUS":        {5, -1},
+	"PFADD":            {1, -1},
+	"PFCOUNT":          {1, -1},
+	"PFMERGE":          {1, -1},
+	"XACK":             {3, -1},
+	"XADD":             {4, -1},
+	"XAUTOCLAIM":       {5, -1},
+	"XCLAIM":           {5, -1},
+	"XDEL":             {2, -1},
+	"XGROUP":           {1, -1},
+	"XINFO":            {1, -1},
+	"XLEN":             {1, 1},
+	"XPENDING":         {2, -1},
+	"XRANGE":           {3, 5},
+	"XREAD":            {3, -1},
+	"XREADGROUP":       {6, -1},
+	"XREVRANGE":        {3, 5},
+	"XSETID":           {2, -1},
+	"XTRIM":            {3, -1},
+	"DEL":              {1, -1},
+	"DUMP":             {1, 1},
+	"EXISTS":           {1, -1},
+	"EXPIRE":           {2, 3},
+	"EXPIREAT":         {2, 3},
+	"MOVE":             {2, 2},
+	"OBJECT":           {1, -1},
+	"PERSIST":          {1, 1},
+	"RESTORE":          {3, -1},
+	"SORT":             {1, -1},
+	"TTL":              {1, 1},
+	"SCAN":             {1, -1},
+	"DISCARD":          {0, 0},
+	"EXEC":             {0, 0},
+	"MULTI":            {0, 0},
+	"UNWATCH":          {0, 0},
+	"WATCH":            {1, -1},
+	"CLIENT":           {1, -1},
+	"COMMAND":          {0, -1},
+	"DEBUG":            {1, -1},
+	"FAILOVER":         {0, -1},
+	"INFO":             {0, -1},
+	"LOLWUT":           {0, 2},
+	"SWAPDB":           {2, 2},
+	"CLUSTER":          {1, -1},
+	"AUTH":             {1, 2},
+	"ECHO":             {1, 1},
+	"PING":             {0, 1},
+	"SELECT":           {1, 1},
+	"EVAL":             {2, -1},
+	"EVALSHA":          {2, -1},
+	"SCRIPT":           {1, -1},
+	"FCALL":            {2, -1},
+	"FCALL_RO":         {2, -1},
+	"FUNCTION":         {1, -1},
+	"UNSAFE":           {1, -1},
+}
+
+// redisCommandAliases maps deprecated command names to the RedisCommand
+// variables implementing them. Aliases are resolved by RedisCommandFromName,
+// the RedisCommand's Name is sent to LedisDB.
+var redisCommandAliases = map[string]*RedisCommand{
+	"SUBSTR": &RedisCommandGETRANGE,
+}
+
+// redisCommandsByName maps command names (string) to the RedisCommand
+// variables of the package (*RedisCommand). It is populated by init.
+var redisCommandsByName sync.Map
+
+func init() {
+	for _, command := range redisCommands {
+		if arity, ok := redisCommandArities[command.Name]; ok {
+			command.TransformFunc = WithArityCheck(arity[0], arity[1], command.TransformFunc)
+		}
+
+		redisCommandsByName.Store(command.Name, command)
+	}
+
+	for alias, command := range redisCommandAliases {
+		redisCommandsByName.Store(alias, command)
+	}
+}
+
+// asciiUpper maps every byte to its ASCII uppercase equivalent. Bytes other
+// than the ASCII lowercase letters are mapped to themselves.
+var asciiUpper = func() (table [256]byte) {
+	for i := range table {
+		table[i] = byte(i)
+		if 'a' <= i && i <= 'z' {
+			table[i] -= 'a' - 'A'
+		}
+	}
+	return
+}()
+
+// canonicalCommandName returns the ASCII uppercase form of name. Command
+// names are usually passed in uppercase, in this case name is returned
+// without allocating.
+func canonicalCommandName(name string) string {
+	i := 0
+	for ; i < len(name); i++ {
+		if asciiUpper[name[i]] != name[i] {
+			break
+		}
+	}
+	if i == len(name) {
+		return name
+	}
+
+	upper := []byte(name)
+	for ; i < len(upper); i++ {
+		upper[i] = asciiUpper[upper[i]]
+	}
+
+	return string(upper)
+}
+
 func RedisCommandFromName(name string) (*RedisCommand, error) {
-	switch strings.ToUpper(name) {
-	case "APPEND":
-		return &RedisCommandAPPEND, nil
-	case "BITCOUNT":
-		return &RedisCommandBITCOUNT, nil
-	case "BITOP":
-		return &RedisCommandBITOP, nil
-	case "BITPOS":
-		return &RedisCommandBITPOS, nil
-	case "DECR":
-		return &RedisCommandDECR, nil
-	case "DECRBY":
-		return &RedisCommandDECRBY, nil
-	case "GET":
-		return &RedisCommandGET, nil
-	case "GETBIT":
-		return &RedisCommandGETBIT, nil
-	case "GETRANGE":
-		return &RedisCommandGETRANGE, nil
-	case "GETSET":
-		return &RedisCommandGETSET, nil
-	case "INCR":
-		return &RedisCommandINCR, nil
-	case "INCRBY":
-		return &RedisCommandINCRBY, nil
-	case "MGET":
-		return &RedisCommandMGET, nil
-	case "MSET":
-		return &RedisCommandMSET, nil
-	case "SET":
-		return &RedisCommandSET, nil
-	case "SETBIT":
-		return &RedisCommandSETBIT, nil
-	case "SETEX":
-		return &RedisCommandSETEX, nil
-	case "SETNX":
-		return &RedisCommandSETNX, nil
-	case "SETRANGE":
-		return &RedisCommandSETRANGE, nil
-	case "STRLEN":
-		return &RedisCommandSTRLEN, nil
-	case "HDEL":
-		return &RedisCommandHDEL, nil
-	case "HEXISTS":
-		return &RedisCommandHEXISTS, nil
-	case "HGET":
-		return &RedisCommandHGET, nil
-	case "HGETALL":
-		return &RedisCommandHGETALL, nil
-	case "HINCRBY":
-		return &RedisCommandHINCRBY, nil
-	case "HKEYS":
-		return &RedisCommandHKEYS, nil
-	case "HLEN":
-		return &RedisCommandHLEN, nil
-	case "HMGET":
-		return &RedisCommandHMGET, nil
-	case "HMSET":
-		return &RedisCommandHMSET, nil
-	case "HSET":
-		return &RedisCommandHSET, nil
-	case "HVALS":
-		return &RedisCommandHVALS, nil
-	case "HSCAN":
-		return &RedisCommandHSCAN, nil
-	case "BLPOP":
-		return &RedisCommandBLPOP, nil
-	case "BRPOP":
-		return &RedisCommandBRPOP, nil
-	case "BRPOPLPUSH":
-		return &RedisCommandBRPOPLPUSH, nil
-	case "LINDEX":
-		return &RedisCommandLINDEX, nil
-	case "LLEN":
-		return &RedisCommandLLEN, nil
-	case "LPOP":
-		return &RedisCommandLPOP, nil
-	case "LPUSH":
-		return &RedisCommandLPUSH, nil
-	case "LRANGE":
-		return &RedisCommandLRANGE, nil
-	case "LREM":
-		return &RedisCommandLREM, nil
-	case "LTRIM":
-		return &RedisCommandLTRIM, nil
-	case "RPOP":
-		return &RedisCommandRPOP, nil
-	case "RPOPLPUSH":
-		return &RedisCommandRPOPLPUSH, nil
-	case "RPUSH":
-		return &RedisCommandRPUSH, nil
-	case "SADD":
-		return &RedisCommandSADD, nil
-	case "SCARD":
-		return &RedisCommandSCARD, nil
-	case "SDIFF":
-		return &RedisCommandSDIFF, nil
-	case "SDIFFSTORE":
-		return &RedisCommandSDIFFSTORE, nil
-	case "SINTER":
-		return &RedisCommandSINTER, nil
-	case "SINTERSTORE":
-		return &RedisCommandSINTERSTORE, nil
-	case "SISMEMBER":
-		return &RedisCommandSISMEMBER, nil
-	case "SMEMBERS":
-		return &RedisCommandSMEMBERS, nil
-	case "SREM":
-		return &RedisCommandSREM, nil
-	case "SSCAN":
-		return &RedisCommandSSCAN, nil
-	case "SUNION":
-		return &RedisCommandSUNION, nil
-	case "SUNIONSTORE":
-		return &RedisCommandSUNIONSTORE, nil
-	case "ZADD":
-		return &RedisCommandZADD, nil
-	case "ZCARD":
-		return &RedisCommandZCARD, nil
-	case "ZCOUNT":
-		return &RedisCommandZCOUNT, nil
-	case "ZINCRBY":
-		return &RedisCommandZINCRBY, nil
-	case "ZINTERSTORE":
-		return &RedisCommandZINTERSTORE, nil
-	case "ZLEXCOUNT":
-		return &RedisCommandZLEXCOUNT, nil
-	case "ZRANGE":
-		return &RedisCommandZRANGE, nil
-	case "ZRANGEBYLEX":
-		return &RedisCommandZRANGEBYLEX, nil
-	case "ZRANGEBYSCORE":
-		return &RedisCommandZRANGEBYSCORE, nil
-	case "ZRANK":
-		return &RedisCommandZRANK, nil
-	case "ZREM":
-		return &RedisCommandZREM, nil
-	case "ZREMRANGEBYLEX":
-		return &RedisCommandZREMRANGEBYLEX, nil
-	case "ZREMRANGEBYRANK":
-		return &RedisCommandZREMRANGEBYRANK, nil
-	case "ZREMRANGEBYSCORE":
-		return &RedisCommandZREMRANGEBYSCORE, nil
-	case "ZREVRANGE":
-		return &RedisCommandZREVRANGE, nil
-	case "ZREVRANGEBYSCORE":
-		return &RedisCommandZREVRANGEBYSCORE, nil
-	case "ZREVRANK":
-		return &RedisCommandZREVRANK, nil
-	case "ZSCAN":
-		return &RedisCommandZSCAN, nil
-	case "ZSCORE":
-		return &RedisCommandZSCORE, nil
-	case "ZUNIONSTORE":
-		return &RedisCommandZUNIONSTORE, nil
-	case "DEL":
-		return &RedisCommandDEL, nil
-	case "DUMP":
-		return &RedisCommandDUMP, nil
-	case "EXISTS":
-		return &RedisCommandEXISTS, nil
-	case "EXPIRE":
-		return &RedisCommandEXPIRE, nil
-	case "EXPIREAT":
-		return &RedisCommandEXPIREAT, nil
-	case "PERSIST":
-		return &RedisCommandPERSIST, nil
-	case "RESTORE":
-		return &RedisCommandRESTORE, nil
-	case "SORT":
-		return &RedisCommandSORT, nil
-	case "TTL":
-		return &RedisCommandTTL, nil
-	case "DISCARD":
-		return &RedisCommandDISCARD, nil
-	case "EXEC":
-		return &RedisCommandEXEC, nil
-	case "MULTI":
-		return &RedisCommandMULTI, nil
-	case "UNWATCH":
-		return &RedisCommandUNWATCH, nil
-	case "WATCH":
-		return &RedisCommandWATCH, nil
-	case "AUTH":
-		return &RedisCommandAUTH, nil
-	case "ECHO":
-		return &RedisCommandECHO, nil
-	case "PING":
-		return &RedisCommandPING, nil
-	case "SELECT":
-		return &RedisCommandSELECT, nil
-	case "EVAL":
-		return &RedisCommandEVAL, nil
-	case "EVALSHA":
-		return &RedisCommandEVALSHA, nil
-	case "SCRIPT":
-		return &RedisCommandSCRIPT, nil
-	case "UNSAFE":
-		return &RedisCommandUNSAFE, nil
-	default:
+	command, ok := redisCommandsByName.Load(canonicalCommandName(name))
+	if !ok {
 		return nil, ErrUnknownRedisCommandName
 	}
+
+	return command.(*RedisCommand), nil
 }
@@ -0,0 +1,119 @@
+package rewledis
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRedisCommandFromName(t *testing.T) {
+	tests := []struct {
+		name    string
+		command *RedisCommand
+		err     error
+	}{
+		{"GET", &RedisCommandGET, nil},
+		{"get", &RedisCommandGET, nil},
+		{"gEt", &RedisCommandGET, nil},
+		{"ZADD", &RedisCommandZADD, nil},
+		{"SUBSTR", &RedisCommandGETRANGE, nil},
+		{"substr", &RedisCommandGETRANGE, nil},
+		{"UNSAFE", &RedisCommandUNSAFE, nil},
+		{"", nil, ErrUnknownRedisCommandName},
+		{"NOTACOMMAND", nil, ErrUnknownRedisCommandName},
+		{"GET ", nil, ErrUnknownRedisCommandName},
+	}
+
+	for _, test := range tests {
+		command, err := RedisCommandFromName(test.name)
+		if err != test.err {
+			t.Errorf("RedisCommandFromName(%q): err = %v, want %v", test.name, err, test.err)
+		}
+		if command != test.command {
+			t.Errorf("RedisCommandFromName(%q) = %v, want %v", test.name, command, test.command)
+		}
+	}
+
+	for _, command := range redisCommands {
+		found, err := RedisCommandFromName(command.Name)
+		if err != nil || found != command {
+			t.Errorf("RedisCommandFromName(%q) = %v, %v, want the command itself", command.Name, found, err)
+		}
+	}
+}
+
+// benchmarkCommandNames are looked up by BenchmarkRedisCommandFromName.
+var benchmarkCommandNames = [...]string{"GET", "SET", "HGETALL", "ZADD", "EXPIRE", "get", "zrangebyscore"}
+
+// BenchmarkRedisCommandFromName measures lookups of command names. Run it
+// with -benchtime=1000000x to measure 1M lookups.
+func BenchmarkRedisCommandFromName(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_, err := RedisCommandFromName(benchmarkCommandNames[i%len(benchmarkCommandNames)])
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestCanonicalCommandName(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"", ""},
+		{"GET", "GET"},
+		{"get", "GET"},
+		{"Get", "GET"},
+		{"GEt", "GET"},
+		{"zrangebyscore", "ZRANGEBYSCORE"},
+		{"a-z_09", "A-Z_09"},
+		{"{}`@[", "{}`@["},
+		{"\xe4\xf6", "\xe4\xf6"},
+	}
+
+	for _, test := range tests {
+		if name := canonicalCommandName(test.name); name != test.expected {
+			t.Errorf("canonicalCommandName(%q) = %q, want %q", test.name, name, test.expected)
+		}
+	}
+}
+
+func BenchmarkCanonicalCommandName(b *testing.B) {
+	benchmarks := []struct {
+		name      string
+		canonical func(string) string
+	}{
+		{"Table", canonicalCommandName},
+		{"ToUpper", strings.ToUpper},
+	}
+
+	for _, benchmark := range benchmarks {
+		for _, commandName := range []string{"ZRANGEBYSCORE", "zrangebyscore"} {
+			b.Run(benchmark.name+"/"+commandName, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					benchmark.canonical(commandName)
+				}
+			})
+		}
+	}
+}
+
+func TestKeyExtractors(t *testing.T) {
+	tests := []struct {
+		command *RedisCommand
+		args    []interface{}
+		keys    []interface{}
+	}{
+		{&RedisCommandPFCOUNT, []interface{}{"a"}, []interface{}{"a"}},
+		{&RedisCommandPFCOUNT, []interface{}{"a", "b", "c"}, []interface{}{"a", "b", "c"}},
+		{&RedisCommandPFMERGE, []interface{}{"dest", "a", "b"}, []interface{}{"dest", "a", "b"}},
+	}
+
+	for _, test := range tests {
+		keys := test.command.KeyExtractor.Args(test.args)
+		if !reflect.DeepEqual(keys, test.keys) {
+			t.Errorf("%s %v: keys = %v, want %v", test.command.Name, test.args, keys, test.keys)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package rewledis
 
 import (
 	"errors"
+	"time"
 
 	rewledisArgs "github.com/pskopnik/rewledis/args"
 
@@ -89,9 +90,58 @@ func ArgsFromUntilIndex(from, until int, skip ...int) ArgsExtractor {
 	})
 }
 
+// ArgsFromNumKeys returns an ArgsExtractor.
+// The ArgsExtractor interprets the argument at index as the number of keys
+// (numkeys) and returns the numkeys arguments following it. This matches the
+// argument layout of commands such as SINTERCARD. If the numkeys argument is
+// missing, cannot be converted to an integer or exceeds the number of
+// available arguments, no arguments are extracted.
+func ArgsFromNumKeys(index int) ArgsExtractor {
+	return ArgsExtractorFunc(func(extracted []interface{}, args []interface{}) []interface{} {
+		if index >= len(args) {
+			return extracted
+		}
+
+		argInfo := rewledisArgs.Parse(args[index])
+		numKeys, err := argInfo.ConvertToInt()
+		if err != nil || numKeys < 0 || int64(len(args)-index-1) < numKeys {
+			return extracted
+		}
+
+		return append(extracted, args[index+1:index+1+int(numKeys)]...)
+	})
+}
+
+var bitopArgsFromIndex = ArgsFromIndex(1)
+
+// BitopArgsExtractor is the ArgsExtractor of the BITOP command. It returns
+// destkey and all source keys. The NOT operation accepts a single source
+// key, so only destkey and srckey are returned for NOT.
+var BitopArgsExtractor ArgsExtractor = ArgsExtractorFunc(func(extracted []interface{}, args []interface{}) []interface{} {
+	if len(args) >= 3 {
+		argInfo := rewledisArgs.Parse(args[0])
+		if argInfo.EqualFoldEither(stringNOT, bytesNOT) {
+			return append(extracted, args[1], args[2])
+		}
+	}
+
+	return bitopArgsFromIndex.AppendArgs(extracted, args)
+})
+
 type Slot struct {
 	RepliesCount int
 	ProcessFunc  func([]interface{}) (interface{}, error)
+	// ReadTimeout overrides the read timeout of the connection while
+	// receiving the replies of the slot, if ReadTimeoutSet is true. A
+	// ReadTimeout of 0 disables the read timeout. This is used for blocking
+	// commands.
+	ReadTimeout    time.Duration
+	ReadTimeoutSet bool
+
+	// conn is the connection on which the replies of the slot are received.
+	// It is set by LedisConn for slots sent on its read connection, nil
+	// denotes the primary connection.
+	conn redis.Conn
 }
 
 type SendLedisFunc func(ledisConn redis.Conn) (Slot, error)
@@ -119,6 +169,21 @@ type RedisCommand struct {
 	KeyExtractor  ArgsExtractor
 	TransformFunc TransformFunc
 	Syntax        string
+	// Complexity describes the time complexity of the command as emulated by
+	// rewledis. It may be higher than the complexity of the native Redis
+	// command.
+	Complexity string
+	// ReadOnly is true for commands which never modify data. ReadOnly
+	// commands may be sent to a replica, see Rewriter.SetReadPool. Connection
+	// and server commands, e.g. PING or INFO, are not marked as ReadOnly, as
+	// they must reach the server of the connection they are issued on.
+	ReadOnly bool
+}
+
+// IsWriteCommand returns true if the command may modify data. Commands not
+// marked as ReadOnly are treated as write commands.
+func (r RedisCommand) IsWriteCommand() bool {
+	return !r.ReadOnly
 }
 
 func (r RedisCommand) Keys(args []interface{}) []string {
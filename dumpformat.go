@@ -0,0 +1,87 @@
+package rewledis
+
+import (
+	"encoding/binary"
+	"hash/crc64"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// DumpFormat identifies the serialisation format of values passed to
+// RESTORE, see Rewriter.DumpFormat.
+type DumpFormat int8
+
+// Constants which a DumpFormat value can assume.
+const (
+	// DumpFormatLedis denotes values serialised by LedisDB's DUMP commands.
+	// Values are passed on to LedisDB unchanged.
+	DumpFormatLedis DumpFormat = iota
+	// DumpFormatRedis denotes values serialised by Redis' DUMP command.
+	// Values are transcoded before they are passed on to LedisDB.
+	DumpFormatRedis
+)
+
+// Error replies returned by RESTORE when a value cannot be transcoded. The
+// messages match those of Redis.
+var (
+	errDumpPayloadInvalid = redis.Error("ERR DUMP payload version or checksum are wrong")
+	errDumpBadDataFormat  = redis.Error("ERR Bad data format")
+)
+
+// ledisRDBVersion is the RDB version of the values serialised and accepted
+// by LedisDB.
+const ledisRDBVersion = 6
+
+// dumpFooterLength is the length of the footer of a serialised value: the
+// RDB version (2 bytes) followed by the CRC64 checksum (8 bytes).
+const dumpFooterLength = 10
+
+// dumpCRCTable is the table of the CRC64 variant (Jones polynomial, reflected)
+// used by Redis for checksums of serialised values.
+var dumpCRCTable = crc64.MakeTable(0x95ac9329ac4bc9b5)
+
+// dumpChecksum computes the checksum of a serialised value as Redis does. The
+// initial and final XOR of hash/crc64 are cancelled out.
+func dumpChecksum(data []byte) uint64 {
+	return ^crc64.Update(^uint64(0), dumpCRCTable, data)
+}
+
+// transcodeRedisDump transcodes serialised, a value serialised by Redis'
+// DUMP command, so that it can be restored by LedisDB.
+//
+// LedisDB reads values in RDB version 6. Newer Redis versions tag values with
+// a higher RDB version, even if the value's encoding is available in RDB
+// version 6. For such values, the version is rewritten and the checksum is
+// recomputed. Values using encodings introduced after RDB version 6
+// (quicklist, listpack, streams, modules, ...) cannot be transcoded, for
+// these an error reply is returned.
+//
+// The returned slice is a copy, serialised is not modified.
+func transcodeRedisDump(serialised []byte) ([]byte, error) {
+	if len(serialised) < dumpFooterLength+1 {
+		return nil, errDumpPayloadInvalid
+	}
+
+	bodyLength := len(serialised) - 8
+	checksum := binary.LittleEndian.Uint64(serialised[bodyLength:])
+	if checksum != dumpChecksum(serialised[:bodyLength]) {
+		return nil, errDumpPayloadInvalid
+	}
+
+	switch serialised[0] {
+	case 0, 1, 2, 3, 4, 9, 10, 11, 12, 13:
+		// string, list, set, zset, hash, zipmap, ziplist, intset, zset
+		// ziplist and hash ziplist encodings are all part of RDB version 6.
+	default:
+		return nil, errDumpBadDataFormat
+	}
+
+	transcoded := make([]byte, len(serialised))
+	copy(transcoded, serialised)
+
+	versionOffset := len(transcoded) - dumpFooterLength
+	binary.LittleEndian.PutUint16(transcoded[versionOffset:], ledisRDBVersion)
+	binary.LittleEndian.PutUint64(transcoded[bodyLength:], dumpChecksum(transcoded[:bodyLength]))
+
+	return transcoded, nil
+}
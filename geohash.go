@@ -0,0 +1,114 @@
+package rewledis
+
+import (
+	"math"
+)
+
+// Geohash constants used by Redis. Coordinates are encoded in 52 bit
+// integers, 26 bits for each of longitude and latitude.
+const (
+	geoStep         = 26
+	geoLatitudeMin  = -85.05112878
+	geoLatitudeMax  = 85.05112878
+	geoLongitudeMin = -180.0
+	geoLongitudeMax = 180.0
+
+	// geoEarthRadius is the earth radius in metres used by Redis for
+	// distance calculations.
+	geoEarthRadius = 6372797.560856
+)
+
+// geoUnitFactors maps the distance units supported by Redis to their length
+// in metres.
+var geoUnitFactors = map[string]float64{
+	"m":  1,
+	"km": 1000,
+	"ft": 0.3048,
+	"mi": 1609.34,
+}
+
+// geoValidCoordinates checks whether longitude and latitude can be encoded.
+func geoValidCoordinates(longitude, latitude float64) bool {
+	return longitude >= geoLongitudeMin && longitude <= geoLongitudeMax &&
+		latitude >= geoLatitudeMin && latitude <= geoLatitudeMax
+}
+
+// geoEncode encodes longitude and latitude in a 52 bit geohash in the same
+// way as Redis does. The geohash is the score of the member of a sorted set
+// representing a geospatial index. The coordinates must be valid, see
+// geoValidCoordinates.
+func geoEncode(longitude, latitude float64) uint64 {
+	latitudeOffset := (latitude - geoLatitudeMin) / (geoLatitudeMax - geoLatitudeMin)
+	longitudeOffset := (longitude - geoLongitudeMin) / (geoLongitudeMax - geoLongitudeMin)
+
+	latitudeBits := uint32(latitudeOffset * (1 << geoStep))
+	longitudeBits := uint32(longitudeOffset * (1 << geoStep))
+
+	return geoInterleave(latitudeBits, longitudeBits)
+}
+
+// geoDecode decodes the geohash bits into the longitude and latitude at the
+// centre of the area represented by the geohash.
+func geoDecode(bits uint64) (longitude, latitude float64) {
+	latitudeBits, longitudeBits := geoDeinterleave(bits)
+
+	latitudeScale := geoLatitudeMax - geoLatitudeMin
+	longitudeScale := geoLongitudeMax - geoLongitudeMin
+
+	latitudeMin := geoLatitudeMin + float64(latitudeBits)/(1<<geoStep)*latitudeScale
+	latitudeMax := geoLatitudeMin + float64(latitudeBits+1)/(1<<geoStep)*latitudeScale
+	longitudeMin := geoLongitudeMin + float64(longitudeBits)/(1<<geoStep)*longitudeScale
+	longitudeMax := geoLongitudeMin + float64(longitudeBits+1)/(1<<geoStep)*longitudeScale
+
+	longitude = math.Max(geoLongitudeMin, math.Min(geoLongitudeMax, (longitudeMin+longitudeMax)/2))
+	latitude = math.Max(geoLatitudeMin, math.Min(geoLatitudeMax, (latitudeMin+latitudeMax)/2))
+
+	return
+}
+
+// geoInterleave interleaves the bits of x and y, x occupies the even and y
+// the odd bit positions of the result.
+func geoInterleave(x, y uint32) uint64 {
+	return geoSpread(x) | geoSpread(y)<<1
+}
+
+// geoDeinterleave reverses geoInterleave.
+func geoDeinterleave(bits uint64) (x, y uint32) {
+	return geoSqueeze(bits), geoSqueeze(bits >> 1)
+}
+
+// geoSpread moves the bits of v to the even bit positions.
+func geoSpread(v uint32) uint64 {
+	x := uint64(v)
+	x = (x | x<<16) & 0x0000ffff0000ffff
+	x = (x | x<<8) & 0x00ff00ff00ff00ff
+	x = (x | x<<4) & 0x0f0f0f0f0f0f0f0f
+	x = (x | x<<2) & 0x3333333333333333
+	x = (x | x<<1) & 0x5555555555555555
+	return x
+}
+
+// geoSqueeze collects the even bits of x, reversing geoSpread.
+func geoSqueeze(x uint64) uint32 {
+	x &= 0x5555555555555555
+	x = (x | x>>1) & 0x3333333333333333
+	x = (x | x>>2) & 0x0f0f0f0f0f0f0f0f
+	x = (x | x>>4) & 0x00ff00ff00ff00ff
+	x = (x | x>>8) & 0x0000ffff0000ffff
+	x = (x | x>>16) & 0x00000000ffffffff
+	return uint32(x)
+}
+
+// geoDistance computes the distance in metres between two points using the
+// Haversine formula, just as Redis does.
+func geoDistance(longitude1, latitude1, longitude2, latitude2 float64) float64 {
+	latitude1r := latitude1 * math.Pi / 180
+	longitude1r := longitude1 * math.Pi / 180
+	latitude2r := latitude2 * math.Pi / 180
+	longitude2r := longitude2 * math.Pi / 180
+
+	u := math.Sin((latitude2r - latitude1r) / 2)
+	v := math.Sin((longitude2r - longitude1r) / 2)
+
+	return 2 * geoEarthRadius * math.Asin(math.Sqrt(u*u+math.Cos(latitude1r)*math.Cos(latitude2r)*v*v))
+}
@@ -2,8 +2,13 @@ package rewledis
 
 import (
 	"errors"
+	"fmt"
+	"strings"
+	"sync"
 	"time"
 
+	rewledisArgs "github.com/pskopnik/rewledis/args"
+
 	"github.com/gomodule/redigo/redis"
 )
 
@@ -11,6 +16,10 @@ import (
 var (
 	ErrTimeoutNotSupported = errors.New("rewledis: connection does not support ConnWithTimeout")
 	ErrConnClosed          = errors.New("rewledis: connection closed")
+
+	ErrOrphanedSlot = errors.New("rewledis: orphaned slot in pending replies")
+
+	ErrCommandNotAllowedInSubscriptionMode = errors.New("rewledis: only (P)SUBSCRIBE / (P)UNSUBSCRIBE / PING / QUIT / CLIENT LIST allowed in subscription mode")
 )
 
 var _ redis.Conn = &LedisConn{}
@@ -27,6 +36,128 @@ type LedisConn struct {
 	// rewriting or errors returned by the methods of the underlying connection.
 	err  error
 	conn redis.Conn
+	// currentDB is the index of the database currently selected on the
+	// connection. It is updated whenever a SELECT command succeeds.
+	currentDB int
+	// sendDB is the index of the database selected once all commands sent so
+	// far have been executed. It is updated when SELECT is sent and is used
+	// to scope and route the commands sent after it. pendingSelects is the
+	// number of SELECT commands whose reply has not been received yet.
+	sendDB         int
+	pendingSelects int
+	// queuedDB is the index of the database selected by a SELECT queued in
+	// a transaction. It becomes currentDB once the transaction is executed.
+	// selectQueued reports whether queuedDB is set.
+	queuedDB     int
+	selectQueued bool
+	// subscriptionMode is true while the connection is subscribed to at
+	// least one channel or pattern through a PubSubConn. Only pub/sub
+	// related commands may be issued in subscription mode.
+	// subscriptionMutex protects subscriptionMode and the subscription
+	// counts, which are updated by the goroutine receiving on the
+	// PubSubConn.
+	subscriptionMode  bool
+	subscriptionMutex sync.Mutex
+	// subscriptions and patternSubscriptions are the number of channels and
+	// patterns the connection is subscribed to through a PubSubConn.
+	subscriptions        int
+	patternSubscriptions int
+	// scopedRewriter is the rewriter scoped to the connection, see
+	// Rewriter.forConn. It is replaced once another database is selected.
+	scopedRewriter *Rewriter
+	// writeMutex serialises writes to conn by a PubSubConn and the goroutine
+	// started by StartPing.
+	writeMutex sync.Mutex
+	// readConn is the connection on which ReadOnly commands are sent, if the
+	// rewriter has a read pool, see Rewriter.SetReadPool. It is obtained
+	// from the read pool when first needed. readConnDB is the index of the
+	// database selected on readConn.
+	readConn   redis.Conn
+	readConnDB int
+	// inMulti is true between MULTI and EXEC or DISCARD. watching is true
+	// between WATCH and EXEC, DISCARD or UNWATCH. While either is true, all
+	// commands are sent on conn, see trackTransaction.
+	inMulti  bool
+	watching bool
+}
+
+// CurrentDB returns the index of the database currently selected on the
+// connection. The index is tracked by observing successful SELECT commands
+// issued on the connection, it is 0 initially. Commands sent after a SELECT
+// whose reply has not been received yet already apply to the newly selected
+// database.
+func (l *LedisConn) CurrentDB() int {
+	return l.currentDB
+}
+
+// Subscriptions returns the number of channels and patterns the connection
+// is subscribed to through a PubSubConn. Subscriptions may be called
+// concurrently with the PubSubConn's Receive.
+func (l *LedisConn) Subscriptions() (channels int, patterns int) {
+	l.subscriptionMutex.Lock()
+	defer l.subscriptionMutex.Unlock()
+
+	return l.subscriptions, l.patternSubscriptions
+}
+
+// StartPing starts a background goroutine sending PING on the connection
+// every interval. This keeps long-lived pub/sub connections alive, e.g.
+// through network middleboxes dropping idle connections. The goroutine stops
+// once the returned function is called or the connection is closed.
+//
+// StartPing is meant for connections in subscription mode, see PubSubConn.
+// The replies to the PINGs are received as redis.Pong values by
+// PubSubConn.Receive. Outside of subscription mode, the replies would not be
+// consumed and would be mistaken for the replies of other commands. Thus no
+// PING is sent while the connection is not in subscription mode, e.g. before
+// the first subscription or after all subscriptions have been removed.
+func (l *LedisConn) StartPing(interval time.Duration) func() {
+	conn := l.conn
+	if conn == nil {
+		return func() {}
+	}
+
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+
+			l.subscriptionMutex.Lock()
+			subscriptionMode := l.subscriptionMode
+			l.subscriptionMutex.Unlock()
+
+			if !subscriptionMode {
+				continue
+			}
+
+			l.writeMutex.Lock()
+			err := conn.Send("PING")
+			if err == nil {
+				err = conn.Flush()
+			}
+			l.writeMutex.Unlock()
+
+			if err != nil {
+				// The connection has been closed or is broken.
+				return
+			}
+		}
+	}()
+
+	return func() {
+		stopOnce.Do(func() {
+			close(stop)
+		})
+	}
 }
 
 // RawConn returns the underlying connection to the LedisDB server.
@@ -59,6 +190,22 @@ func (l *LedisConn) Close() error {
 	err := l.conn.Close()
 	l.conn = nil
 	l.slots.Clear()
+
+	if l.readConn != nil {
+		readConn := l.readConn
+		if l.readConnDB != 0 {
+			readConn = &dbConn{
+				Conn: readConn,
+			}
+		}
+
+		readErr := readConn.Close()
+		l.readConn = nil
+		if err == nil {
+			err = readErr
+		}
+	}
+
 	return err
 }
 
@@ -72,6 +219,12 @@ func (l *LedisConn) Err() error {
 		return ErrConnClosed
 	}
 
+	if l.readConn != nil {
+		if err := l.readConn.Err(); err != nil {
+			return err
+		}
+	}
+
 	return l.conn.Err()
 }
 
@@ -81,6 +234,16 @@ func (l *LedisConn) Send(commandName string, args ...interface{}) error {
 		return ErrConnClosed
 	}
 
+	err := checkCommandName(commandName)
+	if err != nil {
+		return err
+	}
+
+	err = l.checkSubscriptionMode(commandName, args)
+	if err != nil {
+		return err
+	}
+
 	slot, err := l.rewriteAndSend(commandName, args...)
 	if err != nil {
 		return l.fatal(err)
@@ -98,7 +261,7 @@ func (l *LedisConn) Flush() error {
 	}
 
 	// error is captured by underlying conn
-	return l.conn.Flush()
+	return l.flushConns()
 }
 
 // Receive receives a single reply from the Redis server.
@@ -110,7 +273,7 @@ func (l *LedisConn) Receive() (interface{}, error) {
 	slot := l.slots.PopFront()
 
 	var repliesArray [8]interface{}
-	replies, err := l.receiveRepliesAppend(slot.RepliesCount, repliesArray[:0])
+	replies, err := l.receiveSlotRepliesAppend(slot, repliesArray[:0])
 	if err != nil {
 		// error is captured by underlying conn
 		return nil, err
@@ -121,7 +284,7 @@ func (l *LedisConn) Receive() (interface{}, error) {
 		return nil, l.fatal(err)
 	}
 
-	if err, ok := reply.(redis.Error); ok {
+	if err, ok := reply.(error); ok {
 		return reply, err
 	}
 
@@ -142,6 +305,14 @@ func (l *LedisConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, erro
 
 	slot := l.slots.PopFront()
 
+	if slot.conn != nil {
+		connWithTimeout, ok = slot.conn.(redis.ConnWithTimeout)
+		if !ok {
+			l.slots.PushFront(slot)
+			return nil, ErrTimeoutNotSupported
+		}
+	}
+
 	var repliesArray [8]interface{}
 	replies, err := l.receiveRepliesWithTimeoutAppend(connWithTimeout, slot.RepliesCount, timeout, repliesArray[:0])
 	if err != nil {
@@ -154,7 +325,7 @@ func (l *LedisConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, erro
 		return nil, l.fatal(err)
 	}
 
-	if err, ok := reply.(redis.Error); ok {
+	if err, ok := reply.(error); ok {
 		return reply, err
 	}
 
@@ -170,6 +341,21 @@ func (l *LedisConn) Do(commandName string, args ...interface{}) (interface{}, er
 	var err error
 	var slot Slot
 
+	err = checkCommandName(commandName)
+	if err != nil {
+		return nil, err
+	}
+
+	err = l.checkSubscriptionMode(commandName, args)
+	if err != nil {
+		return nil, err
+	}
+
+	err = l.validateSlots()
+	if err != nil {
+		return nil, l.fatal(err)
+	}
+
 	if len(commandName) > 0 {
 		slot, err = l.rewriteAndSend(commandName, args...)
 		if err != nil {
@@ -178,7 +364,7 @@ func (l *LedisConn) Do(commandName string, args ...interface{}) (interface{}, er
 		}
 	}
 
-	err = l.conn.Flush()
+	err = l.flushConns()
 	if err != nil {
 		// error is captured by underlying conn
 		return nil, err
@@ -192,7 +378,7 @@ func (l *LedisConn) Do(commandName string, args ...interface{}) (interface{}, er
 
 	if len(commandName) > 0 {
 		var repliesArray [8]interface{}
-		replies, err := l.receiveRepliesAppend(slot.RepliesCount, repliesArray[:0])
+		replies, err := l.receiveSlotRepliesAppend(slot, repliesArray[:0])
 		if err != nil {
 			// error is captured by underlying conn
 			return nil, err
@@ -203,7 +389,7 @@ func (l *LedisConn) Do(commandName string, args ...interface{}) (interface{}, er
 			return nil, l.fatal(err)
 		}
 
-		if err, ok := reply.(redis.Error); ok {
+		if err, ok := reply.(error); ok {
 			return reply, err
 		}
 
@@ -228,6 +414,21 @@ func (l *LedisConn) DoWithTimeout(timeout time.Duration, commandName string, arg
 	var err error
 	var slot Slot
 
+	err = checkCommandName(commandName)
+	if err != nil {
+		return nil, err
+	}
+
+	err = l.checkSubscriptionMode(commandName, args)
+	if err != nil {
+		return nil, err
+	}
+
+	err = l.validateSlots()
+	if err != nil {
+		return nil, l.fatal(err)
+	}
+
 	if len(commandName) > 0 {
 		slot, err = l.rewriteAndSend(commandName, args...)
 		if err != nil {
@@ -236,7 +437,7 @@ func (l *LedisConn) DoWithTimeout(timeout time.Duration, commandName string, arg
 		}
 	}
 
-	err = l.conn.Flush()
+	err = l.flushConns()
 	if err != nil {
 		// error is captured by underlying conn
 		return nil, err
@@ -249,6 +450,13 @@ func (l *LedisConn) DoWithTimeout(timeout time.Duration, commandName string, arg
 	}
 
 	if len(commandName) > 0 {
+		if slot.conn != nil {
+			connWithTimeout, ok = slot.conn.(redis.ConnWithTimeout)
+			if !ok {
+				return nil, l.fatal(ErrTimeoutNotSupported)
+			}
+		}
+
 		var repliesArray [8]interface{}
 		replies, err := l.receiveRepliesWithTimeoutAppend(connWithTimeout, slot.RepliesCount, timeout, repliesArray[:0])
 		if err != nil {
@@ -261,7 +469,7 @@ func (l *LedisConn) DoWithTimeout(timeout time.Duration, commandName string, arg
 			return nil, l.fatal(err)
 		}
 
-		if err, ok := reply.(redis.Error); ok {
+		if err, ok := reply.(error); ok {
 			return reply, err
 		}
 
@@ -271,16 +479,89 @@ func (l *LedisConn) DoWithTimeout(timeout time.Duration, commandName string, arg
 	return nil, nil
 }
 
+// checkCommandName returns ErrInvalidSyntax if commandName contains
+// characters other than printable, non-space ASCII characters. Such names
+// are typically the result of passing an inline command, e.g. "PING\r\n", as
+// the command name. The empty commandName (flush only) is allowed.
+func checkCommandName(commandName string) error {
+	for i := 0; i < len(commandName); i++ {
+		if commandName[i] <= ' ' || commandName[i] > '~' {
+			return ErrInvalidSyntax
+		}
+	}
+
+	return nil
+}
+
+// checkSubscriptionMode returns ErrCommandNotAllowedInSubscriptionMode if
+// the connection is in subscription mode and the command is not allowed in
+// this mode. The empty commandName (flush only) is always allowed. CLIENT
+// LIST is allowed as well, it is answered without contacting LedisDB and
+// reports the subscriptions of the connection.
+func (l *LedisConn) checkSubscriptionMode(commandName string, args []interface{}) error {
+	if len(commandName) == 0 {
+		return nil
+	}
+
+	l.subscriptionMutex.Lock()
+	subscriptionMode := l.subscriptionMode
+	l.subscriptionMutex.Unlock()
+
+	if !subscriptionMode {
+		return nil
+	}
+
+	switch strings.ToUpper(commandName) {
+	case "SUBSCRIBE", "PSUBSCRIBE", "UNSUBSCRIBE", "PUNSUBSCRIBE", "PING", "QUIT":
+		return nil
+	case "CLIENT":
+		if len(args) > 0 {
+			argInfo := rewledisArgs.Parse(args[0])
+			if argInfo.IsStringLike() && argInfo.EqualFoldEither(stringLIST, bytesLIST) {
+				return nil
+			}
+		}
+		return ErrCommandNotAllowedInSubscriptionMode
+	default:
+		return ErrCommandNotAllowedInSubscriptionMode
+	}
+}
+
+// validateSlots ensures that the deque of pending slots is consistent, i.e.
+// every slot can process its replies. An error wrapping ErrOrphanedSlot is
+// returned for the first slot lacking a ProcessFunc or having a negative
+// RepliesCount. The replies of such a slot cannot be consumed, so the
+// connection's reply stream can no longer be kept in sync.
+func (l *LedisConn) validateSlots() error {
+	for i := 0; i < l.slots.Len(); i++ {
+		slot := l.slots.At(i)
+		if slot.ProcessFunc == nil || slot.RepliesCount < 0 {
+			return fmt.Errorf(
+				"%w: slot %d of %d (replies count %d, process func set %t)",
+				ErrOrphanedSlot, i, l.slots.Len(), slot.RepliesCount, slot.ProcessFunc != nil,
+			)
+		}
+	}
+
+	return nil
+}
+
+// consumeSlots receives the replies of all pending slots. The replies are
+// processed using the ProcessFunc of each slot, so that side effects such as
+// cache updates take place. The processed replies are discarded.
 func (l *LedisConn) consumeSlots() error {
+	var repliesArray [8]interface{}
+
 	for i := 0; i < l.slots.Len(); i++ {
-		for j := 0; j < l.slots.At(i).RepliesCount; j++ {
-			_, err := l.conn.Receive()
-			if err != nil {
-				if _, ok := err.(redis.Error); !ok {
-					return err
-				}
-			}
+		slot := l.slots.At(i)
+
+		replies, err := l.receiveSlotRepliesAppend(slot, repliesArray[:0])
+		if err != nil {
+			return err
 		}
+
+		// Errors occurring while processing a discarded reply are ignored.
+		_, _ = slot.ProcessFunc(replies)
 	}
 
 	l.slots.Clear()
@@ -289,25 +570,299 @@ func (l *LedisConn) consumeSlots() error {
 }
 
 func (l *LedisConn) rewriteAndSend(commandName string, args ...interface{}) (Slot, error) {
-	sendLedisFunc, err := l.rewriter.Rewrite(commandName, args...)
+	command, err := l.rewriter.lookupCommand(commandName)
 	if err != nil {
 		return Slot{}, err
 	}
 
-	slot, err := sendLedisFunc(l.conn)
+	if l.scopedRewriter == nil || l.scopedRewriter.db != l.sendDB {
+		l.scopedRewriter = l.rewriter.forConn(l)
+	}
+
+	sendLedisFunc, err := l.transform(command, args)
 	if err != nil {
 		return Slot{}, err
 	}
 
+	l.trackTransaction(command)
+
+	conn := l.conn
+	if command.ReadOnly && !l.inMulti && !l.watching && l.rewriter.root().readPool != nil {
+		conn, err = l.getReadConn()
+		if err != nil {
+			return Slot{}, err
+		}
+
+		if l.readConnDB != l.sendDB {
+			sendLedisFunc = l.selectReadDB(sendLedisFunc, l.sendDB)
+		}
+	}
+
+	tracer := l.rewriter.root().Tracer
+
+	var slot Slot
+	if tracer != nil {
+		recordingConn := &commandRecordingConn{Conn: conn}
+		slot, err = sendLedisFunc(recordingConn)
+		if err != nil {
+			return Slot{}, err
+		}
+
+		tracer.Record(formatTracedCommand(commandName, args), recordingConn.commands)
+	} else {
+		slot, err = sendLedisFunc(conn)
+		if err != nil {
+			return Slot{}, err
+		}
+	}
+
+	if conn != l.conn {
+		slot.conn = conn
+	}
+
+	switch command.Name {
+	case "SELECT":
+		if len(args) == 1 {
+			l.trackSelect(&slot, args[0])
+		}
+	case "EXEC":
+		l.trackExec(&slot)
+	}
+
+	if errorHandler := l.rewriter.root().ErrorHandler; errorHandler != nil {
+		l.handleErrors(&slot, errorHandler, commandName)
+	}
+
 	return slot, nil
 }
 
-func (l *LedisConn) receiveRepliesAppend(count int, replies []interface{}) ([]interface{}, error) {
+// transform calls the TransformFunc of command. If a slow command threshold
+// and a logger are set on the rewriter, transformations taking longer than
+// the threshold are logged. See Rewriter.SetSlowCommandThreshold.
+func (l *LedisConn) transform(command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	root := l.rewriter.root()
+	if root.slowCommandThreshold <= 0 || root.logger == nil {
+		return command.TransformFunc(l.scopedRewriter, command, args)
+	}
+
+	start := time.Now()
+	sendLedisFunc, err := command.TransformFunc(l.scopedRewriter, command, args)
+	duration := time.Since(start)
+
+	if duration > root.slowCommandThreshold {
+		root.logger.Log(
+			"rewledis: slow command transformation",
+			"command", command.Name,
+			"args", len(args),
+			"duration", duration,
+			"threshold", root.slowCommandThreshold,
+		)
+	}
+
+	return sendLedisFunc, err
+}
+
+// trackTransaction updates the transaction state of the connection when
+// command is sent. The state is updated when commands are sent rather than
+// when their replies are received, as all commands sent after MULTI or WATCH
+// belong to the transaction. Commands of a transaction are not sent on the
+// read connection, they have to be executed on the primary.
+func (l *LedisConn) trackTransaction(command *RedisCommand) {
+	switch command.Name {
+	case "MULTI":
+		l.inMulti = true
+	case "WATCH":
+		l.watching = true
+	case "UNWATCH":
+		l.watching = false
+	case "EXEC", "DISCARD":
+		l.inMulti = false
+		l.watching = false
+	}
+}
+
+// getReadConn returns the read connection, obtaining it from the read pool of
+// the rewriter if necessary.
+func (l *LedisConn) getReadConn() (redis.Conn, error) {
+	if l.readConn != nil {
+		return l.readConn, nil
+	}
+
+	conn := l.rewriter.root().readPool.Get()
+	if err := conn.Err(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	l.readConn = conn
+	l.readConnDB = 0
+
+	return conn, nil
+}
+
+// selectReadDB wraps sendLedisFunc, so that the database with index db is
+// selected on the read connection before the command is sent. The reply to
+// SELECT is stripped from the slot's replies.
+func (l *LedisConn) selectReadDB(sendLedisFunc SendLedisFunc, db int) SendLedisFunc {
+	return SendLedisFunc(func(ledisConn redis.Conn) (Slot, error) {
+		err := ledisConn.Send("SELECT", db)
+		if err != nil {
+			return Slot{}, err
+		}
+
+		slot, err := sendLedisFunc(ledisConn)
+		if err != nil {
+			return Slot{}, err
+		}
+
+		l.readConnDB = db
+
+		processFunc := slot.ProcessFunc
+		slot.RepliesCount++
+		slot.ProcessFunc = func(replies []interface{}) (interface{}, error) {
+			if err, ok := replies[0].(redis.Error); ok {
+				return nil, err
+			}
+
+			return processFunc(replies[1:])
+		}
+
+		return slot, nil
+	})
+}
+
+// flushConns flushes the output buffers of the connection and of the read
+// connection, if one has been obtained. conn is flushed while holding
+// writeMutex, as a PubSubConn may write to it concurrently.
+func (l *LedisConn) flushConns() error {
+	if l.readConn != nil {
+		err := l.readConn.Flush()
+		if err != nil {
+			return err
+		}
+	}
+
+	l.writeMutex.Lock()
+	defer l.writeMutex.Unlock()
+
+	return l.conn.Flush()
+}
+
+// handleErrors wraps the ProcessFunc of slot, so that error replies are
+// passed to errorHandler. See Rewriter.ErrorHandler.
+func (l *LedisConn) handleErrors(slot *Slot, errorHandler func(error, string) error, commandName string) {
+	processFunc := slot.ProcessFunc
+	slot.ProcessFunc = func(replies []interface{}) (interface{}, error) {
+		reply, err := processFunc(replies)
+		if err != nil {
+			return nil, err
+		}
+
+		if replyErr, ok := reply.(redis.Error); ok {
+			if err := errorHandler(replyErr, commandName); err != nil {
+				return err, nil
+			}
+			return nil, nil
+		}
+
+		return reply, nil
+	}
+}
+
+// trackSelect updates sendDB for slot, the slot of a SELECT command, and
+// wraps its ProcessFunc, so that currentDB is updated once the command
+// succeeds. If the SELECT is queued in a transaction, currentDB is updated
+// once the transaction is executed, see trackExec. If the SELECT fails and
+// no other SELECT is pending, sendDB is reset to currentDB.
+func (l *LedisConn) trackSelect(slot *Slot, indexArg interface{}) {
+	argInfo := rewledisArgs.Parse(indexArg)
+	index, err := argInfo.ConvertToInt()
+	if err != nil {
+		return
+	}
+
+	l.sendDB = int(index)
+	l.pendingSelects++
+
+	processFunc := slot.ProcessFunc
+	slot.ProcessFunc = func(replies []interface{}) (interface{}, error) {
+		l.pendingSelects--
+
+		reply, err := processFunc(replies)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := reply.(redis.Error); ok {
+			if l.pendingSelects == 0 && !l.selectQueued {
+				l.sendDB = l.currentDB
+			}
+			return reply, nil
+		}
+
+		if reply == "QUEUED" {
+			l.queuedDB = int(index)
+			l.selectQueued = true
+		} else {
+			l.currentDB = int(index)
+		}
+
+		return reply, nil
+	}
+}
+
+// trackExec wraps the ProcessFunc of slot, the slot of an EXEC command, so
+// that a SELECT queued in the transaction updates currentDB once the
+// transaction has been executed. If the transaction is aborted, sendDB is
+// reset to currentDB unless another SELECT is pending.
+func (l *LedisConn) trackExec(slot *Slot) {
+	processFunc := slot.ProcessFunc
+	slot.ProcessFunc = func(replies []interface{}) (interface{}, error) {
+		reply, err := processFunc(replies)
+		if err != nil {
+			return nil, err
+		}
+
+		if !l.selectQueued {
+			return reply, nil
+		}
+		l.selectQueued = false
+
+		if _, ok := reply.([]interface{}); ok {
+			l.currentDB = l.queuedDB
+		} else if l.pendingSelects == 0 {
+			l.sendDB = l.currentDB
+		}
+
+		return reply, nil
+	}
+}
+
+// receiveSlotRepliesAppend receives the replies of slot and appends them to
+// replies. If the slot specifies a read timeout and the underlying connection
+// supports ConnWithTimeout, the replies are received using the slot's read
+// timeout.
+func (l *LedisConn) receiveSlotRepliesAppend(slot Slot, replies []interface{}) ([]interface{}, error) {
+	conn := l.conn
+	if slot.conn != nil {
+		conn = slot.conn
+	}
+
+	if slot.ReadTimeoutSet {
+		if connWithTimeout, ok := conn.(redis.ConnWithTimeout); ok {
+			return l.receiveRepliesWithTimeoutAppend(connWithTimeout, slot.RepliesCount, slot.ReadTimeout, replies)
+		}
+	}
+
+	return l.receiveRepliesAppend(conn, slot.RepliesCount, replies)
+}
+
+func (l *LedisConn) receiveRepliesAppend(conn redis.Conn, count int, replies []interface{}) ([]interface{}, error) {
 	baseInd := len(replies)
 	replies = append(replies, make([]interface{}, count)...)
 
 	for i := 0; i < count; i++ {
-		reply, err := l.conn.Receive()
+		reply, err := conn.Receive()
 		if err != nil {
 			if _, ok := err.(redis.Error); ok {
 				if reply == nil {
@@ -335,8 +890,17 @@ func (l *LedisConn) receiveRepliesWithTimeoutAppend(
 	deadline := time.Now().Add(timeout)
 
 	for i := 0; i < count; i++ {
-		timeout := time.Now().Sub(deadline)
-		reply, err := connWithTimeout.ReceiveWithTimeout(timeout)
+		// A timeout of 0 disables the read timeout.
+		remaining := time.Duration(0)
+		if timeout != 0 {
+			remaining = time.Until(deadline)
+			if remaining <= 0 {
+				// A remaining time of 0 would disable the read timeout.
+				remaining = time.Nanosecond
+			}
+		}
+
+		reply, err := connWithTimeout.ReceiveWithTimeout(remaining)
 		if err != nil {
 			if _, ok := err.(redis.Error); ok {
 				if reply == nil {
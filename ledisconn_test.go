@@ -0,0 +1,137 @@
+package rewledis
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+func TestLedisConnReadOnlyRouting(t *testing.T) {
+	readConn := &recordingConn{}
+	rewriter := &Rewriter{}
+	rewriter.SetReadPool(&redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return readConn, nil
+		},
+	})
+	rewriter.trySetCacheEntry("key", CacheEntryStateExists, LedisTypeKV)
+
+	primaryConn := &recordingConn{}
+	conn := rewriter.WrapConn(primaryConn)
+
+	tests := []struct {
+		command string
+		args    []interface{}
+		read    bool
+	}{
+		{"GET", []interface{}{"key"}, true},
+		{"PING", nil, false},
+		{"INFO", nil, false},
+		{"ECHO", []interface{}{"message"}, false},
+		{"WATCH", []interface{}{"key"}, false},
+		{"GET", []interface{}{"key"}, false},
+		{"UNWATCH", nil, false},
+		{"GET", []interface{}{"key"}, true},
+		{"MULTI", nil, false},
+		{"GET", []interface{}{"key"}, false},
+		{"EXEC", nil, false},
+		{"GET", []interface{}{"key"}, true},
+		{"WATCH", []interface{}{"key"}, false},
+		{"MULTI", nil, false},
+		{"GET", []interface{}{"key"}, false},
+		{"EXEC", nil, false},
+		{"GET", []interface{}{"key"}, true},
+	}
+
+	for i, test := range tests {
+		primaryCount, readCount := len(primaryConn.commands), len(readConn.commands)
+
+		err := conn.Send(test.command, test.args...)
+		if err != nil {
+			t.Fatalf("%d: %s: unexpected error: %v", i, test.command, err)
+		}
+
+		sentPrimary := len(primaryConn.commands) > primaryCount
+		sentRead := len(readConn.commands) > readCount
+		if test.read && (!sentRead || sentPrimary) {
+			t.Errorf("%d: %s was not sent on the read connection only", i, test.command)
+		}
+		if !test.read && sentRead {
+			t.Errorf("%d: %s was sent on the read connection", i, test.command)
+		}
+	}
+}
+
+// replyConn is a recordingConn replying with replies, in order.
+type replyConn struct {
+	recordingConn
+	replies []interface{}
+}
+
+func (c *replyConn) Receive() (interface{}, error) {
+	reply := c.replies[0]
+	c.replies = c.replies[1:]
+	return reply, nil
+}
+
+func TestLedisConnSelectPipelined(t *testing.T) {
+	readConn := &recordingConn{}
+	rewriter := &Rewriter{}
+	rewriter.SetReadPool(&redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return readConn, nil
+		},
+	})
+
+	primaryConn := &replyConn{replies: []interface{}{"OK"}}
+	conn := rewriter.WrapConn(primaryConn)
+
+	if err := conn.Send("SELECT", 1); err != nil {
+		t.Fatalf("SELECT: unexpected error: %v", err)
+	}
+	if err := conn.Send("GET", "key"); err != nil {
+		t.Fatalf("GET: unexpected error: %v", err)
+	}
+
+	expected := [][]interface{}{{"SELECT", 1}, {"GET", "key"}}
+	if !reflect.DeepEqual(readConn.commands, expected) {
+		t.Errorf("read connection received %v, want %v", readConn.commands, expected)
+	}
+	if db := conn.CurrentDB(); db != 0 {
+		t.Errorf("CurrentDB() = %d before the SELECT reply, want 0", db)
+	}
+
+	if _, err := conn.Receive(); err != nil {
+		t.Fatalf("Receive: unexpected error: %v", err)
+	}
+	if db := conn.CurrentDB(); db != 1 {
+		t.Errorf("CurrentDB() = %d after the SELECT reply, want 1", db)
+	}
+}
+
+func TestLedisConnSelectQueued(t *testing.T) {
+	primaryConn := &replyConn{replies: []interface{}{"QUEUED"}}
+	conn := (&Rewriter{}).WrapConn(primaryConn)
+
+	for _, command := range []string{"MULTI", "SELECT", "EXEC"} {
+		var args []interface{}
+		if command == "SELECT" {
+			args = []interface{}{2}
+		}
+
+		if err := conn.Send(command, args...); err != nil {
+			t.Fatalf("%s: unexpected error: %v", command, err)
+		}
+		if _, err := conn.Receive(); err != nil {
+			t.Fatalf("%s: Receive: unexpected error: %v", command, err)
+		}
+		if db := conn.CurrentDB(); db != 0 {
+			t.Errorf("CurrentDB() = %d after %s, want 0", db, command)
+		}
+	}
+
+	if db := conn.sendDB; db != 0 {
+		t.Errorf("sendDB = %d after the transaction was aborted, want 0", db)
+	}
+}
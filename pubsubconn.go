@@ -0,0 +1,135 @@
+package rewledis
+
+import (
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// PubSubConn wraps the underlying connection of a LedisConn and provides
+// methods for subscribing to channels. It mirrors redigo's redis.PubSubConn.
+//
+// Pub/sub commands are passed on to the LedisDB server directly, they are not
+// rewritten. The LedisConn passed to NewPubSubConn must not be used while the
+// PubSubConn is in use.
+type PubSubConn struct {
+	conn *LedisConn
+	psc  redis.PubSubConn
+}
+
+// NewPubSubConn constructs a PubSubConn using the underlying connection of
+// conn. conn should not have any pending replies.
+func NewPubSubConn(conn *LedisConn) *PubSubConn {
+	return &PubSubConn{
+		conn: conn,
+		psc: redis.PubSubConn{
+			Conn: conn.RawConn(),
+		},
+	}
+}
+
+// Close closes the connection.
+func (p *PubSubConn) Close() error {
+	return p.conn.Close()
+}
+
+// Subscribe subscribes the connection to the specified channels.
+//
+// The wrapped LedisConn enters subscription mode, no further non pub/sub
+// commands may be issued on it until all subscriptions have been removed.
+func (p *PubSubConn) Subscribe(channel ...interface{}) error {
+	p.enterSubscriptionMode()
+
+	p.conn.writeMutex.Lock()
+	defer p.conn.writeMutex.Unlock()
+
+	return p.psc.Subscribe(channel...)
+}
+
+// PSubscribe subscribes the connection to the given patterns.
+//
+// The wrapped LedisConn enters subscription mode, see Subscribe.
+func (p *PubSubConn) PSubscribe(channel ...interface{}) error {
+	p.enterSubscriptionMode()
+
+	p.conn.writeMutex.Lock()
+	defer p.conn.writeMutex.Unlock()
+
+	return p.psc.PSubscribe(channel...)
+}
+
+// Unsubscribe unsubscribes the connection from the given channels, or from
+// all of them if none is given.
+func (p *PubSubConn) Unsubscribe(channel ...interface{}) error {
+	p.conn.writeMutex.Lock()
+	defer p.conn.writeMutex.Unlock()
+
+	return p.psc.Unsubscribe(channel...)
+}
+
+// PUnsubscribe unsubscribes the connection from the given patterns, or from
+// all of them if none is given.
+func (p *PubSubConn) PUnsubscribe(channel ...interface{}) error {
+	p.conn.writeMutex.Lock()
+	defer p.conn.writeMutex.Unlock()
+
+	return p.psc.PUnsubscribe(channel...)
+}
+
+// Ping sends a PING to the server with the specified data.
+func (p *PubSubConn) Ping(data string) error {
+	p.conn.writeMutex.Lock()
+	defer p.conn.writeMutex.Unlock()
+
+	return p.psc.Ping(data)
+}
+
+// Receive returns a pushed message as a redis.Subscription, redis.Message,
+// redis.Pong or error. The return value is intended to be used directly in a
+// type switch, see redis.PubSubConn.Receive.
+func (p *PubSubConn) Receive() interface{} {
+	return p.trackSubscriptionMode(p.psc.Receive())
+}
+
+// ReceiveWithTimeout is like Receive, but it allows the application to
+// override the connection's default timeout.
+func (p *PubSubConn) ReceiveWithTimeout(timeout time.Duration) interface{} {
+	return p.trackSubscriptionMode(p.psc.ReceiveWithTimeout(timeout))
+}
+
+// enterSubscriptionMode puts the wrapped LedisConn into subscription mode.
+func (p *PubSubConn) enterSubscriptionMode() {
+	p.conn.subscriptionMutex.Lock()
+	defer p.conn.subscriptionMutex.Unlock()
+
+	p.conn.subscriptionMode = true
+}
+
+// trackSubscriptionMode updates the subscription counts of the wrapped
+// LedisConn and leaves subscription mode once the server reports that no
+// subscriptions remain. received is returned unchanged.
+func (p *PubSubConn) trackSubscriptionMode(received interface{}) interface{} {
+	if subscription, ok := received.(redis.Subscription); ok {
+		p.conn.subscriptionMutex.Lock()
+		defer p.conn.subscriptionMutex.Unlock()
+
+		switch subscription.Kind {
+		case "subscribe":
+			p.conn.subscriptions++
+		case "unsubscribe":
+			p.conn.subscriptions--
+		case "psubscribe":
+			p.conn.patternSubscriptions++
+		case "punsubscribe":
+			p.conn.patternSubscriptions--
+		}
+
+		if subscription.Count == 0 {
+			p.conn.subscriptionMode = false
+			p.conn.subscriptions = 0
+			p.conn.patternSubscriptions = 0
+		}
+	}
+
+	return received
+}
@@ -0,0 +1,145 @@
+package rewledis
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// pushConn is a redis.Conn on which Receive returns the replies pushed to
+// the replies channel. Commands sent are discarded, except for counting
+// PINGs.
+type pushConn struct {
+	replies chan interface{}
+
+	mutex sync.Mutex
+	pings int
+}
+
+func (c *pushConn) Close() error { return nil }
+func (c *pushConn) Err() error   { return nil }
+func (c *pushConn) Flush() error { return nil }
+
+func (c *pushConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	return nil, nil
+}
+
+func (c *pushConn) Send(commandName string, args ...interface{}) error {
+	if commandName == "PING" {
+		c.mutex.Lock()
+		c.pings++
+		c.mutex.Unlock()
+	}
+	return nil
+}
+
+func (c *pushConn) Pings() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.pings
+}
+
+func (c *pushConn) Receive() (interface{}, error) {
+	return <-c.replies, nil
+}
+
+func TestPubSubConnSubscriptionMode(t *testing.T) {
+	rawConn := &pushConn{replies: make(chan interface{})}
+	conn := (&Rewriter{}).WrapConn(rawConn)
+	psc := NewPubSubConn(conn)
+
+	received := make(chan interface{})
+	go func() {
+		for i := 0; i < 2; i++ {
+			received <- psc.Receive()
+		}
+	}()
+
+	err := psc.Subscribe("channel")
+	if err != nil {
+		t.Fatalf("Subscribe: unexpected error: %v", err)
+	}
+	if err := conn.checkSubscriptionMode("GET", nil); err != ErrCommandNotAllowedInSubscriptionMode {
+		t.Errorf("checkSubscriptionMode(GET) = %v after Subscribe, want %v", err, ErrCommandNotAllowedInSubscriptionMode)
+	}
+
+	rawConn.replies <- []interface{}{[]byte("subscribe"), []byte("channel"), int64(1)}
+	if _, ok := (<-received).(redis.Subscription); !ok {
+		t.Fatal("Receive did not return a redis.Subscription")
+	}
+
+	reply, err := redis.String(conn.Do("CLIENT", "LIST", "TYPE", "pubsub"))
+	if err != nil {
+		t.Fatalf("CLIENT LIST: unexpected error: %v", err)
+	}
+	if !strings.Contains(reply, " flags=P ") || !strings.Contains(reply, " sub=1 psub=0 ") {
+		t.Errorf("CLIENT LIST = %q, want a pubsub entry with one subscription", reply)
+	}
+
+	err = psc.Unsubscribe("channel")
+	if err != nil {
+		t.Fatalf("Unsubscribe: unexpected error: %v", err)
+	}
+
+	rawConn.replies <- []interface{}{[]byte("unsubscribe"), []byte("channel"), int64(0)}
+	// The subscription mode is checked while the receiving goroutine leaves
+	// it, the race detector reports unsynchronised accesses.
+	_ = conn.checkSubscriptionMode("GET", nil)
+	if _, ok := (<-received).(redis.Subscription); !ok {
+		t.Fatal("Receive did not return a redis.Subscription")
+	}
+
+	if err := conn.checkSubscriptionMode("GET", nil); err != nil {
+		t.Errorf("checkSubscriptionMode(GET) = %v after all subscriptions were removed, want nil", err)
+	}
+}
+
+func TestStartPingOnlyInSubscriptionMode(t *testing.T) {
+	rawConn := &pushConn{replies: make(chan interface{})}
+	conn := (&Rewriter{}).WrapConn(rawConn)
+	psc := NewPubSubConn(conn)
+
+	stop := conn.StartPing(time.Millisecond)
+	defer stop()
+
+	time.Sleep(20 * time.Millisecond)
+	if pings := rawConn.Pings(); pings != 0 {
+		t.Fatalf("%d PINGs sent before subscribing, want 0", pings)
+	}
+
+	err := psc.Subscribe("channel")
+	if err != nil {
+		t.Fatalf("Subscribe: unexpected error: %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for rawConn.Pings() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("no PING sent in subscription mode")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	go psc.Receive()
+	rawConn.replies <- []interface{}{[]byte("unsubscribe"), []byte("channel"), int64(0)}
+
+	// Wait for the receiving goroutine to leave subscription mode.
+	for conn.checkSubscriptionMode("GET", nil) != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("subscription mode not left")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// A PING may have been sent while leaving subscription mode.
+	time.Sleep(5 * time.Millisecond)
+	pings := rawConn.Pings()
+	time.Sleep(20 * time.Millisecond)
+	if sent := rawConn.Pings() - pings; sent != 0 {
+		t.Errorf("%d PINGs sent after leaving subscription mode, want 0", sent)
+	}
+}
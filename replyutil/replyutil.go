@@ -0,0 +1,67 @@
+// Package replyutil provides utilities for working with replies received by
+// redigo connections.
+package replyutil
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Error variables related to the normalisation of replies.
+var (
+	ErrUnexpectedReplyFormat = errors.New("reply does not have the expected format")
+)
+
+// NormalizeScanReply extracts the cursor and elements of a reply to a SCAN
+// family command. The reply must be a two element array, consisting of the
+// cursor and an array of elements. The cursor may be an integer or a string,
+// elements must be strings. Any error passed in a redis.Error reply is
+// returned.
+func NormalizeScanReply(reply interface{}) (cursor string, keys []string, err error) {
+	if replyErr, ok := reply.(redis.Error); ok {
+		err = replyErr
+		return
+	}
+
+	values, err := redis.Values(reply, nil)
+	if err != nil {
+		return
+	}
+	if len(values) != 2 {
+		err = ErrUnexpectedReplyFormat
+		return
+	}
+
+	switch typedCursor := values[0].(type) {
+	case int64:
+		cursor = strconv.FormatInt(typedCursor, 10)
+	case []byte:
+		cursor = string(typedCursor)
+	case string:
+		cursor = typedCursor
+	default:
+		err = ErrUnexpectedReplyFormat
+		return
+	}
+
+	keys, err = redis.Strings(values[1], nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// ScanReply constructs a reply to a SCAN family command in the format used
+// by Redis: A two element array consisting of the cursor and an array of
+// elements, all represented as bulk strings.
+func ScanReply(cursor string, keys []string) []interface{} {
+	elements := make([]interface{}, len(keys))
+	for i := range keys {
+		elements[i] = []byte(keys[i])
+	}
+
+	return []interface{}{[]byte(cursor), elements}
+}
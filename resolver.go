@@ -3,6 +3,7 @@ package rewledis
 import (
 	"context"
 	"errors"
+	"sync"
 
 	"github.com/gomodule/redigo/redis"
 )
@@ -19,10 +20,18 @@ type TypeInfo struct {
 }
 
 // Resolver provides functionality to resolve the type of keys while using a
-// Cache instance.
+// TypeCache instance. Keys are resolved in the LedisDB database with index DB.
+//
+// By default, the existence checks for all LedisDB types are pipelined on a
+// single connection of SubPool. If ConcurrentChecks is set, the checks for
+// each LedisDB type are instead pipelined on a separate connection and
+// performed concurrently. This reduces the latency of resolving large
+// batches of keys at the cost of using more connections.
 type Resolver struct {
-	Cache   *Cache
-	SubPool *SubPool
+	Cache            TypeCache
+	SubPool          *SubPool
+	DB               int
+	ConcurrentChecks bool
 }
 
 func (r *Resolver) ResolveOne(ctx context.Context, key string) (LedisType, error) {
@@ -46,7 +55,7 @@ func (r *Resolver) ResolveAppend(typesInfo []TypeInfo, ctx context.Context, keys
 	inputTypesInfo := typesInfo
 
 	for _, key := range keys {
-		entryData, entrySetter, exists := r.Cache.LoadOrCreateEntry(key)
+		entryData, entrySetter, exists := r.Cache.LoadOrCreateEntry(r.DB, key)
 		if exists {
 			if entryData.State == CacheEntryStateExists {
 				typesInfo = append(typesInfo, TypeInfo{
@@ -55,6 +64,12 @@ func (r *Resolver) ResolveAppend(typesInfo []TypeInfo, ctx context.Context, keys
 				})
 			} else if entryData.State == CacheEntryStateLoading {
 				entriesData = append(entriesData, entryData)
+			} else if entryData.State == CacheEntryStateDeleted {
+				// Negatively cached entry, see Cache.NegativeTTL.
+				typesInfo = append(typesInfo, TypeInfo{
+					Key:  key,
+					Type: LedisTypeNone,
+				})
 			} else {
 				// This should not occur, if the entry's state is not one of
 				// the above, Cache returns exists = false.
@@ -72,7 +87,7 @@ func (r *Resolver) ResolveAppend(typesInfo []TypeInfo, ctx context.Context, keys
 	beginIndex := len(typesInfo)
 	typesInfo = append(typesInfo, make([]TypeInfo, len(entrySetters))...)
 	for i := beginIndex; i < len(typesInfo); i++ {
-		typesInfo[i].Key = entrySetters[i].Key
+		typesInfo[i].Key = entrySetters[i-beginIndex].Key
 	}
 	err := r.activeResolve(ctx, entrySetters, typesInfo[beginIndex:])
 	if err != nil {
@@ -83,7 +98,7 @@ func (r *Resolver) ResolveAppend(typesInfo []TypeInfo, ctx context.Context, keys
 	beginIndex = len(typesInfo)
 	typesInfo = append(typesInfo, make([]TypeInfo, len(entriesData))...)
 	for i := beginIndex; i < len(typesInfo); i++ {
-		typesInfo[i].Key = entriesData[i].Key
+		typesInfo[i].Key = entriesData[i-beginIndex].Key
 	}
 	err = r.waitResolve(ctx, entriesData, typesInfo[beginIndex:])
 	if err != nil {
@@ -93,6 +108,10 @@ func (r *Resolver) ResolveAppend(typesInfo []TypeInfo, ctx context.Context, keys
 	return typesInfo, nil
 }
 
+// activeResolve resolves the types of the keys in typesInfo and fulfils the
+// corresponding entrySetters. The existence checks for all LedisDB types are
+// pipelined on a single connection of the SubPool, unless ConcurrentChecks is
+// set, see checkTypesConcurrently.
 func (r *Resolver) activeResolve(ctx context.Context, entrySetters []CacheEntrySetter, typesInfo []TypeInfo) error {
 	ledisTypes := [...]LedisType{
 		LedisTypeKV,
@@ -102,35 +121,30 @@ func (r *Resolver) activeResolve(ctx context.Context, entrySetters []CacheEntryS
 		LedisTypeZSet,
 	}
 
-	noneTypesInfo := typesInfo
-	noneEntrySetters := entrySetters
-
-	for _, ledisType := range ledisTypes {
-		err := r.checkType(ctx, ledisType, noneTypesInfo)
-		if err != nil {
-			for i := range noneEntrySetters {
-				noneEntrySetters[i].Set(CacheEntryStateError, LedisTypeNone)
-			}
-
-			return err
-		}
-
-		noneBegin := r.sortApartCoSortEntrySetters(noneTypesInfo, noneEntrySetters)
+	if len(typesInfo) == 0 {
+		return nil
+	}
 
-		for i := 0; i < noneBegin; i++ {
-			noneEntrySetters[i].Set(CacheEntryStateExists, noneTypesInfo[i].Type)
+	var err error
+	if r.ConcurrentChecks {
+		err = r.checkTypesConcurrently(ctx, ledisTypes[:], typesInfo)
+	} else {
+		err = r.checkTypes(ctx, ledisTypes[:], typesInfo)
+	}
+	if err != nil {
+		for i := range entrySetters {
+			entrySetters[i].Set(CacheEntryStateError, LedisTypeNone)
 		}
 
-		noneTypesInfo = noneTypesInfo[noneBegin:]
-		noneEntrySetters = noneEntrySetters[noneBegin:]
-
-		if len(noneTypesInfo) == 0 {
-			return nil
-		}
+		return err
 	}
 
-	for i := range noneEntrySetters {
-		noneEntrySetters[i].Set(CacheEntryStateDeleted, LedisTypeNone)
+	for i := range typesInfo {
+		if typesInfo[i].Type != LedisTypeNone {
+			entrySetters[i].Set(CacheEntryStateExists, typesInfo[i].Type)
+		} else {
+			entrySetters[i].Set(CacheEntryStateDeleted, LedisTypeNone)
+		}
 	}
 
 	return nil
@@ -214,23 +228,29 @@ func (r *Resolver) sortApartCoSortEntrySetters(typesInfo []TypeInfo, entrySetter
 	return noneBegin
 }
 
-func (r *Resolver) checkType(ctx context.Context, checkType LedisType, typesInfo []TypeInfo) error {
-	conn, err := r.SubPool.getRaw(ctx)
+// checkTypes checks the existence of all keys in typesInfo for each of
+// checkTypes. All commands are sent before any reply is read, using a single
+// connection. The Type field of each element of typesInfo is set to the first
+// type in checkTypes for which the key exists.
+func (r *Resolver) checkTypes(ctx context.Context, checkTypes []LedisType, typesInfo []TypeInfo) error {
+	conn, err := r.SubPool.getRawDB(ctx, r.DB)
 	if err != nil {
 		return err
 	}
 	defer conn.Close()
 
-	command, err := r.existsCommandForType(checkType)
-	if err != nil {
-		return err
-	}
-
-	for i := range typesInfo {
-		err = conn.Send(command, typesInfo[i].Key)
+	for _, checkType := range checkTypes {
+		command, err := r.existsCommandForType(checkType)
 		if err != nil {
 			return err
 		}
+
+		for i := range typesInfo {
+			err = conn.Send(command, typesInfo[i].Key)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	err = conn.Flush()
@@ -238,13 +258,58 @@ func (r *Resolver) checkType(ctx context.Context, checkType LedisType, typesInfo
 		return err
 	}
 
-	for i := range typesInfo {
-		existsCount, err := redis.Int(conn.Receive())
-		if err != nil {
-			return err
+	for _, checkType := range checkTypes {
+		for i := range typesInfo {
+			existsCount, err := redis.Int(conn.Receive())
+			if err != nil {
+				return err
+			}
+			if existsCount == 1 && typesInfo[i].Type == LedisTypeNone {
+				typesInfo[i].Type = checkType
+			}
 		}
-		if existsCount == 1 {
-			typesInfo[i].Type = checkType
+	}
+
+	return nil
+}
+
+// checkTypesConcurrently performs the same checks as checkTypes. The checks
+// for each of checkTypes are performed by a separate goroutine on a separate
+// connection.
+func (r *Resolver) checkTypesConcurrently(ctx context.Context, checkTypes []LedisType, typesInfo []TypeInfo) error {
+	// Each goroutine records the result of its checks in its own copy of
+	// typesInfo.
+	checkedTypesInfo := make([][]TypeInfo, len(checkTypes))
+	errs := make(chan error, len(checkTypes))
+	var wg sync.WaitGroup
+
+	for i := range checkTypes {
+		checkedTypesInfo[i] = append([]TypeInfo(nil), typesInfo...)
+
+		wg.Add(1)
+		go func(checkType []LedisType, typesInfo []TypeInfo) {
+			defer wg.Done()
+
+			err := r.checkTypes(ctx, checkType, typesInfo)
+			if err != nil {
+				errs <- err
+			}
+		}(checkTypes[i:i+1], checkedTypesInfo[i])
+	}
+
+	wg.Wait()
+	close(errs)
+
+	if err, ok := <-errs; ok {
+		return err
+	}
+
+	for i := range typesInfo {
+		for j := range checkTypes {
+			if checkedTypesInfo[j][i].Type != LedisTypeNone {
+				typesInfo[i].Type = checkedTypesInfo[j][i].Type
+				break
+			}
 		}
 	}
 
@@ -269,3 +334,31 @@ func (r *Resolver) existsCommandForType(ledisType LedisType) (string, error) {
 		return "", ErrInvalidLedisType
 	}
 }
+
+// ResolverBatch collects keys to be resolved in a single call. This allows
+// accumulating keys from multiple sources, e.g. while preparing multiple
+// commands, before resolving all of them with a minimal number of round
+// trips.
+type ResolverBatch struct {
+	resolver *Resolver
+	keys     []string
+}
+
+// Batch returns a new ResolverBatch using the resolver.
+func (r *Resolver) Batch() *ResolverBatch {
+	return &ResolverBatch{
+		resolver: r,
+	}
+}
+
+// Add adds keys to the batch.
+func (b *ResolverBatch) Add(keys ...string) {
+	b.keys = append(b.keys, keys...)
+}
+
+// Resolve resolves the types of all keys added to the batch. The order of
+// the returned TypeInfo values does not necessarily correspond to the order
+// in which the keys were added.
+func (b *ResolverBatch) Resolve(ctx context.Context) ([]TypeInfo, error) {
+	return b.resolver.ResolveAppend(make([]TypeInfo, 0, len(b.keys)), ctx, b.keys)
+}
@@ -0,0 +1,125 @@
+package rewledis
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// TestResolveAppendKeysOfWaitedEntries checks that keys waited for are
+// reported with their own key when they follow keys found in the cache.
+func TestResolveAppendKeysOfWaitedEntries(t *testing.T) {
+	cache := &Cache{}
+	cache.TrySetEntry(0, "cached", CacheEntryStateExists, LedisTypeKV)
+
+	_, setter, exists := cache.LoadOrCreateEntry(0, "loading")
+	if exists {
+		t.Fatal("LoadOrCreateEntry returned existing entry for new key")
+	}
+
+	go setter.Set(CacheEntryStateExists, LedisTypeHash)
+
+	resolver := Resolver{
+		Cache: cache,
+	}
+	typesInfo, err := resolver.ResolveAppend(nil, context.Background(), []string{"cached", "loading"})
+	if err != nil {
+		t.Fatalf("ResolveAppend: unexpected error: %v", err)
+	}
+
+	expected := []TypeInfo{
+		{Key: "cached", Type: LedisTypeKV},
+		{Key: "loading", Type: LedisTypeHash},
+	}
+	if len(typesInfo) != len(expected) {
+		t.Fatalf("ResolveAppend = %v, want %v", typesInfo, expected)
+	}
+	for i := range expected {
+		if typesInfo[i] != expected[i] {
+			t.Errorf("ResolveAppend[%d] = %v, want %v", i, typesInfo[i], expected[i])
+		}
+	}
+}
+
+// latencyConn is a redis.Conn emulating a LedisDB server with a fixed round
+// trip time and a fixed processing time per command. All keys are reported
+// as not existing.
+type latencyConn struct {
+	latency    time.Duration
+	perCommand time.Duration
+	pending    int
+}
+
+func (c *latencyConn) Close() error { return nil }
+func (c *latencyConn) Err() error   { return nil }
+
+func (c *latencyConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	if commandName == "UNSAFE" {
+		return c, nil
+	}
+
+	time.Sleep(c.latency)
+	return nil, nil
+}
+
+func (c *latencyConn) Send(commandName string, args ...interface{}) error {
+	c.pending++
+	return nil
+}
+
+func (c *latencyConn) Flush() error {
+	time.Sleep(c.latency + time.Duration(c.pending)*c.perCommand)
+	return nil
+}
+
+func (c *latencyConn) Receive() (interface{}, error) {
+	c.pending--
+	return int64(0), nil
+}
+
+// benchmarkResolveBatch resolves batches of 64 unique keys. Connections
+// emulate a round trip time of 100µs and a processing time of 5µs per
+// command.
+func benchmarkResolveBatch(b *testing.B, concurrentChecks bool) {
+	pool := &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return &latencyConn{
+				latency:    100 * time.Microsecond,
+				perCommand: 5 * time.Microsecond,
+			}, nil
+		},
+		MaxIdle: 8,
+	}
+	defer pool.Close()
+
+	keys := make([]string, 64)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for j := range keys {
+			keys[j] = strconv.Itoa(i) + ":" + strconv.Itoa(j)
+		}
+
+		resolver := Resolver{
+			Cache:            &Cache{},
+			SubPool:          &SubPool{Pool: pool},
+			ConcurrentChecks: concurrentChecks,
+		}
+		_, err := resolver.ResolveAppend(nil, context.Background(), keys)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkResolveBatchPipelined(b *testing.B) {
+	benchmarkResolveBatch(b, false)
+}
+
+func BenchmarkResolveBatchConcurrent(b *testing.B) {
+	benchmarkResolveBatch(b, true)
+}
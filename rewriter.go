@@ -1,13 +1,115 @@
 package rewledis
 
 import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
 	"github.com/gomodule/redigo/redis"
 )
 
+// ConnectionInterceptor intercepts the creation of connections by a
+// Rewriter. Intercept is passed each raw connection to a LedisDB server
+// before it is wrapped in a LedisConn. The connection returned is used in
+// place of the raw connection. This allows wrapping the raw connection, e.g.
+// for logging, tracing or rate limiting.
+type ConnectionInterceptor interface {
+	Intercept(conn redis.Conn) redis.Conn
+}
+
+// Logger receives log messages of a Rewriter, see Rewriter.SetLogger. fields
+// contains alternating keys and values.
+type Logger interface {
+	Log(msg string, fields ...interface{})
+}
+
+// RewriterDiagnostic contains information about the internal state of a
+// Rewriter, see Rewriter.Diagnostic.
+type RewriterDiagnostic struct {
+	Cache CacheStats
+	// PrimaryPool contains the statistics of the primary pool. It is the zero
+	// value if no primary pool has been set.
+	PrimaryPool redis.PoolStats
+	// ReadPool contains the statistics of the read pool. It is the zero value
+	// if no read pool has been set.
+	ReadPool redis.PoolStats
+	// InternalMaxActive is the maximum number of connections of the primary
+	// pool used for internal purposes. 0 means no limit.
+	InternalMaxActive int
+	// RegisteredCommands contains the names of the commands registered
+	// using Rewriter.RegisterCommand, ordered by name.
+	RegisteredCommands []string
+}
+
 type Rewriter struct {
-	cache           Cache
+	// ErrorHandler, if set, is called for every error reply returned by
+	// LedisDB (or the emulation) for a command issued on a LedisConn of the
+	// rewriter. command is the name of the Redis command. The error returned
+	// by ErrorHandler replaces the error reply. If nil is returned, the error
+	// is ignored and a nil reply is returned instead. Errors returned by
+	// ErrorHandler do not close the connection.
+	//
+	// This is useful for deploying against LedisDB versions not supporting
+	// certain commands.
+	ErrorHandler func(err error, command string) error
+
+	// DumpFormat is the format of serialised values passed to RESTORE. If
+	// set to DumpFormatRedis, values produced by Redis' DUMP are transcoded
+	// for LedisDB. Only values in encodings available in RDB version 6 can be
+	// transcoded. Values serialised by DUMP on a LedisConn are always in the
+	// LedisDB format. DumpFormat must be set before the rewriter is used.
+	DumpFormat DumpFormat
+
+	// Tracer, if set, records every command issued on a LedisConn of the
+	// rewriter together with the LedisDB commands it has been rewritten to.
+	// Commands issued on internal connections, e.g. for resolving the type
+	// of keys, are not recorded. Tracer must be set before the rewriter is
+	// used.
+	Tracer *CommandTracer
+
+	// shardedCache is used in place of cache if set, see SetCacheSharded.
+	cache        Cache
+	shardedCache *ShardedCache
+
 	primaryPool     *redis.Pool
+	readPool        *redis.Pool
 	internalSubPool SubPool
+	interceptor     ConnectionInterceptor
+
+	// concurrentTypeChecks is passed on to the Resolver, see
+	// SetConcurrentTypeChecks.
+	concurrentTypeChecks bool
+
+	logger               Logger
+	slowCommandThreshold time.Duration
+
+	// commands holds a map[string]*RedisCommand of all commands known to the
+	// rewriter, once a command has been registered. Until then, it is empty
+	// and commands are looked up using RedisCommandFromName. commandsMutex
+	// serialises updates through RegisterCommand.
+	commands      atomic.Value
+	commandsMutex sync.Mutex
+
+	// streamIDs holds the state of the IDs of each stream emulated by XADD,
+	// see nextStreamID. streamIDsMutex protects streamIDs.
+	streamIDs      map[lruKey]streamIDState
+	streamIDsMutex sync.Mutex
+
+	// objectRefcountSupported is valid once objectRefcountProbed is set, see
+	// supportsObjectRefcount. objectRefcountMutex protects both.
+	objectRefcountMutex     sync.Mutex
+	objectRefcountProbed    bool
+	objectRefcountSupported bool
+
+	// parent and db are set on Rewriter values scoped to a LedisDB database
+	// other than the default database, see forDB. parent holds all shared
+	// state.
+	parent *Rewriter
+	db     int
+	// conn is set on Rewriter values scoped to a LedisConn, see forConn.
+	conn *LedisConn
 }
 
 // NewPrimaryPool creates a new pool from config and uses the created pool as
@@ -21,6 +123,8 @@ type Rewriter struct {
 // The returned Pool yields wrapped connections emulating Redis semantics.
 // Commands are rewritten using this Rewriter.
 func (r *Rewriter) NewPrimaryPool(config *PoolConfig, internalMaxActive int) *redis.Pool {
+	r = r.root()
+
 	pool := &redis.Pool{
 		Dial: func() (redis.Conn, error) {
 			conn, err := config.Dial()
@@ -30,7 +134,7 @@ func (r *Rewriter) NewPrimaryPool(config *PoolConfig, internalMaxActive int) *re
 
 			return &LedisConn{
 				rewriter: r,
-				conn:     conn,
+				conn:     r.intercept(conn),
 			}, nil
 		},
 		TestOnBorrow:    config.TestOnBorrow,
@@ -57,6 +161,8 @@ func (r *Rewriter) NewPrimaryPool(config *PoolConfig, internalMaxActive int) *re
 // In order for rewriting to work properly, the primary pool of the Rewriter
 // must have been set. That means NewPrimaryPool has to have been called.
 func (r *Rewriter) NewPool(config *PoolConfig) *redis.Pool {
+	r = r.root()
+
 	pool := &redis.Pool{
 		Dial: func() (redis.Conn, error) {
 			conn, err := config.Dial()
@@ -66,7 +172,7 @@ func (r *Rewriter) NewPool(config *PoolConfig) *redis.Pool {
 
 			return &LedisConn{
 				rewriter: r,
-				conn:     conn,
+				conn:     r.intercept(conn),
 			}, nil
 		},
 		TestOnBorrow:    config.TestOnBorrow,
@@ -81,17 +187,173 @@ func (r *Rewriter) NewPool(config *PoolConfig) *redis.Pool {
 }
 
 // Resolver constructs and returns a Resolver instance using this rewriter.
+// The Resolver resolves keys in the database this rewriter is scoped to.
 func (r *Rewriter) Resolver() Resolver {
+	root := r.root()
+
 	return Resolver{
-		Cache:   &r.cache,
-		SubPool: &r.internalSubPool,
+		Cache:            root.typeCache(),
+		SubPool:          &root.internalSubPool,
+		DB:               r.db,
+		ConcurrentChecks: root.concurrentTypeChecks,
 	}
 }
 
+// PreloadKeys resolves the types of all keys and stores them in the cache.
+// This avoids the overhead of resolving keys when they are first used, e.g.
+// when a known set of keys is loaded at startup. Keys are resolved in the
+// database the rewriter is scoped to.
+func (r *Rewriter) PreloadKeys(ctx context.Context, keys []string) error {
+	resolver := r.Resolver()
+	_, err := resolver.ResolveAppend(make([]TypeInfo, 0, len(keys)), ctx, keys)
+	return err
+}
+
+// Diagnostic returns information about the internal state of the rewriter.
+// This is intended for debugging and health checks. The same information is
+// returned by the UNSAFE DIAGNOSTIC command.
+func (r *Rewriter) Diagnostic() RewriterDiagnostic {
+	root := r.root()
+
+	diagnostic := RewriterDiagnostic{
+		Cache:              root.typeCache().Stats(),
+		InternalMaxActive:  root.internalSubPool.MaxActive,
+		RegisteredCommands: root.registeredCommandNames(),
+	}
+
+	if root.primaryPool != nil {
+		diagnostic.PrimaryPool = root.primaryPool.Stats()
+	}
+	if root.readPool != nil {
+		diagnostic.ReadPool = root.readPool.Stats()
+	}
+
+	return diagnostic
+}
+
+// typeCache returns the cache of the rewriter. This is the ShardedCache, if
+// one has been selected using SetCacheSharded.
+func (r *Rewriter) typeCache() TypeCache {
+	root := r.root()
+	if root.shardedCache != nil {
+		return root.shardedCache
+	}
+
+	return &root.cache
+}
+
+// root returns the Rewriter holding the shared state of r.
+func (r *Rewriter) root() *Rewriter {
+	if r.parent != nil {
+		return r.parent
+	}
+
+	return r
+}
+
+// forDB returns a Rewriter scoped to the LedisDB database with index db.
+// Cache operations and internal connections of the returned Rewriter operate
+// on that database.
+func (r *Rewriter) forDB(db int) *Rewriter {
+	root := r.root()
+	if db == 0 {
+		return root
+	}
+
+	return &Rewriter{
+		parent: root,
+		db:     db,
+	}
+}
+
+// forConn returns a Rewriter scoped to conn and the database selected on
+// conn once all commands sent so far have been executed. Transformers access
+// the state of conn through the returned Rewriter.
+func (r *Rewriter) forConn(conn *LedisConn) *Rewriter {
+	return &Rewriter{
+		parent: r.root(),
+		db:     conn.sendDB,
+		conn:   conn,
+	}
+}
+
+// loadCachedType looks up the type of key in the cache of the database this
+// rewriter is scoped to.
+func (r *Rewriter) loadCachedType(key string) (LedisType, bool) {
+	return r.root().typeCache().LoadType(r.db, key)
+}
+
+// trySetCacheEntry updates the cache entry of key in the database this
+// rewriter is scoped to. See Cache.TrySetEntry.
+func (r *Rewriter) trySetCacheEntry(key string, state CacheEntryState, keyType LedisType) bool {
+	return r.root().typeCache().TrySetEntry(r.db, key, state, keyType)
+}
+
+// loadCacheVersion returns the version of the cache entry of key in the
+// database this rewriter is scoped to. See Cache.LoadVersion.
+func (r *Rewriter) loadCacheVersion(key string) uint64 {
+	return r.root().typeCache().LoadVersion(r.db, key)
+}
+
+// setCacheEntryIfVersion updates the cache entry of key in the database this
+// rewriter is scoped to if its version matches. See Cache.SetIfVersion.
+func (r *Rewriter) setCacheEntryIfVersion(key string, state CacheEntryState, keyType LedisType, version uint64) bool {
+	return r.root().typeCache().SetIfVersion(r.db, key, state, keyType, version)
+}
+
+// invalidateCacheEntry removes the cache entry of key in the database this
+// rewriter is scoped to. See Cache.Invalidate.
+func (r *Rewriter) invalidateCacheEntry(key string) {
+	r.root().typeCache().Invalidate(r.db, key)
+}
+
+// getInternalConn returns a raw connection from the internal sub pool on
+// which the database this rewriter is scoped to is selected.
+func (r *Rewriter) getInternalConn(ctx context.Context) (redis.Conn, error) {
+	return r.root().internalSubPool.getRawDB(ctx, r.db)
+}
+
+// supportsObjectRefcount reports whether the LedisDB server supports the
+// OBJECT REFCOUNT command. The server is probed by issuing the command on an
+// internal connection. Once the server has replied, the result is stored on
+// the rewriter. If the probe fails, e.g. because no connection could be
+// obtained, the command is considered not supported for now and the server
+// is probed again on the next call.
+func (r *Rewriter) supportsObjectRefcount() bool {
+	root := r.root()
+
+	root.objectRefcountMutex.Lock()
+	defer root.objectRefcountMutex.Unlock()
+
+	if root.objectRefcountProbed {
+		return root.objectRefcountSupported
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	conn, err := root.getInternalConn(ctx)
+	cancel()
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+
+	_, err = conn.Do("OBJECT", "REFCOUNT", "rewledis:probe")
+	if _, ok := err.(redis.Error); err != nil && !ok {
+		return false
+	}
+
+	root.objectRefcountProbed = true
+	root.objectRefcountSupported = err == nil
+
+	return root.objectRefcountSupported
+}
+
 // WrapConn wraps a connection to a LedisDB server and returns a connection
 // emulating Redis semantics.
 // All commands issued on the returned connection are rewritten using this rewriter.
 func (r *Rewriter) WrapConn(conn redis.Conn) *LedisConn {
+	r = r.root()
+
 	if ledisConn, ok := conn.(*LedisConn); ok {
 		return &LedisConn{
 			rewriter: r,
@@ -101,13 +363,235 @@ func (r *Rewriter) WrapConn(conn redis.Conn) *LedisConn {
 
 	return &LedisConn{
 		rewriter: r,
-		conn:     conn,
+		conn:     r.intercept(conn),
+	}
+}
+
+// SetConnectionInterceptor sets the ConnectionInterceptor of the rewriter.
+// All raw connections wrapped by the rewriter afterwards, i.e. connections
+// dialled by pools created by the rewriter and raw connections passed to
+// WrapConn, are passed through interceptor. The connection underlying a
+// LedisConn passed to WrapConn is not intercepted again. A nil interceptor
+// disables interception.
+func (r *Rewriter) SetConnectionInterceptor(interceptor ConnectionInterceptor) {
+	r.root().interceptor = interceptor
+}
+
+// SetReadPool sets the pool from which LedisConns of the rewriter obtain a
+// read connection. Commands marked as ReadOnly are sent on the read
+// connection instead of the connection wrapped by the LedisConn, e.g. for
+// routing reads to a LedisDB replica. pool must yield raw connections to a
+// LedisDB server. A nil pool disables read/write splitting.
+//
+// Commands issued while a transaction is active, i.e. after MULTI or WATCH
+// until EXEC, DISCARD or UNWATCH, are always sent on the primary connection.
+// Reads on a replica may not observe preceding writes on the primary
+// connection. The type cache is updated from replies on both connections.
+// SetReadPool must be called before the rewriter is used.
+func (r *Rewriter) SetReadPool(pool *redis.Pool) {
+	r.root().readPool = pool
+}
+
+// SetLogger sets the logger of the rewriter. At the moment, only slow
+// command transformations (see SetSlowCommandThreshold) and CLIENT KILL
+// commands, which have no effect, are logged. SetLogger must be called
+// before the rewriter is used.
+func (r *Rewriter) SetLogger(logger Logger) {
+	r.root().logger = logger
+}
+
+// SetSlowCommandThreshold enables logging of slow command transformations.
+// Whenever executing the TransformFunc of a command issued on a LedisConn
+// takes longer than threshold, a message is logged using the rewriter's
+// logger, see SetLogger. This includes resolving the types of keys, but not
+// the round trip to LedisDB. This is similar to Redis' SLOWLOG, but limited
+// to rewriting. A threshold of 0 disables logging. SetSlowCommandThreshold
+// must be called before the rewriter is used.
+func (r *Rewriter) SetSlowCommandThreshold(threshold time.Duration) {
+	r.root().slowCommandThreshold = threshold
+}
+
+// SetConcurrentTypeChecks sets whether the types of keys are resolved by
+// checking each LedisDB type concurrently on a separate internal connection,
+// see Resolver.ConcurrentChecks. SetConcurrentTypeChecks must be called
+// before the rewriter is used.
+func (r *Rewriter) SetConcurrentTypeChecks(concurrent bool) {
+	r.root().concurrentTypeChecks = concurrent
+}
+
+// SetCacheSharded selects the implementation of the rewriter's cache. If
+// sharded is true, a ShardedCache is used instead of a Cache. This reduces
+// contention on the cache for highly concurrent workloads. The options set
+// using SetCacheMaxEntries, SetCacheLoadingTimeout and SetCacheNegativeTTL
+// apply to either implementation. SetCacheSharded must be called before the
+// rewriter is used.
+func (r *Rewriter) SetCacheSharded(sharded bool) {
+	root := r.root()
+
+	if !sharded {
+		root.shardedCache = nil
+		return
 	}
+
+	root.shardedCache = &ShardedCache{
+		LoadingTimeout: root.cache.LoadingTimeout,
+		NegativeTTL:    root.cache.NegativeTTL,
+		MaxEntries:     root.cache.MaxEntries,
+	}
+}
+
+// SetCacheMaxEntries sets the maximum number of entries of the rewriter's
+// cache, see Cache.MaxEntries and ShardedCache.MaxEntries.
+// SetCacheMaxEntries must be called before the rewriter is used.
+func (r *Rewriter) SetCacheMaxEntries(maxEntries int) {
+	root := r.root()
+
+	root.cache.MaxEntries = maxEntries
+	if root.shardedCache != nil {
+		root.shardedCache.MaxEntries = maxEntries
+	}
+}
+
+// SetCacheLoadingTimeout sets the loading timeout of the rewriter's cache,
+// see Cache.LoadingTimeout. SetCacheLoadingTimeout must be called before the
+// rewriter is used.
+func (r *Rewriter) SetCacheLoadingTimeout(timeout time.Duration) {
+	root := r.root()
+
+	root.cache.LoadingTimeout = timeout
+	if root.shardedCache != nil {
+		root.shardedCache.LoadingTimeout = timeout
+	}
+}
+
+// SetCacheNegativeTTL sets the negative TTL of the rewriter's cache, see
+// Cache.NegativeTTL. SetCacheNegativeTTL must be called before the rewriter
+// is used.
+func (r *Rewriter) SetCacheNegativeTTL(ttl time.Duration) {
+	root := r.root()
+
+	root.cache.NegativeTTL = ttl
+	if root.shardedCache != nil {
+		root.shardedCache.NegativeTTL = ttl
+	}
+}
+
+// log logs msg using the logger of the rewriter, if one is set.
+func (r *Rewriter) log(msg string, fields ...interface{}) {
+	logger := r.root().logger
+	if logger == nil {
+		return
+	}
+
+	logger.Log(msg, fields...)
+}
+
+// intercept passes conn through the ConnectionInterceptor of the rewriter,
+// if one is set.
+func (r *Rewriter) intercept(conn redis.Conn) redis.Conn {
+	interceptor := r.root().interceptor
+	if interceptor == nil {
+		return conn
+	}
+
+	return interceptor.Intercept(conn)
+}
+
+// RegisterCommand adds command to the commands known to the rewriter. The
+// command is looked up by its Name, case-insensitively. A command registered
+// with the name of an existing command replaces that command for this
+// rewriter. RegisterCommand is safe for concurrent use with the rewriter.
+func (r *Rewriter) RegisterCommand(command *RedisCommand) {
+	root := r.root()
+
+	root.commandsMutex.Lock()
+	defer root.commandsMutex.Unlock()
+
+	current, _ := root.commands.Load().(map[string]*RedisCommand)
+
+	commands := make(map[string]*RedisCommand, len(redisCommands)+len(redisCommandAliases)+1)
+	if current == nil {
+		redisCommandsByName.Range(func(name, redisCommand interface{}) bool {
+			commands[name.(string)] = redisCommand.(*RedisCommand)
+			return true
+		})
+	} else {
+		for name, redisCommand := range current {
+			commands[name] = redisCommand
+		}
+	}
+	commands[canonicalCommandName(command.Name)] = command
+
+	root.commands.Store(commands)
+}
+
+// registeredCommandNames returns the names of all commands registered using
+// RegisterCommand, ordered by name. A command is reported if it replaces a
+// command of the package or if its name is not known to the package.
+func (r *Rewriter) registeredCommandNames() []string {
+	commands, _ := r.root().commands.Load().(map[string]*RedisCommand)
+
+	var names []string
+	for name, command := range commands {
+		defaultCommand, ok := redisCommandsByName.Load(name)
+		if !ok || defaultCommand.(*RedisCommand) != command {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// lookupCommand returns the RedisCommand with the name commandName, taking
+// commands registered with the rewriter into account.
+func (r *Rewriter) lookupCommand(commandName string) (*RedisCommand, error) {
+	commands, _ := r.root().commands.Load().(map[string]*RedisCommand)
+	if commands == nil {
+		return RedisCommandFromName(commandName)
+	}
+
+	command, ok := commands[canonicalCommandName(commandName)]
+	if !ok {
+		return nil, ErrUnknownRedisCommandName
+	}
+
+	return command, nil
+}
+
+// knownCommands returns all commands known to the rewriter, taking commands
+// registered with the rewriter into account. Aliases are omitted. The
+// commands are ordered by name.
+func (r *Rewriter) knownCommands() []*RedisCommand {
+	var known []*RedisCommand
+
+	commands, _ := r.root().commands.Load().(map[string]*RedisCommand)
+	if commands == nil {
+		redisCommandsByName.Range(func(name, command interface{}) bool {
+			if command.(*RedisCommand).Name == name.(string) {
+				known = append(known, command.(*RedisCommand))
+			}
+			return true
+		})
+	} else {
+		for name, command := range commands {
+			if canonicalCommandName(command.Name) == name {
+				known = append(known, command)
+			}
+		}
+	}
+
+	sort.Slice(known, func(i, j int) bool {
+		return known[i].Name < known[j].Name
+	})
+
+	return known
 }
 
 // Rewrite applies transformations for a single supplied command invocation.
 func (r *Rewriter) Rewrite(commandName string, args ...interface{}) (SendLedisFunc, error) {
-	command, err := RedisCommandFromName(commandName)
+	command, err := r.lookupCommand(commandName)
 	if err != nil {
 		return nil, err
 	}
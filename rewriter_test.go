@@ -0,0 +1,88 @@
+package rewledis
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+func TestSupportsObjectRefcountRetriesFailedProbe(t *testing.T) {
+	rewriter := &Rewriter{}
+
+	dialErr := errors.New("dial failed")
+	rewriter.internalSubPool.Pool = &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			if dialErr != nil {
+				return nil, dialErr
+			}
+
+			return rewriter.WrapConn(&recordingConn{}), nil
+		},
+	}
+
+	if rewriter.supportsObjectRefcount() {
+		t.Fatal("supportsObjectRefcount = true without a connection")
+	}
+
+	dialErr = nil
+
+	if !rewriter.supportsObjectRefcount() {
+		t.Error("supportsObjectRefcount = false after the server became reachable")
+	}
+}
+
+func TestRegisterCommandKeepsKnownCommands(t *testing.T) {
+	rewriter := &Rewriter{}
+
+	command := RedisCommandHGETALL
+	rewriter.RegisterCommand(&command)
+
+	tests := []struct {
+		name    string
+		command *RedisCommand
+	}{
+		{"HGETALL", &command},
+		{"hgetall", &command},
+		{"GET", &RedisCommandGET},
+		{"GETRANGE", &RedisCommandGETRANGE},
+		{"SUBSTR", &RedisCommandGETRANGE},
+		{"substr", &RedisCommandGETRANGE},
+	}
+
+	for _, test := range tests {
+		redisCommand, err := rewriter.lookupCommand(test.name)
+		if err != nil {
+			t.Errorf("lookupCommand(%q): unexpected error: %v", test.name, err)
+			continue
+		}
+		if redisCommand != test.command {
+			t.Errorf("lookupCommand(%q) = %s, want %s", test.name, redisCommand.Name, test.command.Name)
+		}
+	}
+}
+
+func TestDiagnosticRegisteredCommands(t *testing.T) {
+	rewriter := &Rewriter{}
+
+	if commands := rewriter.Diagnostic().RegisteredCommands; len(commands) != 0 {
+		t.Errorf("RegisteredCommands = %v without registered commands, want none", commands)
+	}
+
+	hgetall := RedisCommandHGETALL
+	rewriter.RegisterCommand(&hgetall)
+	custom := RedisCommand{Name: "custom"}
+	rewriter.RegisterCommand(&custom)
+	rewriter.RegisterCommand(&RedisCommandGET)
+
+	reply, err := rewriter.WrapConn(&recordingConn{}).Do("UNSAFE", "DIAGNOSTIC")
+	if err != nil {
+		t.Fatalf("UNSAFE DIAGNOSTIC: unexpected error: %v", err)
+	}
+
+	expected := []string{"CUSTOM", "HGETALL"}
+	if commands := reply.(RewriterDiagnostic).RegisteredCommands; !reflect.DeepEqual(commands, expected) {
+		t.Errorf("RegisteredCommands = %v, want %v", commands, expected)
+	}
+}
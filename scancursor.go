@@ -0,0 +1,111 @@
+package rewledis
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// Error variables related to ScanCursorState.
+var (
+	ErrInvalidScanCursor = errors.New("invalid SCAN cursor")
+)
+
+// scanLedisTypes contains the LedisDB type names in the order in which SCAN
+// iterates over the keyspaces.
+var scanLedisTypes = [...]string{
+	"KV",
+	"LIST",
+	"HASH",
+	"SET",
+	"ZSET",
+}
+
+// ScanCursorState is the state of a SCAN iteration emulated using XSCAN.
+//
+// LedisDB maintains separate keyspaces for each type, XSCAN iterates over
+// a single keyspace. The state is a compound of the keyspace currently being
+// scanned and the LedisDB cursor within this keyspace. It is passed to
+// clients as an opaque cursor string, see Encode.
+type ScanCursorState struct {
+	// TypeIndex is the index of the keyspace currently being scanned in the
+	// fixed order KV, LIST, HASH, SET, ZSET.
+	TypeIndex int `json:"t"`
+	// Cursor is the LedisDB cursor within the current keyspace. The empty
+	// string starts the iteration of the keyspace.
+	Cursor string `json:"c"`
+}
+
+// DecodeScanCursor decodes a cursor string as produced by Encode. The cursor
+// "0" starts a new iteration and decodes to the zero ScanCursorState.
+func DecodeScanCursor(cursor string) (ScanCursorState, error) {
+	var state ScanCursorState
+
+	if cursor == "0" {
+		return state, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return state, ErrInvalidScanCursor
+	}
+
+	err = json.Unmarshal(data, &state)
+	if err != nil {
+		return state, ErrInvalidScanCursor
+	}
+
+	if !state.valid() {
+		return state, ErrInvalidScanCursor
+	}
+
+	return state, nil
+}
+
+// Encode returns the cursor string representing s. The cursor of a finished
+// iteration is "0", as in Redis.
+func (s ScanCursorState) Encode() string {
+	if s.Done() {
+		return "0"
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		// Marshalling a struct of an int and a string does not fail.
+		panic(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// LedisType returns the LedisDB type name of the keyspace currently being
+// scanned, as accepted by XSCAN.
+func (s ScanCursorState) LedisType() string {
+	return scanLedisTypes[s.TypeIndex]
+}
+
+// Advance returns the state following s, given the cursor returned by XSCAN
+// for the current keyspace. LedisDB signals the end of a keyspace by
+// returning an empty cursor, in which case the iteration moves on to the
+// next keyspace.
+func (s ScanCursorState) Advance(ledisCursor string) ScanCursorState {
+	if len(ledisCursor) > 0 {
+		return ScanCursorState{
+			TypeIndex: s.TypeIndex,
+			Cursor:    ledisCursor,
+		}
+	}
+
+	return ScanCursorState{
+		TypeIndex: s.TypeIndex + 1,
+	}
+}
+
+// Done returns true iff all keyspaces have been scanned.
+func (s ScanCursorState) Done() bool {
+	return s.TypeIndex >= len(scanLedisTypes)
+}
+
+func (s ScanCursorState) valid() bool {
+	return s.TypeIndex >= 0 && s.TypeIndex < len(scanLedisTypes)
+}
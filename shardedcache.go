@@ -0,0 +1,303 @@
+package rewledis
+
+import (
+	"sync"
+	"time"
+)
+
+// TypeCache is the interface of caches storing type information about keys.
+// It is implemented by Cache and ShardedCache.
+type TypeCache interface {
+	LoadType(db int, key string) (LedisType, bool)
+	TrySetEntry(db int, key string, state CacheEntryState, keyType LedisType) bool
+	LoadVersion(db int, key string) uint64
+	SetIfVersion(db int, key string, state CacheEntryState, keyType LedisType, version uint64) bool
+	LoadOrCreateEntry(db int, key string) (CacheEntryData, CacheEntrySetter, bool)
+	Invalidate(db int, key string)
+	Clear()
+	Stats() CacheStats
+	Snapshot(db int) map[string]CacheEntryData
+}
+
+var (
+	_ TypeCache = &Cache{}
+	_ TypeCache = &ShardedCache{}
+)
+
+// Constants related to the FNV-1a hash function (32 bit) used for sharding.
+const (
+	fnv32Offset uint32 = 2166136261
+	fnv32Prime  uint32 = 16777619
+)
+
+const shardedCacheShardsCount = 256
+
+type cacheShards [shardedCacheShardsCount]cacheEntries
+
+// ShardedCache stores type information about keys, just as Cache does. The
+// entries of each database are distributed among 256 shards based on the
+// FNV-32 hash of the key. This reduces contention on the underlying maps
+// under high concurrency.
+//
+// If MaxEntries is set, the number of entries is bounded per shard: Each
+// shard holds at most MaxEntries / 256 entries (rounded up) across all
+// databases. Once a shard holds more entries, its least recently used entry
+// is evicted. In contrast to Cache, operations on a bounded ShardedCache are
+// only serialised per shard. The total number of entries may exceed
+// MaxEntries by up to 255.
+type ShardedCache struct {
+	// versions is the version counter of all entries, see nextVersion. It is
+	// the first field to guarantee 64-bit alignment for atomic operations.
+	versions uint64
+
+	// LoadingTimeout is the maximum duration an entry stays in the Loading
+	// state, see Cache.LoadingTimeout. LoadingTimeout must not be changed
+	// once the cache is in use.
+	LoadingTimeout time.Duration
+	// NegativeTTL is the duration for which entries in the Deleted state are
+	// used, see Cache.NegativeTTL. NegativeTTL must not be changed once the
+	// cache is in use.
+	NegativeTTL time.Duration
+	// MaxEntries is the maximum number of entries stored across all
+	// databases, see above for how the bound is applied. 0 means no limit.
+	// MaxEntries must not be changed once the cache is in use.
+	MaxEntries int
+
+	// dbShards maps database indices (int) to the shards of that database
+	// (*cacheShards).
+	dbShards sync.Map
+
+	// lrus contains an LRU list for each shard index, spanning all databases.
+	// The mutex of each list is held while entries of the shards with that
+	// index are added or removed. lrus is only used if MaxEntries is set.
+	lrus [shardedCacheShardsCount]cacheLRU
+}
+
+// shard returns the shard with index index of database db.
+func (c *ShardedCache) shard(db int, index uint8) *cacheEntries {
+	shardsIntf, ok := c.dbShards.Load(db)
+	if !ok {
+		shards := &cacheShards{}
+		for i := range shards {
+			shards[i].versions = &c.versions
+			shards[i].loadingTimeout = c.LoadingTimeout
+			shards[i].negativeTTL = c.NegativeTTL
+		}
+
+		shardsIntf, _ = c.dbShards.LoadOrStore(db, shards)
+	}
+
+	shards := shardsIntf.(*cacheShards)
+
+	return &shards[index]
+}
+
+// shardIndex returns the index of the shard for key. The index is the first
+// (most significant) byte of the FNV-1a hash of key.
+func shardIndex(key string) uint8 {
+	hash := fnv32Offset
+	for i := 0; i < len(key); i++ {
+		hash ^= uint32(key[i])
+		hash *= fnv32Prime
+	}
+
+	return uint8(hash >> 24)
+}
+
+// shardMaxEntries returns the maximum number of entries of each shard.
+func (c *ShardedCache) shardMaxEntries() int {
+	return (c.MaxEntries + shardedCacheShardsCount - 1) / shardedCacheShardsCount
+}
+
+// lockLRU locks the LRU list of the shards with index index, if the cache is
+// bounded.
+func (c *ShardedCache) lockLRU(index uint8) {
+	if c.MaxEntries > 0 {
+		c.lrus[index].Mutex.Lock()
+	}
+}
+
+// unlockLRU unlocks the LRU list of the shards with index index, if the
+// cache is bounded.
+func (c *ShardedCache) unlockLRU(index uint8) {
+	if c.MaxEntries > 0 {
+		c.lrus[index].Mutex.Unlock()
+	}
+}
+
+// touch marks the entry for key in database db as most recently used and
+// evicts entries from the shards with index index, if necessary. The caller
+// must hold the LRU lock of index, see lockLRU.
+func (c *ShardedCache) touch(index uint8, db int, key string) {
+	if c.MaxEntries <= 0 {
+		return
+	}
+
+	lru := &c.lrus[index]
+	lru.Touch(lruKey{DB: db, Key: key})
+
+	for {
+		evictedKey, ok := lru.Evict(c.shardMaxEntries())
+		if !ok {
+			break
+		}
+
+		c.shard(evictedKey.DB, index).Invalidate(evictedKey.Key)
+	}
+}
+
+// forget removes the entry for key in database db from the LRU list of the
+// shards with index index. The caller must hold the LRU lock of index, see
+// lockLRU.
+func (c *ShardedCache) forget(index uint8, db int, key string) {
+	if c.MaxEntries <= 0 {
+		return
+	}
+
+	c.lrus[index].Forget(lruKey{DB: db, Key: key})
+}
+
+// LoadType returns the type of key in database db, if the entry of key is in
+// the Exists state. See Cache.LoadType.
+func (c *ShardedCache) LoadType(db int, key string) (LedisType, bool) {
+	index := shardIndex(key)
+
+	c.lockLRU(index)
+	defer c.unlockLRU(index)
+
+	keyType, ok := c.shard(db, index).LoadType(key)
+	if ok {
+		c.touch(index, db, key)
+	}
+
+	return keyType, ok
+}
+
+// TrySetEntry tries to update the entry for the given key in database db.
+// See Cache.TrySetEntry.
+func (c *ShardedCache) TrySetEntry(db int, key string, state CacheEntryState, keyType LedisType) bool {
+	index := shardIndex(key)
+
+	c.lockLRU(index)
+	defer c.unlockLRU(index)
+
+	ok := c.shard(db, index).TrySetEntry(key, state, keyType)
+	if ok {
+		c.touch(index, db, key)
+	}
+
+	return ok
+}
+
+// LoadVersion returns the current version of the entry for the given key in
+// database db. See Cache.LoadVersion.
+func (c *ShardedCache) LoadVersion(db int, key string) uint64 {
+	return c.shard(db, shardIndex(key)).LoadVersion(key)
+}
+
+// SetIfVersion updates the entry for the given key in database db if its
+// version equals version. See Cache.SetIfVersion.
+func (c *ShardedCache) SetIfVersion(db int, key string, state CacheEntryState, keyType LedisType, version uint64) bool {
+	index := shardIndex(key)
+
+	c.lockLRU(index)
+	defer c.unlockLRU(index)
+
+	ok := c.shard(db, index).SetIfVersion(key, state, keyType, version)
+	if ok {
+		c.touch(index, db, key)
+	}
+
+	return ok
+}
+
+// LoadOrCreateEntry returns either entry data or a setter for the given key
+// in database db. See Cache.LoadOrCreateEntry.
+func (c *ShardedCache) LoadOrCreateEntry(db int, key string) (CacheEntryData, CacheEntrySetter, bool) {
+	index := shardIndex(key)
+
+	c.lockLRU(index)
+	defer c.unlockLRU(index)
+
+	entryData, entrySetter, exists := c.shard(db, index).LoadOrCreateEntry(key)
+	c.touch(index, db, key)
+
+	return entryData, entrySetter, exists
+}
+
+// Invalidate removes the entry for the given key in database db. See
+// Cache.Invalidate.
+func (c *ShardedCache) Invalidate(db int, key string) {
+	index := shardIndex(key)
+
+	c.lockLRU(index)
+	defer c.unlockLRU(index)
+
+	c.shard(db, index).Invalidate(key)
+	c.forget(index, db, key)
+}
+
+// Clear removes all entries of all databases from the cache. See
+// Cache.Clear.
+func (c *ShardedCache) Clear() {
+	for i := range c.lrus {
+		c.lockLRU(uint8(i))
+	}
+	defer func() {
+		for i := range c.lrus {
+			c.unlockLRU(uint8(i))
+		}
+	}()
+
+	c.dbShards.Range(func(db, _ interface{}) bool {
+		c.dbShards.Delete(db)
+		return true
+	})
+
+	if c.MaxEntries > 0 {
+		for i := range c.lrus {
+			c.lrus[i].Clear()
+		}
+	}
+}
+
+// Stats collects statistics about the entries of the cache. See
+// Cache.Stats.
+func (c *ShardedCache) Stats() CacheStats {
+	stats := CacheStats{
+		EntriesByState: make(map[CacheEntryState]int),
+	}
+
+	c.dbShards.Range(func(_, shardsIntf interface{}) bool {
+		stats.Databases++
+
+		shards := shardsIntf.(*cacheShards)
+		for i := range shards {
+			shards[i].addStats(&stats)
+		}
+
+		return true
+	})
+
+	return stats
+}
+
+// Snapshot returns a copy of all entries of database db. See
+// Cache.Snapshot.
+func (c *ShardedCache) Snapshot(db int) map[string]CacheEntryData {
+	snapshot := make(map[string]CacheEntryData)
+
+	shardsIntf, ok := c.dbShards.Load(db)
+	if !ok {
+		return snapshot
+	}
+
+	shards := shardsIntf.(*cacheShards)
+	for i := range shards {
+		for key, entryData := range shards[i].Snapshot() {
+			snapshot[key] = entryData
+		}
+	}
+
+	return snapshot
+}
@@ -0,0 +1,92 @@
+package rewledis
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShardedCacheOptions(t *testing.T) {
+	cache := &ShardedCache{
+		NegativeTTL: time.Minute,
+		MaxEntries:  shardedCacheShardsCount,
+	}
+
+	cache.TrySetEntry(0, "missing", CacheEntryStateDeleted, LedisTypeNone)
+	entryData, _, exists := cache.LoadOrCreateEntry(0, "missing")
+	if !exists || entryData.State != CacheEntryStateDeleted {
+		t.Errorf("LoadOrCreateEntry of negatively cached key = %v, %t, want Deleted entry", entryData.State, exists)
+	}
+
+	for i := 0; i < 10*shardedCacheShardsCount; i++ {
+		cache.TrySetEntry(0, strconv.Itoa(i), CacheEntryStateExists, LedisTypeKV)
+	}
+	if entries := cache.Stats().Entries; entries > 2*shardedCacheShardsCount-1 {
+		t.Errorf("cache holds %d entries, want at most %d", entries, 2*shardedCacheShardsCount-1)
+	}
+
+	cache = &ShardedCache{
+		LoadingTimeout: time.Millisecond,
+	}
+
+	_, setter, _ := cache.LoadOrCreateEntry(0, "key")
+	entryData, _, _ = cache.LoadOrCreateEntry(0, "key")
+	select {
+	case <-entryData.DoneLoading:
+	case <-time.After(time.Second):
+		t.Fatal("loading was not aborted after the loading timeout")
+	}
+	entryData.Refresh()
+	if entryData.State != CacheEntryStateError {
+		t.Errorf("state after loading timeout = %v, want %v", entryData.State, CacheEntryStateError)
+	}
+	setter.Set(CacheEntryStateExists, LedisTypeKV)
+}
+
+// benchmarkTypeCacheContention performs b.N cache lookups distributed among
+// 100 goroutines. The lookups cover 10,000 unique keys, missing keys are
+// filled in.
+func benchmarkTypeCacheContention(b *testing.B, cache TypeCache) {
+	const (
+		goroutines = 100
+		keysCount  = 10000
+	)
+
+	keys := make([]string, keysCount)
+	for i := range keys {
+		keys[i] = "key:" + strconv.Itoa(i)
+	}
+
+	b.ResetTimer()
+
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+
+			for i := g; i < b.N; i += goroutines {
+				key := keys[(i*7919)%keysCount]
+
+				if _, ok := cache.LoadType(0, key); ok {
+					continue
+				}
+
+				_, setter, exists := cache.LoadOrCreateEntry(0, key)
+				if !exists {
+					setter.Set(CacheEntryStateExists, LedisTypeKV)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func BenchmarkCacheContention(b *testing.B) {
+	benchmarkTypeCacheContention(b, &Cache{})
+}
+
+func BenchmarkShardedCacheContention(b *testing.B) {
+	benchmarkTypeCacheContention(b, &ShardedCache{})
+}
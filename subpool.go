@@ -165,3 +165,48 @@ func (s *SubPool) getRaw(ctx context.Context) (redis.Conn, error) {
 		closer: poolConn,
 	}, nil
 }
+
+// dbConn is a connection on which a database other than the default database
+// has been selected. The default database is selected again when the
+// connection is closed.
+type dbConn struct {
+	redis.Conn
+}
+
+func (d *dbConn) Close() error {
+	if d.Conn == nil {
+		return nil
+	}
+
+	_, err := d.Conn.Do("SELECT", 0)
+	closeErr := d.Conn.Close()
+	d.Conn = nil
+	if err != nil {
+		return err
+	}
+
+	return closeErr
+}
+
+// getRawDB returns a raw, unwrapped connection from the sub pool on which the
+// database with index db is selected. See getRaw.
+func (s *SubPool) getRawDB(ctx context.Context, db int) (redis.Conn, error) {
+	conn, err := s.getRaw(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if db == 0 {
+		return conn, nil
+	}
+
+	_, err = conn.Do("SELECT", db)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &dbConn{
+		Conn: conn,
+	}, nil
+}
@@ -0,0 +1,45 @@
+package rewledis
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// TestSubPoolGetRaw checks that getRaw exposes the raw connection underlying
+// the LedisConns of the pool, on which commands such as XDUMP are sent
+// without rewriting.
+func TestSubPoolGetRaw(t *testing.T) {
+	rewriter := &Rewriter{}
+	rawConn := &recordingConn{}
+
+	subPool := &SubPool{
+		Pool: &redis.Pool{
+			Dial: func() (redis.Conn, error) {
+				return rewriter.WrapConn(rawConn), nil
+			},
+		},
+	}
+
+	conn, err := subPool.getRaw(context.Background())
+	if err != nil {
+		t.Fatalf("getRaw: unexpected error: %v", err)
+	}
+	defer conn.Close()
+
+	if _, ok := conn.(*LedisConn); ok {
+		t.Fatal("getRaw returned a LedisConn")
+	}
+
+	err = conn.Send("XDUMP", "key")
+	if err != nil {
+		t.Fatalf("Send: unexpected error: %v", err)
+	}
+
+	expected := [][]interface{}{{"XDUMP", "key"}}
+	if !reflect.DeepEqual(rawConn.commands, expected) {
+		t.Errorf("raw connection received %v, want %v", rawConn.commands, expected)
+	}
+}
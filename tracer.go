@@ -0,0 +1,122 @@
+package rewledis
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	rewledisArgs "github.com/pskopnik/rewledis/args"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// DefaultTracerMaxEntries is the number of entries retained by a
+// CommandTracer if MaxEntries is not set.
+const DefaultTracerMaxEntries = 1000
+
+// CommandTracer records Redis commands along with the LedisDB commands they
+// have been rewritten to. It is meant for debugging incorrect rewrites, see
+// Rewriter.Tracer. Only the most recent entries are retained.
+//
+// The zero value is ready to use. A CommandTracer is safe for concurrent
+// use.
+type CommandTracer struct {
+	// MaxEntries is the maximum number of entries retained. Once exceeded,
+	// the oldest entries are discarded. If MaxEntries is 0,
+	// DefaultTracerMaxEntries is used.
+	MaxEntries int
+
+	mutex   sync.Mutex
+	entries []commandTraceEntry
+}
+
+type commandTraceEntry struct {
+	Time          time.Time
+	OriginalCmd   string
+	RewrittenCmds []string
+}
+
+// Record records originalCmd, a Redis command, together with rewrittenCmds,
+// the LedisDB commands it has been rewritten to. rewrittenCmds is empty if
+// the command has been handled without sending any command to LedisDB.
+func (c *CommandTracer) Record(originalCmd string, rewrittenCmds []string) {
+	entry := commandTraceEntry{
+		Time:          time.Now(),
+		OriginalCmd:   originalCmd,
+		RewrittenCmds: rewrittenCmds,
+	}
+
+	maxEntries := c.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = DefaultTracerMaxEntries
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if len(c.entries) >= maxEntries {
+		// Shift instead of reslicing, so that the backing array does not
+		// grow indefinitely.
+		dropped := len(c.entries) - maxEntries + 1
+		c.entries = c.entries[:copy(c.entries, c.entries[dropped:])]
+	}
+
+	c.entries = append(c.entries, entry)
+}
+
+// Dump returns a human-readable representation of all retained entries,
+// oldest first. Each original command is followed by the indented commands
+// it has been rewritten to.
+func (c *CommandTracer) Dump() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var builder strings.Builder
+
+	for _, entry := range c.entries {
+		builder.WriteString(entry.Time.Format(time.RFC3339Nano))
+		builder.WriteByte(' ')
+		builder.WriteString(entry.OriginalCmd)
+		builder.WriteByte('\n')
+
+		if len(entry.RewrittenCmds) == 0 {
+			builder.WriteString("    (no commands sent)\n")
+		}
+		for _, rewrittenCmd := range entry.RewrittenCmds {
+			builder.WriteString("    -> ")
+			builder.WriteString(rewrittenCmd)
+			builder.WriteByte('\n')
+		}
+	}
+
+	return builder.String()
+}
+
+// formatTracedCommand formats a command in the style of Redis' MONITOR
+// command: The name and all arguments are quoted and separated by spaces.
+func formatTracedCommand(commandName string, args []interface{}) string {
+	var argsArray [8][]byte
+	byteArgs := rewledisArgs.AppendAsBytes(argsArray[:0], args)
+
+	var builder strings.Builder
+	builder.WriteString(strconv.Quote(commandName))
+	for _, arg := range byteArgs {
+		builder.WriteByte(' ')
+		builder.WriteString(strconv.Quote(string(arg)))
+	}
+
+	return builder.String()
+}
+
+// commandRecordingConn wraps a redis.Conn and records all commands sent
+// using Send. It is passed to SendLedisFuncs when a CommandTracer is set.
+type commandRecordingConn struct {
+	redis.Conn
+	commands []string
+}
+
+func (c *commandRecordingConn) Send(commandName string, args ...interface{}) error {
+	c.commands = append(c.commands, formatTracedCommand(commandName, args))
+	return c.Conn.Send(commandName, args...)
+}
@@ -2,11 +2,17 @@ package rewledis
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	rewledisArgs "github.com/pskopnik/rewledis/args"
+	"github.com/pskopnik/rewledis/replyutil"
 
 	"github.com/gomodule/redigo/redis"
 )
@@ -19,6 +25,7 @@ var (
 	ErrInvalidSyntax              = errors.New("invalid syntax")
 	ErrInvalidArgumentType        = errors.New("invalid argument type")
 	ErrNoEmulationPossible        = errors.New("no emulation possible for the issued command")
+	ErrInvalidPatternKeyType      = errors.New("key referenced by pattern is not of type string")
 )
 
 const (
@@ -26,10 +33,40 @@ const (
 	stringPX = "PX"
 	stringXX = "XX"
 	stringNX = "NX"
+	stringGT = "GT"
+	stringLT = "LT"
 
 	stringINCR = "INCR"
 	stringCH   = "CH"
 
+	stringLIMIT = "LIMIT"
+	stringMATCH = "MATCH"
+
+	stringBYTE = "BYTE"
+	stringBIT  = "BIT"
+	stringNOT  = "NOT"
+
+	stringLEFT  = "LEFT"
+	stringRIGHT = "RIGHT"
+	stringMIN   = "MIN"
+	stringMAX   = "MAX"
+	stringCOUNT = "COUNT"
+
+	stringWITHVALUES = "WITHVALUES"
+
+	stringBY    = "BY"
+	stringGET   = "GET"
+	stringASC   = "ASC"
+	stringDESC  = "DESC"
+	stringALPHA = "ALPHA"
+	stringSTORE = "STORE"
+
+	stringWITHCOORD = "WITHCOORD"
+	stringWITHDIST  = "WITHDIST"
+	stringWITHHASH  = "WITHHASH"
+	stringANY       = "ANY"
+	stringSTOREDIST = "STOREDIST"
+
 	stringEXISTS = "EXISTS"
 	stringFLUSH  = "FLUSH"
 	stringLOAD   = "LOAD"
@@ -38,20 +75,80 @@ const (
 	stringABSTTL   = "ABSTTL"
 	stringIDLETIME = "IDLETIME"
 	stringFREQ     = "FREQ"
+	stringREFCOUNT = "REFCOUNT"
+	stringHELP     = "HELP"
+
+	stringENCODING = "ENCODING"
+
+	stringLIST = "LIST"
 
 	stringLEDIS = "LEDIS"
 	stringSELF  = "SELF"
+
+	stringKEYSPACE   = "KEYSPACE"
+	stringVERSION    = "VERSION"
+	stringINFO       = "INFO"
+	stringDEFAULT    = "DEFAULT"
+	stringEVERYTHING = "EVERYTHING"
+
+	stringDIAGNOSTIC = "DIAGNOSTIC"
+
+	stringKEYSLOT = "KEYSLOT"
+
+	stringQUICKLISTPACKEDTHRESHOLD = "QUICKLIST-PACKED-THRESHOLD"
+
+	stringTYPE   = "TYPE"
+	stringNORMAL = "NORMAL"
+	stringPUBSUB = "PUBSUB"
+	stringKILL   = "KILL"
+	stringPAUSE  = "PAUSE"
+
+	stringNOEVICT = "NO-EVICT"
+	stringON      = "ON"
+	stringOFF     = "OFF"
+	stringWRITE   = "WRITE"
+	stringALL     = "ALL"
 )
 
 var (
 	bytesEX = []byte("EX")
 	bytesPX = []byte("PX")
 	bytesXX = []byte("XX")
+	bytesGT = []byte("GT")
+	bytesLT = []byte("LT")
 	bytesNX = []byte("NX")
 
 	bytesINCR = []byte("INCR")
 	bytesCH   = []byte("CH")
 
+	bytesLIMIT = []byte("LIMIT")
+	bytesMATCH = []byte("MATCH")
+
+	bytesBYTE = []byte("BYTE")
+	bytesBIT  = []byte("BIT")
+	bytesNOT  = []byte("NOT")
+
+	bytesLEFT  = []byte("LEFT")
+	bytesRIGHT = []byte("RIGHT")
+	bytesMIN   = []byte("MIN")
+	bytesMAX   = []byte("MAX")
+	bytesCOUNT = []byte("COUNT")
+
+	bytesWITHVALUES = []byte("WITHVALUES")
+
+	bytesBY    = []byte("BY")
+	bytesGET   = []byte("GET")
+	bytesASC   = []byte("ASC")
+	bytesDESC  = []byte("DESC")
+	bytesALPHA = []byte("ALPHA")
+	bytesSTORE = []byte("STORE")
+
+	bytesWITHCOORD = []byte("WITHCOORD")
+	bytesWITHDIST  = []byte("WITHDIST")
+	bytesWITHHASH  = []byte("WITHHASH")
+	bytesANY       = []byte("ANY")
+	bytesSTOREDIST = []byte("STOREDIST")
+
 	bytesEXISTS = []byte("EXISTS")
 	bytesFLUSH  = []byte("FLUSH")
 	bytesLOAD   = []byte("LOAD")
@@ -60,9 +157,39 @@ var (
 	bytesABSTTL   = []byte("ABSTTL")
 	bytesIDLETIME = []byte("IDLETIME")
 	bytesFREQ     = []byte("FREQ")
+	bytesREFCOUNT = []byte("REFCOUNT")
+	bytesHELP     = []byte("HELP")
+
+	bytesENCODING = []byte("ENCODING")
+
+	bytesLIST = []byte("LIST")
 
 	bytesLEDIS = []byte("LEDIS")
 	bytesSELF  = []byte("SELF")
+
+	bytesKEYSPACE   = []byte("KEYSPACE")
+	bytesVERSION    = []byte("VERSION")
+	bytesINFO       = []byte("INFO")
+	bytesDEFAULT    = []byte("DEFAULT")
+	bytesEVERYTHING = []byte("EVERYTHING")
+
+	bytesDIAGNOSTIC = []byte("DIAGNOSTIC")
+
+	bytesKEYSLOT = []byte("KEYSLOT")
+
+	bytesQUICKLISTPACKEDTHRESHOLD = []byte("QUICKLIST-PACKED-THRESHOLD")
+
+	bytesTYPE   = []byte("TYPE")
+	bytesNORMAL = []byte("NORMAL")
+	bytesPUBSUB = []byte("PUBSUB")
+	bytesKILL   = []byte("KILL")
+	bytesPAUSE  = []byte("PAUSE")
+
+	bytesNOEVICT = []byte("NO-EVICT")
+	bytesON      = []byte("ON")
+	bytesOFF     = []byte("OFF")
+	bytesWRITE   = []byte("WRITE")
+	bytesALL     = []byte("ALL")
 )
 
 var (
@@ -89,504 +216,693 @@ func NoneTransformer() TransformFunc {
 	)
 }
 
-type KeyTypeAggregation struct {
-	None []string
-	KV   []string
-	List []string
-	Hash []string
-	Set  []string
-	ZSet []string
-}
+// WithArityCheck returns a TransformFunc validating the number of arguments
+// before calling inner. ErrInvalidSyntax is returned if fewer than minArgs or
+// more than maxArgs arguments are passed. A maxArgs of -1 means that the
+// number of arguments is not bounded. The command name is not counted as an
+// argument.
+func WithArityCheck(minArgs, maxArgs int, inner TransformFunc) TransformFunc {
+	return TransformFunc(
+		func(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+			if len(args) < minArgs || (maxArgs >= 0 && len(args) > maxArgs) {
+				return nil, ErrInvalidSyntax
+			}
 
-func (k *KeyTypeAggregation) AppendKeys(typesInfo []TypeInfo) {
-	for i := range typesInfo {
-		switch typesInfo[i].Type {
-		case LedisTypeNone:
-			k.None = append(k.None, typesInfo[i].Key)
-		case LedisTypeKV:
-			k.KV = append(k.KV, typesInfo[i].Key)
-		case LedisTypeList:
-			k.List = append(k.List, typesInfo[i].Key)
-		case LedisTypeHash:
-			k.Hash = append(k.Hash, typesInfo[i].Key)
-		case LedisTypeSet:
-			k.Set = append(k.Set, typesInfo[i].Key)
-		case LedisTypeZSet:
-			k.ZSet = append(k.ZSet, typesInfo[i].Key)
-		}
-	}
+			return inner(rewriter, command, args)
+		},
+	)
 }
 
-type TypeSpecificCommands struct {
-	None string
-	KV   string
-	List string
-	Hash string
-	Set  string
-	ZSet string
-}
+// WriteThroughTransformer returns a TransformFunc passing commands on to
+// LedisDB unchanged, just as NoneTransformer. After the command succeeded,
+// the cache entry of the key at index 0 is set to keyType. This is meant for
+// commands which guarantee that the key exists with type keyType afterwards,
+// so that subsequent generic commands do not need to resolve the key.
+func WriteThroughTransformer(keyType LedisType) TransformFunc {
+	return TransformFunc(
+		func(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+			if len(args) < 1 {
+				return nil, ErrInvalidSyntax
+			}
 
-var (
-	ErrInvalidAggregationValue = errors.New("invalid Aggregation value")
-)
+			sendLedisFunc, err := noneTransformerInstance(rewriter, command, args)
+			if err != nil {
+				return nil, err
+			}
 
-type Aggregation int8
+			key := rewledisArgs.AsSimpleString(args[0])
 
-const (
-	AggregationSum Aggregation = iota
-	AggregationCountOne
-	AggregationFirst
-)
+			return chainProcessFunc(sendLedisFunc, func(reply interface{}) (interface{}, error) {
+				if _, ok := reply.(redis.Error); ok {
+					return reply, nil
+				}
 
-type TypeSpecificBulkTransformerConfig struct {
-	Commands            TypeSpecificCommands
-	Debulk              bool
-	Aggregation         Aggregation
-	AppendArgsExtractor ArgsExtractor
+				rewriter.trySetCacheEntry(key, CacheEntryStateExists, keyType)
+
+				return reply, nil
+			}), nil
+		},
+	)
 }
 
-func TypeSpecificBulkTransformer(config *TypeSpecificBulkTransformerConfig) TransformFunc {
+// expireOptionError returns the error for the option argument of the EXPIRE
+// family commands, which has been introduced in Redis 7.0. LedisDB supports
+// none of the options, an error wrapping ErrNoEmulationPossible is returned
+// for NX, XX, GT and LT. ErrInvalidSyntax is returned for other arguments.
+func expireOptionError(command *RedisCommand, arg interface{}) error {
+	argInfo := rewledisArgs.Parse(arg)
+	if !argInfo.IsStringLike() {
+		return ErrInvalidArgumentType
+	}
+
+	if argInfo.EqualFoldEither(stringNX, bytesNX) ||
+		argInfo.EqualFoldEither(stringXX, bytesXX) ||
+		argInfo.EqualFoldEither(stringGT, bytesGT) ||
+		argInfo.EqualFoldEither(stringLT, bytesLT) {
+		option, _ := argInfo.ConvertToRedisString()
+		return fmt.Errorf("%w: %s %s", ErrNoEmulationPossible, command.Name, strings.ToUpper(option))
+	}
+
+	return ErrInvalidSyntax
+}
+
+// ExpireTransformer returns a TransformFunc for the EXPIRE Redis command. The
+// command is rewritten by a TypeSpecificBulkTransformer using config. After
+// the command has been executed, the cache entry of the key is refreshed: A
+// reply of 0 means that the key does not exist. A reply of 1 confirms the
+// cached type of the key, unless the timeout is not positive, in which case
+// the key has been deleted. Refreshing the entry prevents the key from being
+// resolved again immediately.
+//
+// The NX, XX, GT and LT options are not supported, see expireOptionError.
+func ExpireTransformer(config *TypeSpecificBulkTransformerConfig) TransformFunc {
+	bulkTransformer := TypeSpecificBulkTransformer(config)
+
 	return TransformFunc(
 		func(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
-			var typeInfoArray [12]TypeInfo
-			var extractedArgsArray [12]interface{}
-			var keysArray [12]string
-
-			keyArgs := command.KeyExtractor.AppendArgs(extractedArgsArray[:0], args)
-			keys := rewledisArgs.AppendAsSimpleStrings(keysArray[:0], keyArgs)
+			if len(args) == 3 {
+				return nil, expireOptionError(command, args[2])
+			}
+			if len(args) != 2 {
+				return nil, ErrInvalidSyntax
+			}
 
-			resolver := rewriter.Resolver()
-			ctx, cancel := context.WithCancel(context.Background())
-			typesInfo, err := resolver.ResolveAppend(typeInfoArray[:0], ctx, keys)
-			cancel()
+			secondsInfo := rewledisArgs.Parse(args[1])
+			seconds, err := secondsInfo.ConvertToInt()
 			if err != nil {
 				return nil, err
 			}
 
-			var appendArgs []interface{}
-			if config.AppendArgsExtractor != nil {
-				appendArgs = config.AppendArgsExtractor.AppendArgs(extractedArgsArray[:0], args)
+			sendLedisFunc, err := bulkTransformer(rewriter, command, args)
+			if err != nil {
+				return nil, err
 			}
 
-			keyTypeAggregation := KeyTypeAggregation{}
-			keyTypeAggregation.AppendKeys(typesInfo)
+			// The key has been resolved by bulkTransformer, the version is
+			// loaded afterwards so that only later updates take precedence.
+			key := rewledisArgs.AsSimpleString(args[0])
+			version := rewriter.loadCacheVersion(key)
 
-			return SendLedisFunc(func(ledisConn redis.Conn) (Slot, error) {
-				repliesCount, err := sendBulkForAllTypes(config, keyTypeAggregation, ledisConn, appendArgs)
+			return chainProcessFunc(sendLedisFunc, func(reply interface{}) (interface{}, error) {
+				if _, ok := reply.(redis.Error); ok {
+					return reply, nil
+				}
+
+				count, err := redis.Int64(reply, nil)
 				if err != nil {
-					return Slot{}, err
+					return nil, err
 				}
 
-				return Slot{
-					RepliesCount: repliesCount,
-					ProcessFunc:  Aggregator(config.Aggregation),
-				}, nil
+				if count == 0 || seconds <= 0 {
+					rewriter.setCacheEntryIfVersion(key, CacheEntryStateDeleted, LedisTypeNone, version)
+				} else if keyType, ok := rewriter.loadCachedType(key); ok {
+					rewriter.setCacheEntryIfVersion(key, CacheEntryStateExists, keyType, version)
+				}
+
+				return reply, nil
 			}), nil
 		},
 	)
 }
 
-// Aggregator creates an aggregator, i.e. a function which reduces any number
-// of reply values to a single reply value.
-func Aggregator(aggregation Aggregation) func([]interface{}) (interface{}, error) {
-	return func(replies []interface{}) (interface{}, error) {
-		switch aggregation {
-		case AggregationSum:
-			var sum int64
-			for _, reply := range replies {
-				value, err := redis.Int64(reply, nil)
-				if err != nil {
-					return nil, err
-				}
-				sum += value
+// ExpireatTransformer returns a TransformFunc for the EXPIREAT Redis command.
+// The command is rewritten by a TypeSpecificBulkTransformer using config. If
+// the command succeeded and timestamp lies in the past, LedisDB expires the
+// key immediately. The cache entry of the key is invalidated in this case,
+// so that no stale type is served for the expired key.
+//
+// The NX, XX, GT and LT options are not supported, see expireOptionError.
+func ExpireatTransformer(config *TypeSpecificBulkTransformerConfig) TransformFunc {
+	bulkTransformer := TypeSpecificBulkTransformer(config)
+
+	return TransformFunc(
+		func(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+			if len(args) == 3 {
+				return nil, expireOptionError(command, args[2])
 			}
-			return sum, nil
-		case AggregationCountOne:
-			return int64(len(replies)), nil
-		case AggregationFirst:
-			if len(replies) == 0 {
-				return nil, nil
+			if len(args) != 2 {
+				return nil, ErrInvalidSyntax
 			}
-			return replies[0], nil
-		default:
-			return nil, ErrInvalidAggregationValue
-		}
-	}
-}
-
-func sendBulkForAllTypes(
-	config *TypeSpecificBulkTransformerConfig,
-	keyTypeAggregation KeyTypeAggregation,
-	ledisConn redis.Conn,
-	appendArgs []interface{},
-) (int, error) {
-	var sentCount, repliesCount int
-	var err error
-
-	sentCount, err = sendBulk(config.Commands.None, keyTypeAggregation.None, ledisConn, config.Debulk, appendArgs)
-	if err != nil {
-		return repliesCount, err
-	}
-	repliesCount += sentCount
-	sentCount, err = sendBulk(config.Commands.KV, keyTypeAggregation.KV, ledisConn, config.Debulk, appendArgs)
-	if err != nil {
-		return repliesCount, err
-	}
-	repliesCount += sentCount
-	sentCount, err = sendBulk(config.Commands.List, keyTypeAggregation.List, ledisConn, config.Debulk, appendArgs)
-	if err != nil {
-		return repliesCount, err
-	}
-	repliesCount += sentCount
-	sentCount, err = sendBulk(config.Commands.Hash, keyTypeAggregation.Hash, ledisConn, config.Debulk, appendArgs)
-	if err != nil {
-		return repliesCount, err
-	}
-	repliesCount += sentCount
-	sentCount, err = sendBulk(config.Commands.Set, keyTypeAggregation.Set, ledisConn, config.Debulk, appendArgs)
-	if err != nil {
-		return repliesCount, err
-	}
-	repliesCount += sentCount
-	sentCount, err = sendBulk(config.Commands.ZSet, keyTypeAggregation.ZSet, ledisConn, config.Debulk, appendArgs)
-	if err != nil {
-		return repliesCount, err
-	}
-	repliesCount += sentCount
 
-	return repliesCount, nil
-}
+			timestampInfo := rewledisArgs.Parse(args[1])
+			timestamp, err := timestampInfo.ConvertToInt()
+			if err != nil {
+				return nil, err
+			}
 
-func sendBulk(command string, keys []string, conn redis.Conn, debulk bool, appendArgs []interface{}) (int, error) {
-	var sentCount int
-	var argsArray [12]interface{}
+			sendLedisFunc, err := bulkTransformer(rewriter, command, args)
+			if err != nil {
+				return nil, err
+			}
 
-	if len(command) > 0 && len(keys) > 0 {
-		if debulk {
-			for _, key := range keys {
-				sentCount++
+			key := rewledisArgs.AsSimpleString(args[0])
 
-				args := append(argsArray[:0], key)
-				args = append(args, appendArgs...)
+			return chainProcessFunc(sendLedisFunc, func(reply interface{}) (interface{}, error) {
+				if _, ok := reply.(redis.Error); ok {
+					return reply, nil
+				}
 
-				err := conn.Send(command, args...)
+				count, err := redis.Int64(reply, nil)
 				if err != nil {
-					return sentCount, err
+					return nil, err
 				}
-			}
-		} else {
-			sentCount++
 
-			args := append(argsArray[:0], make([]interface{}, len(keys))...)
-			for i := range keys {
-				args[i] = keys[i]
-			}
-			args = append(args, appendArgs...)
+				if count == 1 && timestamp <= time.Now().Unix() {
+					rewriter.invalidateCacheEntry(key)
+				}
 
-			err := conn.Send(command, args...)
-			if err != nil {
-				return sentCount, err
-			}
-		}
-	}
+				return reply, nil
+			}), nil
+		},
+	)
+}
 
-	return sentCount, nil
+// NoEmulationTransformer returns a TransformFunc for commands which cannot be
+// emulated on LedisDB. The TransformFunc always returns an error wrapping
+// ErrNoEmulationPossible. hint is included in the error message and should
+// point users to an alternative.
+func NoEmulationTransformer(hint string) TransformFunc {
+	return TransformFunc(
+		func(_ *Rewriter, command *RedisCommand, _ []interface{}) (SendLedisFunc, error) {
+			return nil, fmt.Errorf("%w: %s: %s", ErrNoEmulationPossible, command.Name, hint)
+		},
+	)
 }
 
-// SetCommandTransformer performs transformations for the SET Redis
-// command.
+// streamNotSupportedHint is appended to the error reply returned for stream
+// commands.
+const streamNotSupportedHint = "LedisDB does not support Redis Streams, use UNSAFE LEDIS to issue native LedisDB commands"
+
+// StreamNotSupportedTransformer is the TransformFunc of all Redis stream
+// commands. Streams are not implemented in LedisDB, the TransformFunc always
+// returns an error reply in the format used by Redis for unknown commands.
+// The error message points users to the UNSAFE LEDIS command.
 //
-// This transformer mirrors Redis behaviour: When both XX and EX is supplied,
-// nil is returned without applying any changes. When both EX and PX is
-// supplied, the PX value takes precedence.
-func SetCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
-	commandInfo, err := parseSetCommand(args)
-	if err != nil {
-		return nil, err
+// Unlike NoEmulationTransformer, no Go error is returned, so the connection
+// remains usable. Some clients issue stream commands such as XAUTOCLAIM or
+// XPENDING unconditionally when they detect a stream-capable server.
+func StreamNotSupportedTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	return replySendLedisFunc(redis.Error(fmt.Sprintf(
+		"ERR unknown command '%s', %s", command.Name, streamNotSupportedHint,
+	))), nil
+}
+
+// PfaddCommandTransformer emulates the PFADD Redis command using a set.
+// HyperLogLogs are not implemented in LedisDB. Instead of estimating the
+// cardinality, the elements are stored in the set at key using SADD. Counts
+// are thus exact, but memory usage grows with the number of unique elements.
+// The set is not compatible with Redis' HyperLogLog representation, e.g.
+// GET on key does not work.
+//
+// 1 is returned if at least one element was added, 0 otherwise. If no
+// elements are passed, 1 is returned if key does not exist. Unlike Redis, no
+// empty HyperLogLog is created in this case.
+func PfaddCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	if len(args) < 1 {
+		return nil, ErrInvalidSyntax
 	}
 
-	if commandInfo.XXSet && commandInfo.NXSet {
-		return nil, ErrInvalidArgumentCombination
-	}
-	if commandInfo.EXSet && commandInfo.PXSet {
-		return nil, ErrInvalidArgumentCombination
-	}
-
-	expSet := commandInfo.EXSet
-	expDuration := commandInfo.EX
-	if commandInfo.PXSet {
-		expSet = true
-		// Round up the expiration duration (ignoring overflow)
-		expDuration = (commandInfo.PX + 999) / 1000
-	}
-
-	if commandInfo.XXSet {
-		return nil, ErrNoEmulationPossible
-	}
-	// if expSet && commandInfo.NXSet {
-	// 	// Emulation subject to race-conditions
-	// }
+	key := rewledisArgs.AsSimpleString(args[0])
 
 	return SendLedisFunc(func(ledisConn redis.Conn) (Slot, error) {
 		var err error
-		var repliesCount int
-		var transformNX bool
+		if len(args) == 1 {
+			err = ledisConn.Send("SCARD", args[0])
+		} else {
+			err = ledisConn.Send("SADD", args...)
+		}
+		if err != nil {
+			return Slot{}, err
+		}
 
-		if commandInfo.NXSet {
-			transformNX = true
-			repliesCount++
-			err = ledisConn.Send("SETNX", args[0], args[1])
-			if err != nil {
-				return Slot{}, err
-			}
+		return Slot{
+			RepliesCount: 1,
+			ProcessFunc: func(replies []interface{}) (interface{}, error) {
+				if _, ok := replies[0].(redis.Error); ok {
+					return replies[0], nil
+				}
 
-			if expSet {
-				repliesCount++
-				err = ledisConn.Send("EXPIRE", args[0], expDuration)
+				count, err := redis.Int64(replies[0], nil)
 				if err != nil {
-					return Slot{}, err
+					return nil, err
 				}
-			}
-		} else if expSet {
-			repliesCount++
-			err = ledisConn.Send("SETEX", args[0], expDuration, args[1])
-			if err != nil {
-				return Slot{}, err
-			}
-		} else {
-			repliesCount++
-			err = ledisConn.Send("SET", args[0], args[1])
+
+				if len(args) == 1 {
+					if count == 0 {
+						return int64(1), nil
+					}
+					return int64(0), nil
+				}
+
+				rewriter.trySetCacheEntry(key, CacheEntryStateExists, LedisTypeSet)
+
+				if count > 0 {
+					return int64(1), nil
+				}
+				return int64(0), nil
+			},
+		}, nil
+	}), nil
+}
+
+// PfcountCommandTransformer emulates the PFCOUNT Redis command for
+// HyperLogLogs emulated by PfaddCommandTransformer. For a single key, the
+// cardinality of the set at key is returned using SCARD. For multiple keys,
+// the union of the sets is retrieved using SUNION and its size is returned.
+func PfcountCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	if len(args) < 1 {
+		return nil, ErrInvalidSyntax
+	}
+
+	if len(args) == 1 {
+		return SendLedisFunc(func(ledisConn redis.Conn) (Slot, error) {
+			err := ledisConn.Send("SCARD", args[0])
 			if err != nil {
 				return Slot{}, err
 			}
+
+			return Slot{
+				RepliesCount: 1,
+				ProcessFunc: func(replies []interface{}) (interface{}, error) {
+					return replies[0], nil
+				},
+			}, nil
+		}), nil
+	}
+
+	return SendLedisFunc(func(ledisConn redis.Conn) (Slot, error) {
+		err := ledisConn.Send("SUNION", args...)
+		if err != nil {
+			return Slot{}, err
 		}
 
 		return Slot{
-			RepliesCount: repliesCount,
+			RepliesCount: 1,
 			ProcessFunc: func(replies []interface{}) (interface{}, error) {
-				if transformNX {
-					wasSet, err := redis.Bool(replies[0], nil)
-					if err != nil {
-						return nil, err
-					}
-					if !wasSet {
-						return nil, nil
-					}
-					return "OK", nil
+				if _, ok := replies[0].(redis.Error); ok {
+					return replies[0], nil
 				}
 
-				return replies[0], nil
+				members, err := redis.Values(replies[0], nil)
+				if err != nil {
+					return nil, err
+				}
+
+				return int64(len(members)), nil
 			},
 		}, nil
 	}), nil
 }
 
-type setCommandInfo struct {
-	EXSet bool
-	EX    int64
-	PXSet bool
-	PX    int64
-	NXSet bool
-	XXSet bool
-}
-
-func parseSetCommand(args []interface{}) (info setCommandInfo, err error) {
-	if len(args) < 2 {
-		err = ErrInvalidSyntax
-		return
+// PfmergeCommandTransformer emulates the PFMERGE Redis command for
+// HyperLogLogs emulated by PfaddCommandTransformer. The union of destkey and
+// all source keys is stored at destkey using SUNIONSTORE. If all sets are
+// empty, destkey does not exist afterwards.
+func PfmergeCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	if len(args) < 1 {
+		return nil, ErrInvalidSyntax
 	}
 
-	for i := 2; i < len(args); i++ {
-		argInfo := rewledisArgs.Parse(args[i])
+	key := rewledisArgs.AsSimpleString(args[0])
 
-		if !argInfo.IsStringLike() {
-			err = ErrInvalidArgumentType
-			return
+	return SendLedisFunc(func(ledisConn redis.Conn) (Slot, error) {
+		var transformedArgsArray [8]interface{}
+		transformedArgs := append(transformedArgsArray[:0], args[0])
+		transformedArgs = append(transformedArgs, args...)
+
+		err := ledisConn.Send("SUNIONSTORE", transformedArgs...)
+		if err != nil {
+			return Slot{}, err
 		}
 
-		if argInfo.EqualFoldEither(stringEX, bytesEX) {
-			if i+1 >= len(args) {
-				err = ErrInvalidSyntax
-				return
-			}
+		return Slot{
+			RepliesCount: 1,
+			ProcessFunc: func(replies []interface{}) (interface{}, error) {
+				if _, ok := replies[0].(redis.Error); ok {
+					return replies[0], nil
+				}
 
-			i++
-			valueInfo := rewledisArgs.Parse(args[i])
-			info.EX, err = valueInfo.ConvertToInt()
-			if err != nil {
-				return
-			}
-			info.EXSet = true
-		} else if argInfo.EqualFoldEither(stringPX, bytesPX) {
-			if i+1 >= len(args) {
-				err = ErrInvalidSyntax
-				return
-			}
+				count, err := redis.Int64(replies[0], nil)
+				if err != nil {
+					return nil, err
+				}
 
-			i++
-			valueInfo := rewledisArgs.Parse(args[i])
-			info.PX, err = valueInfo.ConvertToInt()
-			if err != nil {
-				return
-			}
-			info.PXSet = true
-		} else if argInfo.EqualFoldEither(stringNX, bytesNX) {
-			info.NXSet = true
-		} else if argInfo.EqualFoldEither(stringXX, bytesXX) {
-			info.XXSet = true
-		} else {
-			err = ErrInvalidSyntax
-			return
-		}
-	}
+				if count > 0 {
+					rewriter.trySetCacheEntry(key, CacheEntryStateExists, LedisTypeSet)
+				} else {
+					rewriter.trySetCacheEntry(key, CacheEntryStateDeleted, LedisTypeNone)
+				}
 
-	return
+				return "OK", nil
+			},
+		}, nil
+	}), nil
 }
 
-var lremScript = redis.NewScript(2, `
-local function reverse(arr)
-	local i, j = 1, #arr
-
-	while i < j do
-		arr[i], arr[j] = arr[j], arr[i]
+// streamID is the ID of an entry of a Redis stream, consisting of a
+// millisecond timestamp and a sequence number.
+type streamID struct {
+	MS  uint64
+	Seq uint64
+}
 
-		i = i + 1
-		j = j - 1
-	end
-end
+func (s streamID) String() string {
+	return strconv.FormatUint(s.MS, 10) + "-" + strconv.FormatUint(s.Seq, 10)
+}
 
-local listKey = KEYS[1]
-local tempListKey = KEYS[2]
-local count = tonumber(ARGV[1])
-local value = ARGV[2]
+func (s streamID) Less(other streamID) bool {
+	return s.MS < other.MS || (s.MS == other.MS && s.Seq < other.Seq)
+}
 
-local removedCount = 0
-local processed = 0
-local tempListLen = 0
-local listLen = ledis.call('LLEN', listKey)
+// streamIDState is the state of an emulated stream tracked by the rewriter,
+// see nextStreamID.
+type streamIDState struct {
+	// Last is the ID of the last entry stored in LedisDB.
+	Last streamID
+	// Reserved is the greatest ID handed out to an XADD whose ZADD has not
+	// completed yet. Reserved is only valid if Pending is greater than 0.
+	Reserved streamID
+	// Pending is the number of XADDs whose ZADD has not completed yet.
+	Pending int
+}
 
-if listLen == 0
-then
-	return 0
-end
+// parseStreamID parses an ID in the form ms-seq or ms. seq may be "*", in
+// which case autoSeq is true.
+func parseStreamID(raw string) (id streamID, autoSeq bool, err error) {
+	msPart, seqPart := raw, ""
+	if i := strings.IndexByte(raw, '-'); i >= 0 {
+		msPart, seqPart = raw[:i], raw[i+1:]
+	}
 
-if count >= 0
-then
-	for i = 0, listLen - 1, 1 do
-		element = ledis.call('LPOP', listKey)
-		processed = processed + 1
+	id.MS, err = strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return
+	}
 
-		if element == value
-		then
-			removedCount = removedCount + 1
-			if removedCount == count
-			then
-				break
-			end
-		else
-			ledis.call('RPUSH', tempListKey, element)
-			tempListLen = tempListLen + 1
-		end
-	end
+	if seqPart == "*" {
+		autoSeq = true
+	} else if seqPart != "" {
+		id.Seq, err = strconv.ParseUint(seqPart, 10, 64)
+	}
 
-	if processed < listLen
-	then
-		remainingElements = ledis.call('LRANGE', listKey, 0, -1)
-		ledis.call('RPUSH', tempListKey, unpack(remainingElements))
-		tempListLen = tempListLen + #remainingElements
-	end
-else
-	for i = 0, listLen - 1, 1 do
-		element = ledis.call('LINDEX', listKey, -1)
-		processed = processed + 1
+	return
+}
 
-		if element == value
-		then
-			ledis.call('RPOP', listKey)
-			removedCount = removedCount + 1
-			if removedCount == -count
-			then
-				break
-			end
-		else
-			ledis.call('RPOPLPUSH', listKey, tempListKey)
-			tempListLen = tempListLen + 1
-		end
-	end
+// emulatedStreamEntry is the JSON-encoded member of the sorted set emulating
+// a stream, see XaddCommandTransformer.
+type emulatedStreamEntry struct {
+	ID     string            `json:"id"`
+	Fields map[string]string `json:"fields"`
+}
 
-	if processed < listLen
-	then
-		remainingElements = ledis.call('LRANGE', listKey, 0, -1)
-		reverse(remainingElements)
-		ledis.call('LPUSH', tempListKey, unpack(remainingElements))
-		tempListLen = tempListLen + #remainingElements
-	end
-end
+// Error replies returned by the XADD emulation. The messages match those of
+// Redis.
+var (
+	errStreamIDInvalid  = redis.Error("ERR Invalid stream ID specified as stream command argument")
+	errStreamIDTooSmall = redis.Error("ERR The ID specified in XADD is equal or smaller than the target stream top item")
+	errStreamIDZero     = redis.Error("ERR The ID specified in XADD must be greater than 0-0")
+)
 
--- move temporary list content to the original key
-
-if tempListLen > 0
-then
-	local tempListContent = ledis.call('LDUMP', tempListKey)
-	local listTTL = ledis.call('LTTL', listKey)
+// XaddCommandTransformer emulates the XADD Redis command using a sorted set.
+// Each entry is stored as a member of the sorted set at key. The member is
+// the JSON encoding of the entry's ID and fields, the score is the
+// millisecond part of the ID. This is not compatible with Redis streams, but
+// provides a working subset for applications only appending to streams.
+//
+// IDs are generated and checked per rewriter: The last ID of each stream is
+// tracked in memory and loaded from LedisDB when first needed. The ID of a
+// new entry becomes the last ID once the entry has been stored. XADDs issued
+// concurrently through different rewriters or processes may produce
+// duplicate IDs.
+//
+// The NOMKSTREAM, MAXLEN, MINID and LIMIT options are not supported and
+// result in an error wrapping ErrNoEmulationPossible.
+func XaddCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	if len(args) < 4 || len(args)%2 != 0 {
+		return nil, ErrInvalidSyntax
+	}
 
-	local restoreTTL = 0
-	if listTTL > -1
-	then
-		restoreTTL = listTTL * 1000
-	end
+	keyInfo := rewledisArgs.Parse(args[0])
+	key, err := keyInfo.ConvertToRedisString()
+	if err != nil {
+		return nil, err
+	}
+	idInfo := rewledisArgs.Parse(args[1])
+	rawID, err := idInfo.ConvertToRedisString()
+	if err != nil {
+		return nil, err
+	}
 
-	ledis.call('RESTORE', listKey, restoreTTL, tempListContent)
-	ledis.call('LCLEAR', tempListKey)
-else
-	ledis.call('LCLEAR', listKey)
-end
+	switch strings.ToUpper(rawID) {
+	case "NOMKSTREAM", "MAXLEN", "MINID":
+		return nil, fmt.Errorf("%w: XADD %s", ErrNoEmulationPossible, rawID)
+	}
 
-return removedCount
-`)
+	entry := emulatedStreamEntry{
+		Fields: make(map[string]string, (len(args)-2)/2),
+	}
+	for i := 2; i < len(args); i += 2 {
+		fieldInfo := rewledisArgs.Parse(args[i])
+		field, err := fieldInfo.ConvertToRedisString()
+		if err != nil {
+			return nil, err
+		}
+		valueInfo := rewledisArgs.Parse(args[i+1])
+		value, err := valueInfo.ConvertToRedisString()
+		if err != nil {
+			return nil, err
+		}
+		entry.Fields[field] = value
+	}
 
-func LremCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
-	if len(args) < 3 {
-		return nil, ErrInvalidSyntax
+	id, err := nextStreamID(rewriter, key, rawID)
+	if err != nil {
+		if replyErr, ok := err.(redis.Error); ok {
+			return replySendLedisFunc(replyErr), nil
+		}
+		return nil, err
 	}
+	entry.ID = id.String()
 
-	argInfo := rewledisArgs.Parse(args[0])
-	listKey, err := argInfo.ConvertToRedisString()
+	member, err := json.Marshal(entry)
 	if err != nil {
 		return nil, err
 	}
 
-	now := time.Now()
-	tempListKey := fmt.Sprintf("rewledis:temp:%d%d:%s", now.Unix(), now.Nanosecond(), listKey)
+	return SendLedisFunc(func(ledisConn redis.Conn) (Slot, error) {
+		err := ledisConn.Send("ZADD", args[0], id.MS, member)
+		if err != nil {
+			return Slot{}, err
+		}
+
+		return Slot{
+			RepliesCount: 1,
+			ProcessFunc: func(replies []interface{}) (interface{}, error) {
+				if _, ok := replies[0].(redis.Error); ok {
+					releaseStreamID(rewriter, key, id, false)
+					return replies[0], nil
+				}
+
+				releaseStreamID(rewriter, key, id, true)
+				rewriter.trySetCacheEntry(key, CacheEntryStateExists, LedisTypeZSet)
+
+				return []byte(entry.ID), nil
+			},
+		}, nil
+	}), nil
+}
+
+// nextStreamID determines the ID of a new entry of the emulated stream at
+// key. rawID is the ID argument passed to XADD. Invalid IDs result in an
+// error reply (redis.Error) being returned.
+//
+// The returned ID is reserved until releaseStreamID is called for it. IDs
+// are determined relative to the greatest reserved ID, so that pipelined
+// XADDs receive increasing IDs. Once no ID is reserved, IDs are determined
+// relative to the last ID stored.
+func nextStreamID(rewriter *Rewriter, key string, rawID string) (streamID, error) {
+	var requested streamID
+	var autoMS, autoSeq bool
+
+	if rawID == "*" {
+		autoMS = true
+	} else {
+		var err error
+		requested, autoSeq, err = parseStreamID(rawID)
+		if err != nil {
+			return streamID{}, errStreamIDInvalid
+		}
+		if !autoSeq && requested == (streamID{}) {
+			return streamID{}, errStreamIDZero
+		}
+	}
+
+	root := rewriter.root()
+	trackingKey := lruKey{DB: rewriter.db, Key: key}
+
+	root.streamIDsMutex.Lock()
+	state, ok := root.streamIDs[trackingKey]
+	if !ok {
+		// The last ID is loaded without holding the mutex, so that XADDs on
+		// other streams are not blocked by the round trip. The state is
+		// checked again as it may have been set in the meantime.
+		root.streamIDsMutex.Unlock()
+
+		loaded, err := loadLastStreamID(rewriter, key)
+		if err != nil {
+			return streamID{}, err
+		}
+
+		root.streamIDsMutex.Lock()
+		state, ok = root.streamIDs[trackingKey]
+		if !ok {
+			state.Last = loaded
+		}
+	}
+	defer root.streamIDsMutex.Unlock()
+
+	last := state.Last
+	if state.Pending > 0 {
+		last = state.Reserved
+	}
+
+	var id streamID
+	switch {
+	case autoMS:
+		id.MS = uint64(time.Now().UnixNano() / int64(time.Millisecond))
+		if id.MS <= last.MS {
+			id = streamID{MS: last.MS, Seq: last.Seq + 1}
+		}
+	case autoSeq:
+		if requested.MS < last.MS {
+			return streamID{}, errStreamIDTooSmall
+		}
+		id.MS = requested.MS
+		if requested.MS == last.MS {
+			id.Seq = last.Seq + 1
+		}
+	default:
+		if !last.Less(requested) {
+			return streamID{}, errStreamIDTooSmall
+		}
+		id = requested
+	}
+
+	state.Reserved = id
+	state.Pending++
+
+	if root.streamIDs == nil {
+		root.streamIDs = make(map[lruKey]streamIDState)
+	}
+	root.streamIDs[trackingKey] = state
+
+	return id, nil
+}
+
+// releaseStreamID releases the reservation of id, an ID returned by
+// nextStreamID for the emulated stream at key. stored indicates whether the
+// entry has been stored successfully, in this case id becomes the last ID of
+// the stream.
+func releaseStreamID(rewriter *Rewriter, key string, id streamID, stored bool) {
+	root := rewriter.root()
+	root.streamIDsMutex.Lock()
+	defer root.streamIDsMutex.Unlock()
+
+	trackingKey := lruKey{DB: rewriter.db, Key: key}
+	state, ok := root.streamIDs[trackingKey]
+	if !ok {
+		return
+	}
+
+	if stored && state.Last.Less(id) {
+		state.Last = id
+	}
+	if state.Pending > 0 {
+		state.Pending--
+	}
+
+	root.streamIDs[trackingKey] = state
+}
 
+// loadLastStreamID retrieves the ID of the last entry of the emulated stream
+// at key from LedisDB. The zero ID is returned if the stream is empty.
+//
+// Entries of the same millisecond share a score and are ordered by their
+// JSON encoding, which does not order sequence numbers numerically. Thus all
+// members with the greatest score are retrieved and the greatest of their
+// IDs is returned.
+func loadLastStreamID(rewriter *Rewriter, key string) (streamID, error) {
 	ctx, cancel := context.WithCancel(context.Background())
-	conn, err := rewriter.internalSubPool.getRaw(ctx)
+	conn, err := rewriter.getInternalConn(ctx)
 	cancel()
 	if err != nil {
-		return nil, err
+		return streamID{}, err
 	}
 	defer conn.Close()
 
-	reply, err := redis.Values(conn.Do("SCRIPT", "EXISTS", lremScript.Hash()))
+	top, err := redis.Values(conn.Do("ZREVRANGE", key, 0, 0, "WITHSCORES"))
 	if err != nil {
-		return nil, err
+		return streamID{}, err
+	}
+	if len(top) < 2 {
+		return streamID{}, nil
 	}
 
-	var scriptExists int
-	_, err = redis.Scan(reply, &scriptExists)
+	members, err := redis.ByteSlices(conn.Do("ZRANGEBYSCORE", key, top[1], top[1]))
 	if err != nil {
-		return nil, err
+		return streamID{}, err
 	}
 
-	if scriptExists == 0 {
-		err = lremScript.Load(conn)
+	var last streamID
+	for _, member := range members {
+		var entry emulatedStreamEntry
+		err = json.Unmarshal(member, &entry)
 		if err != nil {
-			return nil, err
+			return streamID{}, err
+		}
+
+		id, _, err := parseStreamID(entry.ID)
+		if err != nil {
+			return streamID{}, err
 		}
+		if last.Less(id) {
+			last = id
+		}
+	}
+
+	return last, nil
+}
+
+// XlenCommandTransformer emulates the XLEN Redis command for streams
+// emulated by XaddCommandTransformer. The number of entries is the
+// cardinality of the sorted set at key.
+func XlenCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	if len(args) != 1 {
+		return nil, ErrInvalidSyntax
 	}
 
 	return SendLedisFunc(func(ledisConn redis.Conn) (Slot, error) {
-		err := lremScript.SendHash(ledisConn, listKey, tempListKey, args[1], args[2])
+		err := ledisConn.Send("ZCARD", args[0])
 		if err != nil {
 			return Slot{}, err
 		}
@@ -600,258 +916,4167 @@ func LremCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []in
 	}), nil
 }
 
-func ZaddCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
-	commandInfo, err := parseZaddCommand(args)
-	if err != nil {
-		return nil, err
-	}
+var (
+	functionNotSupportedTransformerInstance = NoEmulationTransformer(
+		"Redis Functions require Redis 7.0+ and are not supported by LedisDB",
+	)
+)
 
-	if commandInfo.XXSet && commandInfo.NXSet {
-		return nil, ErrInvalidArgumentCombination
-	}
+// FunctionNotSupportedTransformer is the TransformFunc of all Redis function
+// commands (FUNCTION, FCALL and FCALL_RO). Functions are not implemented in
+// LedisDB, the TransformFunc always returns an error wrapping
+// ErrNoEmulationPossible.
+func FunctionNotSupportedTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	return functionNotSupportedTransformerInstance(rewriter, command, args)
+}
 
-	if commandInfo.XXSet || commandInfo.NXSet || commandInfo.CHSet {
-		return nil, ErrNoEmulationPossible
-	}
+type KeyTypeAggregation struct {
+	None []string
+	KV   []string
+	List []string
+	Hash []string
+	Set  []string
+	ZSet []string
+}
 
-	if commandInfo.INCRSet {
-		if len(args)-(commandInfo.NumFlags+1) != 2 {
-			return nil, ErrInvalidSyntax
+func (k *KeyTypeAggregation) AppendKeys(typesInfo []TypeInfo) {
+	for i := range typesInfo {
+		switch typesInfo[i].Type {
+		case LedisTypeNone:
+			k.None = append(k.None, typesInfo[i].Key)
+		case LedisTypeKV:
+			k.KV = append(k.KV, typesInfo[i].Key)
+		case LedisTypeList:
+			k.List = append(k.List, typesInfo[i].Key)
+		case LedisTypeHash:
+			k.Hash = append(k.Hash, typesInfo[i].Key)
+		case LedisTypeSet:
+			k.Set = append(k.Set, typesInfo[i].Key)
+		case LedisTypeZSet:
+			k.ZSet = append(k.ZSet, typesInfo[i].Key)
 		}
 	}
+}
 
-	return SendLedisFunc(func(ledisConn redis.Conn) (Slot, error) {
-		var err error
+type TypeSpecificCommands struct {
+	None string
+	KV   string
+	List string
+	Hash string
+	Set  string
+	ZSet string
+}
 
-		if commandInfo.INCRSet {
-			err = ledisConn.Send(
-				"ZINCRBY",
-				args[0],
-				args[commandInfo.NumFlags+1],
-				args[commandInfo.NumFlags+2],
-			)
+// ForType returns the command for keys of type ledisType.
+func (t *TypeSpecificCommands) ForType(ledisType LedisType) string {
+	switch ledisType {
+	case LedisTypeKV:
+		return t.KV
+	case LedisTypeList:
+		return t.List
+	case LedisTypeHash:
+		return t.Hash
+	case LedisTypeSet:
+		return t.Set
+	case LedisTypeZSet:
+		return t.ZSet
+	default:
+		return t.None
+	}
+}
+
+var (
+	ErrInvalidAggregationValue = errors.New("invalid Aggregation value")
+)
+
+type Aggregation int8
+
+const (
+	AggregationSum Aggregation = iota
+	AggregationCountOne
+	AggregationFirst
+)
+
+// ResolutionStrategy determines how a TypeSpecificBulkTransformer resolves
+// the types of keys.
+type ResolutionStrategy int8
+
+const (
+	// ResolutionStrategyBulk resolves the types of all keys using a Resolver
+	// before sending commands.
+	ResolutionStrategyBulk ResolutionStrategy = iota
+	// ResolutionStrategyLazy uses the cached type of the key for commands
+	// with a single key, skipping the Resolver. On a cache miss, the key is
+	// resolved as with ResolutionStrategyBulk. Commands with multiple keys
+	// are always resolved as with ResolutionStrategyBulk.
+	ResolutionStrategyLazy
+)
+
+type TypeSpecificBulkTransformerConfig struct {
+	Commands            TypeSpecificCommands
+	Debulk              bool
+	Aggregation         Aggregation
+	AppendArgsExtractor ArgsExtractor
+	ResolutionStrategy  ResolutionStrategy
+}
+
+func TypeSpecificBulkTransformer(config *TypeSpecificBulkTransformerConfig) TransformFunc {
+	return TransformFunc(
+		func(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+			var typeInfoArray [12]TypeInfo
+			var extractedArgsArray [12]interface{}
+			var keysArray [12]string
+
+			keyArgs := command.KeyExtractor.AppendArgs(extractedArgsArray[:0], args)
+			keys := rewledisArgs.AppendAsSimpleStrings(keysArray[:0], keyArgs)
+
+			var typesInfo []TypeInfo
+			if config.ResolutionStrategy == ResolutionStrategyLazy && len(keys) == 1 {
+				if keyType, ok := rewriter.loadCachedType(keys[0]); ok {
+					typesInfo = append(typeInfoArray[:0], TypeInfo{
+						Key:  keys[0],
+						Type: keyType,
+					})
+				}
+			}
+
+			if typesInfo == nil {
+				resolver := rewriter.Resolver()
+				ctx, cancel := context.WithCancel(context.Background())
+				var err error
+				typesInfo, err = resolver.ResolveAppend(typeInfoArray[:0], ctx, keys)
+				cancel()
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			var appendArgs []interface{}
+			if config.AppendArgsExtractor != nil {
+				appendArgs = config.AppendArgsExtractor.AppendArgs(extractedArgsArray[:0], args)
+			}
+
+			keyTypeAggregation := KeyTypeAggregation{}
+			keyTypeAggregation.AppendKeys(typesInfo)
+
+			return SendLedisFunc(func(ledisConn redis.Conn) (Slot, error) {
+				repliesCount, err := sendBulkForAllTypes(config, keyTypeAggregation, ledisConn, appendArgs)
+				if err != nil {
+					return Slot{}, err
+				}
+
+				return Slot{
+					RepliesCount: repliesCount,
+					ProcessFunc:  Aggregator(config.Aggregation),
+				}, nil
+			}), nil
+		},
+	)
+}
+
+// Aggregator creates an aggregator, i.e. a function which reduces any number
+// of reply values to a single reply value.
+func Aggregator(aggregation Aggregation) func([]interface{}) (interface{}, error) {
+	return func(replies []interface{}) (interface{}, error) {
+		switch aggregation {
+		case AggregationSum:
+			var sum int64
+			for _, reply := range replies {
+				value, err := redis.Int64(reply, nil)
+				if err != nil {
+					return nil, err
+				}
+				sum += value
+			}
+			return sum, nil
+		case AggregationCountOne:
+			return int64(len(replies)), nil
+		case AggregationFirst:
+			if len(replies) == 0 {
+				return nil, nil
+			}
+			return replies[0], nil
+		default:
+			return nil, ErrInvalidAggregationValue
+		}
+	}
+}
+
+func sendBulkForAllTypes(
+	config *TypeSpecificBulkTransformerConfig,
+	keyTypeAggregation KeyTypeAggregation,
+	ledisConn redis.Conn,
+	appendArgs []interface{},
+) (int, error) {
+	var sentCount, repliesCount int
+	var err error
+
+	sentCount, err = sendBulk(config.Commands.None, keyTypeAggregation.None, ledisConn, config.Debulk, appendArgs)
+	if err != nil {
+		return repliesCount, err
+	}
+	repliesCount += sentCount
+	sentCount, err = sendBulk(config.Commands.KV, keyTypeAggregation.KV, ledisConn, config.Debulk, appendArgs)
+	if err != nil {
+		return repliesCount, err
+	}
+	repliesCount += sentCount
+	sentCount, err = sendBulk(config.Commands.List, keyTypeAggregation.List, ledisConn, config.Debulk, appendArgs)
+	if err != nil {
+		return repliesCount, err
+	}
+	repliesCount += sentCount
+	sentCount, err = sendBulk(config.Commands.Hash, keyTypeAggregation.Hash, ledisConn, config.Debulk, appendArgs)
+	if err != nil {
+		return repliesCount, err
+	}
+	repliesCount += sentCount
+	sentCount, err = sendBulk(config.Commands.Set, keyTypeAggregation.Set, ledisConn, config.Debulk, appendArgs)
+	if err != nil {
+		return repliesCount, err
+	}
+	repliesCount += sentCount
+	sentCount, err = sendBulk(config.Commands.ZSet, keyTypeAggregation.ZSet, ledisConn, config.Debulk, appendArgs)
+	if err != nil {
+		return repliesCount, err
+	}
+	repliesCount += sentCount
+
+	return repliesCount, nil
+}
+
+func sendBulk(command string, keys []string, conn redis.Conn, debulk bool, appendArgs []interface{}) (int, error) {
+	var sentCount int
+	var argsArray [12]interface{}
+
+	if len(command) > 0 && len(keys) > 0 {
+		if debulk {
+			for _, key := range keys {
+				sentCount++
+
+				args := append(argsArray[:0], key)
+				args = append(args, appendArgs...)
+
+				err := conn.Send(command, args...)
+				if err != nil {
+					return sentCount, err
+				}
+			}
+		} else {
+			sentCount++
+
+			args := append(argsArray[:0], make([]interface{}, len(keys))...)
+			for i := range keys {
+				args[i] = keys[i]
+			}
+			args = append(args, appendArgs...)
+
+			err := conn.Send(command, args...)
+			if err != nil {
+				return sentCount, err
+			}
+		}
+	}
+
+	return sentCount, nil
+}
+
+// chainProcessFunc returns a SendLedisFunc which behaves like sendLedisFunc.
+// However, the reply produced by the ProcessFunc of the returned Slot is
+// passed on to processFunc, which may inspect or replace the reply. processFunc
+// is not called if the original ProcessFunc returns an error.
+func chainProcessFunc(
+	sendLedisFunc SendLedisFunc,
+	processFunc func(reply interface{}) (interface{}, error),
+) SendLedisFunc {
+	return SendLedisFunc(func(ledisConn redis.Conn) (Slot, error) {
+		slot, err := sendLedisFunc(ledisConn)
+		if err != nil {
+			return Slot{}, err
+		}
+
+		innerProcessFunc := slot.ProcessFunc
+		slot.ProcessFunc = func(replies []interface{}) (interface{}, error) {
+			reply, err := innerProcessFunc(replies)
+			if err != nil {
+				return nil, err
+			}
+
+			return processFunc(reply)
+		}
+
+		return slot, nil
+	})
+}
+
+var persistBulkTransformer = TypeSpecificBulkTransformer(&TypeSpecificBulkTransformerConfig{
+	Commands: TypeSpecificCommands{
+		KV:   "PERSIST",
+		List: "LPERSIST",
+		Hash: "HPERSIST",
+		Set:  "SPERSIST",
+		ZSet: "ZPERSIST",
+	},
+	Aggregation: AggregationSum,
+})
+
+// PersistCommandTransformer performs transformations for the PERSIST Redis
+// command.
+//
+// PERSIST does not change the type of key. Nonetheless, the cache entry of
+// key is rewritten after the command succeeded in order to refresh its
+// WrittenAt timestamp.
+func PersistCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	if len(args) != 1 {
+		return nil, ErrInvalidSyntax
+	}
+
+	sendLedisFunc, err := persistBulkTransformer(rewriter, command, args)
+	if err != nil {
+		return nil, err
+	}
+
+	key := rewledisArgs.AsSimpleString(args[0])
+
+	return chainProcessFunc(sendLedisFunc, func(reply interface{}) (interface{}, error) {
+		if _, ok := reply.(redis.Error); ok {
+			return reply, nil
+		}
+
+		// The version is loaded first, so that the entry is not refreshed
+		// if it is updated concurrently.
+		version := rewriter.loadCacheVersion(key)
+		if keyType, ok := rewriter.loadCachedType(key); ok {
+			rewriter.setCacheEntryIfVersion(key, CacheEntryStateExists, keyType, version)
+		}
+
+		return reply, nil
+	}), nil
+}
+
+// BitposCommandTransformer performs transformations for the BITPOS Redis
+// command.
+//
+// LedisDB does not support the BYTE|BIT range type modifier introduced in
+// Redis 7.0. BYTE is the default range type, so the modifier is removed when
+// passing on the command to LedisDB. For BIT, ErrNoEmulationPossible is
+// returned.
+func BitposCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	if len(args) < 2 || len(args) > 5 {
+		return nil, ErrInvalidSyntax
+	}
+
+	if len(args) < 5 {
+		return noneTransformerInstance(rewriter, command, args)
+	}
+
+	argInfo := rewledisArgs.Parse(args[4])
+	if !argInfo.IsStringLike() {
+		return nil, ErrInvalidArgumentType
+	}
+
+	if argInfo.EqualFoldEither(stringBYTE, bytesBYTE) {
+		return noneTransformerInstance(rewriter, command, args[:4])
+	} else if argInfo.EqualFoldEither(stringBIT, bytesBIT) {
+		return nil, ErrNoEmulationPossible
+	} else {
+		return nil, ErrInvalidSyntax
+	}
+}
+
+var sortBulkTransformer = TypeSpecificBulkTransformer(&TypeSpecificBulkTransformerConfig{
+	Commands: TypeSpecificCommands{
+		List: "XLSORT",
+		Set:  "XSSORT",
+		ZSet: "XZSORT",
+	},
+	Aggregation:         AggregationFirst,
+	AppendArgsExtractor: ArgsFromIndex(1),
+})
+
+// SortCommandTransformer performs transformations for the SORT Redis
+// command.
+//
+// The command is passed on to the type specific sort command of LedisDB.
+// When STORE is passed, the cache entry of the destination key is updated
+// after the command succeeded. The destination is a list if any elements
+// were stored and deleted otherwise.
+//
+// BY and GET patterns reference external keys. Before the command is issued,
+// the elements of key are retrieved and the patterns are expanded. All
+// referenced keys which exist must be strings, otherwise
+// ErrInvalidPatternKeyType is returned. Patterns referencing hash fields
+// ("->") are not supported and result in ErrNoEmulationPossible.
+func SortCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	commandInfo, err := parseSortCommand(args)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(commandInfo.Patterns) > 0 {
+		err = validateSortPatterns(rewriter, args[0], commandInfo.Patterns)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sendLedisFunc, err := sortBulkTransformer(rewriter, command, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if !commandInfo.STORESet {
+		return sendLedisFunc, nil
+	}
+
+	// The cache entry of the destination is only updated if it has not been
+	// updated since the command was issued.
+	version := rewriter.loadCacheVersion(commandInfo.STORE)
+
+	return chainProcessFunc(sendLedisFunc, func(reply interface{}) (interface{}, error) {
+		if _, ok := reply.(redis.Error); ok {
+			return reply, nil
+		}
+
+		storedCount, err := redis.Int64(reply, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if storedCount > 0 {
+			rewriter.setCacheEntryIfVersion(commandInfo.STORE, CacheEntryStateExists, LedisTypeList, version)
+		} else {
+			rewriter.setCacheEntryIfVersion(commandInfo.STORE, CacheEntryStateDeleted, LedisTypeNone, version)
+		}
+
+		return reply, nil
+	}), nil
+}
+
+// validateSortPatterns expands patterns for all elements of the collection
+// stored at keyArg and checks the types of the referenced keys.
+func validateSortPatterns(rewriter *Rewriter, keyArg interface{}, patterns []string) error {
+	var expandingPatterns []string
+	for _, pattern := range patterns {
+		if strings.Contains(pattern, "->") {
+			return ErrNoEmulationPossible
+		}
+		if strings.Contains(pattern, "*") {
+			expandingPatterns = append(expandingPatterns, pattern)
+		}
+	}
+
+	if len(expandingPatterns) == 0 {
+		return nil
+	}
+
+	keyType, err := resolveKeyType(rewriter, keyArg)
+	if err != nil {
+		return err
+	}
+
+	var elementsCommand string
+	var elementsArgs []interface{}
+	switch keyType {
+	case LedisTypeNone:
+		return nil
+	case LedisTypeList:
+		elementsCommand = "LRANGE"
+		elementsArgs = []interface{}{keyArg, 0, -1}
+	case LedisTypeSet:
+		elementsCommand = "SMEMBERS"
+		elementsArgs = []interface{}{keyArg}
+	case LedisTypeZSet:
+		elementsCommand = "ZRANGE"
+		elementsArgs = []interface{}{keyArg, 0, -1}
+	default:
+		// Sorting is not possible, LedisDB reports the error.
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	conn, err := rewriter.getInternalConn(ctx)
+	cancel()
+	if err != nil {
+		return err
+	}
+	elements, err := redis.Strings(conn.Do(elementsCommand, elementsArgs...))
+	conn.Close()
+	if err != nil {
+		return err
+	}
+
+	referencedKeys := make([]string, 0, len(elements)*len(expandingPatterns))
+	for _, pattern := range expandingPatterns {
+		for _, element := range elements {
+			referencedKeys = append(referencedKeys, strings.Replace(pattern, "*", element, 1))
+		}
+	}
+
+	resolver := rewriter.Resolver()
+	ctx, cancel = context.WithCancel(context.Background())
+	typesInfo, err := resolver.ResolveAppend(nil, ctx, referencedKeys)
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	for i := range typesInfo {
+		if typesInfo[i].Type != LedisTypeNone && typesInfo[i].Type != LedisTypeKV {
+			return ErrInvalidPatternKeyType
+		}
+	}
+
+	return nil
+}
+
+type sortCommandInfo struct {
+	// Patterns contains all BY and GET patterns.
+	Patterns []string
+	STORESet bool
+	STORE    string
+}
+
+func parseSortCommand(args []interface{}) (info sortCommandInfo, err error) {
+	if len(args) < 1 {
+		err = ErrInvalidSyntax
+		return
+	}
+
+	for i := 1; i < len(args); i++ {
+		argInfo := rewledisArgs.Parse(args[i])
+
+		if !argInfo.IsStringLike() {
+			err = ErrInvalidArgumentType
+			return
+		}
+
+		if argInfo.EqualFoldEither(stringBY, bytesBY) ||
+			argInfo.EqualFoldEither(stringGET, bytesGET) {
+			if i+1 >= len(args) {
+				err = ErrInvalidSyntax
+				return
+			}
+
+			i++
+			valueInfo := rewledisArgs.Parse(args[i])
+			var pattern string
+			pattern, err = valueInfo.ConvertToRedisString()
+			if err != nil {
+				return
+			}
+			info.Patterns = append(info.Patterns, pattern)
+		} else if argInfo.EqualFoldEither(stringLIMIT, bytesLIMIT) {
+			if i+2 >= len(args) {
+				err = ErrInvalidSyntax
+				return
+			}
+
+			i += 2
+		} else if argInfo.EqualFoldEither(stringSTORE, bytesSTORE) {
+			if i+1 >= len(args) {
+				err = ErrInvalidSyntax
+				return
+			}
+
+			i++
+			valueInfo := rewledisArgs.Parse(args[i])
+			info.STORE, err = valueInfo.ConvertToRedisString()
+			if err != nil {
+				return
+			}
+			info.STORESet = true
+		} else if argInfo.EqualFoldEither(stringASC, bytesASC) ||
+			argInfo.EqualFoldEither(stringDESC, bytesDESC) ||
+			argInfo.EqualFoldEither(stringALPHA, bytesALPHA) {
+			continue
+		} else {
+			err = ErrInvalidSyntax
+			return
+		}
+	}
+
+	return
+}
+
+// ScanCommandTransformer performs transformations for the SCAN Redis
+// command.
+//
+// The cursor passed by the client is decoded into a ScanCursorState. XSCAN is
+// issued for the keyspace and LedisDB cursor stored in the state. The cursor
+// returned to the client encodes the following state. It is "0" only after
+// all keyspaces have been scanned.
+func ScanCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	if len(args) < 1 {
+		return nil, ErrInvalidSyntax
+	}
+
+	cursorInfo := rewledisArgs.Parse(args[0])
+	cursor, err := cursorInfo.ConvertToRedisString()
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := DecodeScanCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validateScanOptions(args[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	return SendLedisFunc(func(ledisConn redis.Conn) (Slot, error) {
+		var xscanArgsArray [8]interface{}
+
+		xscanArgs := append(xscanArgsArray[:0], state.LedisType(), state.Cursor)
+		xscanArgs = append(xscanArgs, args[1:]...)
+
+		err := ledisConn.Send("XSCAN", xscanArgs...)
+		if err != nil {
+			return Slot{}, err
+		}
+
+		return Slot{
+			RepliesCount: 1,
+			ProcessFunc: func(replies []interface{}) (interface{}, error) {
+				if err, ok := replies[0].(redis.Error); ok {
+					return err, nil
+				}
+
+				ledisCursor, keys, err := replyutil.NormalizeScanReply(replies[0])
+				if err != nil {
+					return nil, err
+				}
+
+				nextState := state.Advance(ledisCursor)
+
+				return replyutil.ScanReply(nextState.Encode(), keys), nil
+			},
+		}, nil
+	}), nil
+}
+
+// HscanCommandTransformer performs transformations for the HSCAN Redis
+// command.
+//
+// The cursor is translated, see keyScanTransformer.
+func HscanCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	return keyScanTransformer(rewriter, command, args)
+}
+
+// SscanCommandTransformer performs transformations for the SSCAN Redis
+// command.
+//
+// The cursor is translated, see keyScanTransformer.
+func SscanCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	return keyScanTransformer(rewriter, command, args)
+}
+
+// ZscanCommandTransformer performs transformations for the ZSCAN Redis
+// command.
+//
+// The cursor is translated, see keyScanTransformer.
+func ZscanCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	return keyScanTransformer(rewriter, command, args)
+}
+
+// keyScanCursorPrefix is prepended to the cursors returned by
+// keyScanTransformer, see there.
+const keyScanCursorPrefix = "c"
+
+// keyScanTransformer performs transformations for the SCAN family commands
+// iterating over the elements of a single key.
+//
+// Clients may pass the cursor as an integer or a string. LedisDB uses the
+// last element returned as the cursor and the empty string to start and end
+// an iteration, while Redis uses "0". The empty cursor returned by LedisDB at
+// the end of an iteration is translated to "0". All other cursors are
+// prefixed with keyScanCursorPrefix, so that an element "0" is not mistaken
+// for the end of the iteration. Clients' cursors are translated back, "0" is
+// translated to the empty string. Other cursors lacking the prefix result in
+// ErrInvalidScanCursor.
+func keyScanTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	if len(args) < 2 {
+		return nil, ErrInvalidSyntax
+	}
+
+	cursorInfo := rewledisArgs.Parse(args[1])
+	cursor, err := cursorInfo.ConvertToRedisString()
+	if err != nil {
+		return nil, err
+	}
+	if cursor == "0" {
+		cursor = ""
+	} else if strings.HasPrefix(cursor, keyScanCursorPrefix) {
+		cursor = cursor[len(keyScanCursorPrefix):]
+	} else {
+		return nil, ErrInvalidScanCursor
+	}
+
+	err = validateScanOptions(args[2:])
+	if err != nil {
+		return nil, err
+	}
+
+	return SendLedisFunc(func(ledisConn redis.Conn) (Slot, error) {
+		var scanArgsArray [8]interface{}
+
+		scanArgs := append(scanArgsArray[:0], args[0], cursor)
+		scanArgs = append(scanArgs, args[2:]...)
+
+		err := ledisConn.Send(command.Name, scanArgs...)
+		if err != nil {
+			return Slot{}, err
+		}
+
+		return Slot{
+			RepliesCount: 1,
+			ProcessFunc: func(replies []interface{}) (interface{}, error) {
+				if err, ok := replies[0].(redis.Error); ok {
+					return err, nil
+				}
+
+				ledisCursor, elements, err := replyutil.NormalizeScanReply(replies[0])
+				if err != nil {
+					return nil, err
+				}
+				if len(ledisCursor) == 0 {
+					ledisCursor = "0"
+				} else {
+					ledisCursor = keyScanCursorPrefix + ledisCursor
+				}
+
+				return replyutil.ScanReply(ledisCursor, elements), nil
+			},
+		}, nil
+	}), nil
+}
+
+// validateScanOptions validates the MATCH and COUNT options of SCAN family
+// commands. The options are passed on to LedisDB unchanged.
+func validateScanOptions(args []interface{}) error {
+	for i := 0; i < len(args); i++ {
+		argInfo := rewledisArgs.Parse(args[i])
+
+		if !argInfo.IsStringLike() {
+			return ErrInvalidArgumentType
+		}
+
+		if argInfo.EqualFoldEither(stringMATCH, bytesMATCH) ||
+			argInfo.EqualFoldEither(stringCOUNT, bytesCOUNT) {
+			if i+1 >= len(args) {
+				return ErrInvalidSyntax
+			}
+
+			i++
+		} else {
+			return ErrInvalidSyntax
+		}
+	}
+
+	return nil
+}
+
+// SetCommandTransformer performs transformations for the SET Redis
+// command.
+//
+// This transformer mirrors Redis behaviour: When both XX and EX is supplied,
+// nil is returned without applying any changes. When both EX and PX is
+// supplied, the PX value takes precedence.
+func SetCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	commandInfo, err := parseSetCommand(args)
+	if err != nil {
+		return nil, err
+	}
+
+	if commandInfo.XXSet && commandInfo.NXSet {
+		return nil, ErrInvalidArgumentCombination
+	}
+	if commandInfo.EXSet && commandInfo.PXSet {
+		return nil, ErrInvalidArgumentCombination
+	}
+
+	expSet := commandInfo.EXSet
+	expDuration := commandInfo.EX
+	if commandInfo.PXSet {
+		expSet = true
+		// Round up the expiration duration (ignoring overflow)
+		expDuration = (commandInfo.PX + 999) / 1000
+	}
+
+	if commandInfo.XXSet {
+		return nil, ErrNoEmulationPossible
+	}
+	// if expSet && commandInfo.NXSet {
+	// 	// Emulation subject to race-conditions
+	// }
+
+	return SendLedisFunc(func(ledisConn redis.Conn) (Slot, error) {
+		var err error
+		var repliesCount int
+		var transformNX bool
+
+		if commandInfo.NXSet {
+			transformNX = true
+			repliesCount++
+			err = ledisConn.Send("SETNX", args[0], args[1])
+			if err != nil {
+				return Slot{}, err
+			}
+
+			if expSet {
+				repliesCount++
+				err = ledisConn.Send("EXPIRE", args[0], expDuration)
+				if err != nil {
+					return Slot{}, err
+				}
+			}
+		} else if expSet {
+			repliesCount++
+			err = ledisConn.Send("SETEX", args[0], expDuration, args[1])
+			if err != nil {
+				return Slot{}, err
+			}
+		} else {
+			repliesCount++
+			err = ledisConn.Send("SET", args[0], args[1])
+			if err != nil {
+				return Slot{}, err
+			}
+		}
+
+		return Slot{
+			RepliesCount: repliesCount,
+			ProcessFunc: func(replies []interface{}) (interface{}, error) {
+				if transformNX {
+					wasSet, err := redis.Bool(replies[0], nil)
+					if err != nil {
+						return nil, err
+					}
+					if !wasSet {
+						return nil, nil
+					}
+					return "OK", nil
+				}
+
+				return replies[0], nil
+			},
+		}, nil
+	}), nil
+}
+
+type setCommandInfo struct {
+	EXSet bool
+	EX    int64
+	PXSet bool
+	PX    int64
+	NXSet bool
+	XXSet bool
+}
+
+func parseSetCommand(args []interface{}) (info setCommandInfo, err error) {
+	if len(args) < 2 {
+		err = ErrInvalidSyntax
+		return
+	}
+
+	for i := 2; i < len(args); i++ {
+		argInfo := rewledisArgs.Parse(args[i])
+
+		if !argInfo.IsStringLike() {
+			err = ErrInvalidArgumentType
+			return
+		}
+
+		if argInfo.EqualFoldEither(stringEX, bytesEX) {
+			if i+1 >= len(args) {
+				err = ErrInvalidSyntax
+				return
+			}
+
+			i++
+			valueInfo := rewledisArgs.Parse(args[i])
+			info.EX, err = valueInfo.ConvertToInt()
+			if err != nil {
+				return
+			}
+			info.EXSet = true
+		} else if argInfo.EqualFoldEither(stringPX, bytesPX) {
+			if i+1 >= len(args) {
+				err = ErrInvalidSyntax
+				return
+			}
+
+			i++
+			valueInfo := rewledisArgs.Parse(args[i])
+			info.PX, err = valueInfo.ConvertToInt()
+			if err != nil {
+				return
+			}
+			info.PXSet = true
+		} else if argInfo.EqualFoldEither(stringNX, bytesNX) {
+			info.NXSet = true
+		} else if argInfo.EqualFoldEither(stringXX, bytesXX) {
+			info.XXSet = true
+		} else {
+			err = ErrInvalidSyntax
+			return
+		}
+	}
+
+	return
+}
+
+var lremScript = redis.NewScript(2, `
+local function reverse(arr)
+	local i, j = 1, #arr
+
+	while i < j do
+		arr[i], arr[j] = arr[j], arr[i]
+
+		i = i + 1
+		j = j - 1
+	end
+end
+
+local listKey = KEYS[1]
+local tempListKey = KEYS[2]
+local count = tonumber(ARGV[1])
+local value = ARGV[2]
+
+local removedCount = 0
+local processed = 0
+local tempListLen = 0
+local listLen = ledis.call('LLEN', listKey)
+
+if listLen == 0
+then
+	return 0
+end
+
+if count >= 0
+then
+	for i = 0, listLen - 1, 1 do
+		element = ledis.call('LPOP', listKey)
+		processed = processed + 1
+
+		if element == value
+		then
+			removedCount = removedCount + 1
+			if removedCount == count
+			then
+				break
+			end
+		else
+			ledis.call('RPUSH', tempListKey, element)
+			tempListLen = tempListLen + 1
+		end
+	end
+
+	if processed < listLen
+	then
+		remainingElements = ledis.call('LRANGE', listKey, 0, -1)
+		ledis.call('RPUSH', tempListKey, unpack(remainingElements))
+		tempListLen = tempListLen + #remainingElements
+	end
+else
+	for i = 0, listLen - 1, 1 do
+		element = ledis.call('LINDEX', listKey, -1)
+		processed = processed + 1
+
+		if element == value
+		then
+			ledis.call('RPOP', listKey)
+			removedCount = removedCount + 1
+			if removedCount == -count
+			then
+				break
+			end
+		else
+			ledis.call('RPOPLPUSH', listKey, tempListKey)
+			tempListLen = tempListLen + 1
+		end
+	end
+
+	if processed < listLen
+	then
+		remainingElements = ledis.call('LRANGE', listKey, 0, -1)
+		reverse(remainingElements)
+		ledis.call('LPUSH', tempListKey, unpack(remainingElements))
+		tempListLen = tempListLen + #remainingElements
+	end
+end
+
+-- move temporary list content to the original key
+
+if tempListLen > 0
+then
+	local tempListContent = ledis.call('LDUMP', tempListKey)
+	local listTTL = ledis.call('LTTL', listKey)
+
+	local restoreTTL = 0
+	if listTTL > -1
+	then
+		restoreTTL = listTTL * 1000
+	end
+
+	ledis.call('RESTORE', listKey, restoreTTL, tempListContent)
+	ledis.call('LCLEAR', tempListKey)
+else
+	ledis.call('LCLEAR', listKey)
+end
+
+return removedCount
+`)
+
+func LremCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	if len(args) < 3 {
+		return nil, ErrInvalidSyntax
+	}
+
+	argInfo := rewledisArgs.Parse(args[0])
+	listKey, err := argInfo.ConvertToRedisString()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	tempListKey := fmt.Sprintf("rewledis:temp:%d%d:%s", now.Unix(), now.Nanosecond(), listKey)
+
+	err = loadScript(rewriter, lremScript)
+	if err != nil {
+		return nil, err
+	}
+
+	return SendLedisFunc(func(ledisConn redis.Conn) (Slot, error) {
+		err := lremScript.SendHash(ledisConn, listKey, tempListKey, args[1], args[2])
+		if err != nil {
+			return Slot{}, err
+		}
+
+		return Slot{
+			RepliesCount: 1,
+			ProcessFunc: func(replies []interface{}) (interface{}, error) {
+				return replies[0], nil
+			},
+		}, nil
+	}), nil
+}
+
+var errPopCountNotPositive = redis.Error("ERR value is out of range, must be positive")
+
+var popCountScript = redis.NewScript(1, `
+local popCommand = ARGV[1]
+local count = tonumber(ARGV[2])
+
+if ledis.call('LLEN', KEYS[1]) == 0
+then
+	return false
+end
+
+local elements = {}
+for i = 1, count
+do
+	local element = ledis.call(popCommand, KEYS[1])
+	if not element
+	then
+		break
+	end
+	elements[i] = element
+end
+
+return elements
+`)
+
+// LpopCommandTransformer performs transformations for the LPOP Redis
+// command.
+//
+// Without count, the command is passed on unchanged and a bulk string is
+// returned, as in Redis versions before 6.2. With count, up to count elements
+// are popped atomically by a lua script performing LPOP repeatedly and an
+// array is returned. A nil array is returned if key does not exist.
+func LpopCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	return popCountTransformer(rewriter, command, args)
+}
+
+// RpopCommandTransformer performs transformations for the RPOP Redis
+// command. It works in the same way as LpopCommandTransformer, popping
+// elements from the tail of the list.
+func RpopCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	return popCountTransformer(rewriter, command, args)
+}
+
+// popCountTransformer implements LpopCommandTransformer and
+// RpopCommandTransformer. command.Name is used as the pop command invoked by
+// the lua script.
+func popCountTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	if len(args) == 1 {
+		return noneTransformerInstance(rewriter, command, args)
+	}
+	if len(args) != 2 {
+		return nil, ErrInvalidSyntax
+	}
+
+	countInfo := rewledisArgs.Parse(args[1])
+	count, err := countInfo.ConvertToInt()
+	if err != nil || count < 0 {
+		return replySendLedisFunc(errPopCountNotPositive), nil
+	}
+
+	err = loadScript(rewriter, popCountScript)
+	if err != nil {
+		return nil, err
+	}
+
+	return SendLedisFunc(func(ledisConn redis.Conn) (Slot, error) {
+		err := popCountScript.SendHash(ledisConn, args[0], command.Name, count)
+		if err != nil {
+			return Slot{}, err
+		}
+
+		return Slot{
+			RepliesCount: 1,
+			ProcessFunc: func(replies []interface{}) (interface{}, error) {
+				return replies[0], nil
+			},
+		}, nil
+	}), nil
+}
+
+// loadScript ensures that script is loaded on the LedisDB server. A
+// connection from the internal sub pool of rewriter is used to check for the
+// script and load it if necessary. Afterwards, the script may be invoked
+// using its hash.
+func loadScript(rewriter *Rewriter, script *redis.Script) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	conn, err := rewriter.getInternalConn(ctx)
+	cancel()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	reply, err := redis.Values(conn.Do("SCRIPT", "EXISTS", script.Hash()))
+	if err != nil {
+		return err
+	}
+
+	var scriptExists int
+	_, err = redis.Scan(reply, &scriptExists)
+	if err != nil {
+		return err
+	}
+
+	if scriptExists == 0 {
+		err = script.Load(conn)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+var sintercardScript = redis.NewScript(-1, `
+local limit = tonumber(ARGV[1])
+
+local members = ledis.call('SINTER', unpack(KEYS))
+local count = #members
+
+if limit > 0 and count > limit
+then
+	count = limit
+end
+
+return count
+`)
+
+// SintercardCommandTransformer performs transformations for the SINTERCARD
+// Redis command.
+//
+// The cardinality of the intersection is computed by a lua script performing
+// SINTER and counting the members of the result. No temporary key is
+// created. A LIMIT of 0 means no limit, mirroring Redis behaviour.
+func SintercardCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	commandInfo, err := parseSintercardCommand(args)
+	if err != nil {
+		return nil, err
+	}
+
+	err = loadScript(rewriter, sintercardScript)
+	if err != nil {
+		return nil, err
+	}
+
+	return SendLedisFunc(func(ledisConn redis.Conn) (Slot, error) {
+		scriptArgs := make([]interface{}, 0, commandInfo.NumKeys+2)
+		scriptArgs = append(scriptArgs, commandInfo.NumKeys)
+		scriptArgs = append(scriptArgs, args[1:commandInfo.NumKeys+1]...)
+		scriptArgs = append(scriptArgs, commandInfo.Limit)
+
+		err := sintercardScript.SendHash(ledisConn, scriptArgs...)
+		if err != nil {
+			return Slot{}, err
+		}
+
+		return Slot{
+			RepliesCount: 1,
+			ProcessFunc: func(replies []interface{}) (interface{}, error) {
+				return replies[0], nil
+			},
+		}, nil
+	}), nil
+}
+
+type sintercardCommandInfo struct {
+	NumKeys  int
+	LimitSet bool
+	Limit    int64
+}
+
+func parseSintercardCommand(args []interface{}) (info sintercardCommandInfo, err error) {
+	if len(args) < 2 {
+		err = ErrInvalidSyntax
+		return
+	}
+
+	numKeysInfo := rewledisArgs.Parse(args[0])
+	numKeys, err := numKeysInfo.ConvertToInt()
+	if err != nil {
+		return
+	}
+	if numKeys <= 0 || numKeys > int64(len(args)-1) {
+		err = ErrInvalidSyntax
+		return
+	}
+	info.NumKeys = int(numKeys)
+
+	for i := info.NumKeys + 1; i < len(args); i++ {
+		argInfo := rewledisArgs.Parse(args[i])
+
+		if !argInfo.IsStringLike() {
+			err = ErrInvalidArgumentType
+			return
+		}
+
+		if argInfo.EqualFoldEither(stringLIMIT, bytesLIMIT) {
+			if i+1 >= len(args) {
+				err = ErrInvalidSyntax
+				return
+			}
+
+			i++
+			valueInfo := rewledisArgs.Parse(args[i])
+			info.Limit, err = valueInfo.ConvertToInt()
+			if err != nil {
+				return
+			}
+			if info.Limit < 0 {
+				err = ErrInvalidSyntax
+				return
+			}
+			info.LimitSet = true
+		} else {
+			err = ErrInvalidSyntax
+			return
+		}
+	}
+
+	return
+}
+
+// BlockingReadTimeoutMargin is added to the timeout of blocking commands to
+// obtain the read timeout used while waiting for the reply. This leaves time
+// for the server to reply once the timeout of the command expired.
+const BlockingReadTimeoutMargin = time.Second
+
+// withBlockingTimeout wraps sendLedisFunc, the SendLedisFunc of a blocking
+// command, so that the reply is received using a read timeout matching
+// timeoutArg, the timeout argument of the command in seconds. A timeout of 0
+// blocks indefinitely, the read timeout is disabled in this case.
+func withBlockingTimeout(sendLedisFunc SendLedisFunc, timeoutArg interface{}) (SendLedisFunc, error) {
+	argInfo := rewledisArgs.Parse(timeoutArg)
+	timeoutSeconds, err := argInfo.ConvertToFloat()
+	if err != nil {
+		return nil, err
+	}
+	if timeoutSeconds < 0 {
+		return nil, ErrInvalidSyntax
+	}
+
+	var readTimeout time.Duration
+	if timeoutSeconds > 0 {
+		readTimeout = time.Duration(timeoutSeconds*float64(time.Second)) + BlockingReadTimeoutMargin
+	}
+
+	return SendLedisFunc(func(ledisConn redis.Conn) (Slot, error) {
+		slot, err := sendLedisFunc(ledisConn)
+		if err != nil {
+			return Slot{}, err
+		}
+
+		slot.ReadTimeout = readTimeout
+		slot.ReadTimeoutSet = true
+
+		return slot, nil
+	}), nil
+}
+
+// BrpoplpushCommandTransformer performs transformations for the BRPOPLPUSH
+// Redis command.
+//
+// The command is passed on to LedisDB. The reply is received using a read
+// timeout derived from the timeout argument, see withBlockingTimeout.
+func BrpoplpushCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	if len(args) != 3 {
+		return nil, ErrInvalidSyntax
+	}
+
+	sendLedisFunc, err := noneTransformerInstance(rewriter, command, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return withBlockingTimeout(sendLedisFunc, args[2])
+}
+
+// BlpopCommandTransformer performs transformations for the BLPOP and BRPOP
+// Redis commands.
+//
+// The command is passed on to LedisDB. The reply is received using a read
+// timeout derived from the timeout argument, see withBlockingTimeout.
+func BlpopCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	if len(args) < 2 {
+		return nil, ErrInvalidSyntax
+	}
+
+	sendLedisFunc, err := noneTransformerInstance(rewriter, command, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return withBlockingTimeout(sendLedisFunc, args[len(args)-1])
+}
+
+// LmpopCommandTransformer performs transformations for the LMPOP Redis
+// command.
+//
+// The lengths of all keys are queried using LLEN on an internal connection
+// before the command is issued. Elements are then popped from the first
+// non-empty key by the lua script of LpopCommandTransformer. This emulation
+// is subject to race-conditions. The lengths are queried during the
+// transformation, so if LMPOP is issued after commands have been sent using
+// Send but their replies have not been received, an error reply is returned.
+func LmpopCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	commandInfo, err := parseMpopCommand(args, stringLEFT, bytesLEFT, stringRIGHT, bytesRIGHT)
+	if err != nil {
+		return nil, err
+	}
+
+	if hasPendingReplies(rewriter) {
+		return replySendLedisFunc(errNotPipelinable), nil
+	}
+
+	key, found, err := firstNonEmptyKey(rewriter, "LLEN", args[1:commandInfo.NumKeys+1])
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nilReplySendLedisFunc(), nil
+	}
+
+	popCommand := "LPOP"
+	if !commandInfo.FromFirst {
+		popCommand = "RPOP"
+	}
+
+	err = loadScript(rewriter, popCountScript)
+	if err != nil {
+		return nil, err
+	}
+
+	return SendLedisFunc(func(ledisConn redis.Conn) (Slot, error) {
+		err := popCountScript.SendHash(ledisConn, key, popCommand, commandInfo.Count)
+		if err != nil {
+			return Slot{}, err
+		}
+
+		return Slot{
+			RepliesCount: 1,
+			ProcessFunc: func(replies []interface{}) (interface{}, error) {
+				if err, ok := replies[0].(redis.Error); ok {
+					return err, nil
+				}
+				if replies[0] == nil {
+					return nil, nil
+				}
+
+				elements, err := redis.Values(replies[0], nil)
+				if err != nil {
+					return nil, err
+				}
+
+				if len(elements) == 0 {
+					return nil, nil
+				}
+
+				return []interface{}{key, elements}, nil
+			},
+		}, nil
+	}), nil
+}
+
+// ZmpopCommandTransformer performs transformations for the ZMPOP Redis
+// command.
+//
+// The cardinalities of all keys are queried using ZCARD on an internal
+// connection before the command is issued. Members are then popped from the
+// first non-empty key by the lua script of ZpopminCommandTransformer and
+// ZpopmaxCommandTransformer. This emulation is subject to race-conditions.
+// As for LMPOP, an error reply is returned if ZMPOP is issued while replies
+// are pending.
+func ZmpopCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	commandInfo, err := parseMpopCommand(args, stringMIN, bytesMIN, stringMAX, bytesMAX)
+	if err != nil {
+		return nil, err
+	}
+
+	if hasPendingReplies(rewriter) {
+		return replySendLedisFunc(errNotPipelinable), nil
+	}
+
+	key, found, err := firstNonEmptyKey(rewriter, "ZCARD", args[1:commandInfo.NumKeys+1])
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nilReplySendLedisFunc(), nil
+	}
+
+	rangeCommand, from, to := "ZRANGEBYSCORE", "-inf", "+inf"
+	if !commandInfo.FromFirst {
+		rangeCommand, from, to = "ZREVRANGEBYSCORE", "+inf", "-inf"
+	}
+
+	err = loadScript(rewriter, zpopScript)
+	if err != nil {
+		return nil, err
+	}
+
+	return SendLedisFunc(func(ledisConn redis.Conn) (Slot, error) {
+		err := zpopScript.SendHash(ledisConn, key, rangeCommand, commandInfo.Count, from, to)
+		if err != nil {
+			return Slot{}, err
+		}
+
+		return Slot{
+			RepliesCount: 1,
+			ProcessFunc: func(replies []interface{}) (interface{}, error) {
+				if err, ok := replies[0].(redis.Error); ok {
+					return err, nil
+				}
+
+				values, err := redis.Values(replies[0], nil)
+				if err != nil {
+					return nil, err
+				}
+
+				if len(values) == 0 {
+					return nil, nil
+				}
+
+				elements := make([]interface{}, 0, len(values)/2)
+				for i := 0; i+1 < len(values); i += 2 {
+					elements = append(elements, []interface{}{values[i], values[i+1]})
+				}
+
+				return []interface{}{key, elements}, nil
+			},
+		}, nil
+	}), nil
+}
+
+var zpopScript = redis.NewScript(1, `
+local rangeCommand = ARGV[1]
+local count = tonumber(ARGV[2])
+
+local values = ledis.call(rangeCommand, KEYS[1], ARGV[3], ARGV[4], 'WITHSCORES', 'LIMIT', 0, count)
+
+local members = {}
+for i = 1, #values, 2
+do
+	members[#members + 1] = values[i]
+end
+
+if #members > 0
+then
+	ledis.call('ZREM', KEYS[1], unpack(members))
+end
+
+return values
+`)
+
+// ZpopminCommandTransformer performs transformations for the ZPOPMIN Redis
+// command.
+//
+// LedisDB does not implement ZPOPMIN. A lua script fetches up to count
+// members with the lowest scores using ZRANGEBYSCORE and removes them using
+// ZREM. The reply is an array of interleaved members and scores, as in Redis.
+func ZpopminCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	return zpopTransformer(rewriter, args, "ZRANGEBYSCORE", "-inf", "+inf")
+}
+
+// ZpopmaxCommandTransformer performs transformations for the ZPOPMAX Redis
+// command. It works in the same way as ZpopminCommandTransformer, fetching
+// the members with the highest scores using ZREVRANGEBYSCORE.
+func ZpopmaxCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	return zpopTransformer(rewriter, args, "ZREVRANGEBYSCORE", "+inf", "-inf")
+}
+
+// zpopTransformer implements ZpopminCommandTransformer and
+// ZpopmaxCommandTransformer. rangeCommand is invoked by the lua script with
+// the bounds from and to.
+func zpopTransformer(rewriter *Rewriter, args []interface{}, rangeCommand, from, to string) (SendLedisFunc, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return nil, ErrInvalidSyntax
+	}
+
+	count := int64(1)
+	if len(args) == 2 {
+		countInfo := rewledisArgs.Parse(args[1])
+		var err error
+		count, err = countInfo.ConvertToInt()
+		if err != nil || count < 0 {
+			return replySendLedisFunc(errPopCountNotPositive), nil
+		}
+	}
+
+	if count == 0 {
+		return replySendLedisFunc([]interface{}{}), nil
+	}
+
+	err := loadScript(rewriter, zpopScript)
+	if err != nil {
+		return nil, err
+	}
+
+	return SendLedisFunc(func(ledisConn redis.Conn) (Slot, error) {
+		err := zpopScript.SendHash(ledisConn, args[0], rangeCommand, count, from, to)
+		if err != nil {
+			return Slot{}, err
+		}
+
+		return Slot{
+			RepliesCount: 1,
+			ProcessFunc: func(replies []interface{}) (interface{}, error) {
+				return replies[0], nil
+			},
+		}, nil
+	}), nil
+}
+
+type mpopCommandInfo struct {
+	NumKeys int
+	// FromFirst is true if elements are popped from the start of the
+	// collection (LEFT / MIN) and false if popped from the end (RIGHT / MAX).
+	FromFirst bool
+	CountSet  bool
+	Count     int64
+}
+
+// parseMpopCommand parses the arguments of LMPOP and ZMPOP style commands.
+// The strings passed as first and last are the direction arguments
+// accepted for popping from the start or the end of the collection.
+func parseMpopCommand(
+	args []interface{},
+	firstString string, firstBytes []byte,
+	lastString string, lastBytes []byte,
+) (info mpopCommandInfo, err error) {
+	if len(args) < 3 {
+		err = ErrInvalidSyntax
+		return
+	}
+
+	numKeysInfo := rewledisArgs.Parse(args[0])
+	numKeys, err := numKeysInfo.ConvertToInt()
+	if err != nil {
+		return
+	}
+	if numKeys <= 0 || numKeys > int64(len(args)-2) {
+		err = ErrInvalidSyntax
+		return
+	}
+	info.NumKeys = int(numKeys)
+	info.Count = 1
+
+	directionInfo := rewledisArgs.Parse(args[info.NumKeys+1])
+	if !directionInfo.IsStringLike() {
+		err = ErrInvalidArgumentType
+		return
+	}
+
+	if directionInfo.EqualFoldEither(firstString, firstBytes) {
+		info.FromFirst = true
+	} else if directionInfo.EqualFoldEither(lastString, lastBytes) {
+		info.FromFirst = false
+	} else {
+		err = ErrInvalidSyntax
+		return
+	}
+
+	for i := info.NumKeys + 2; i < len(args); i++ {
+		argInfo := rewledisArgs.Parse(args[i])
+
+		if !argInfo.IsStringLike() {
+			err = ErrInvalidArgumentType
+			return
+		}
+
+		if argInfo.EqualFoldEither(stringCOUNT, bytesCOUNT) {
+			if i+1 >= len(args) {
+				err = ErrInvalidSyntax
+				return
+			}
+
+			i++
+			valueInfo := rewledisArgs.Parse(args[i])
+			info.Count, err = valueInfo.ConvertToInt()
+			if err != nil {
+				return
+			}
+			if info.Count <= 0 {
+				err = ErrInvalidSyntax
+				return
+			}
+			info.CountSet = true
+		} else {
+			err = ErrInvalidSyntax
+			return
+		}
+	}
+
+	return
+}
+
+// firstNonEmptyKey issues lengthCommand for each key on a connection of the
+// internal sub pool of rewriter. The first key for which a length greater
+// than 0 is reported is returned. The returned bool is false if all keys are
+// empty.
+func firstNonEmptyKey(rewriter *Rewriter, lengthCommand string, keys []interface{}) (interface{}, bool, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	conn, err := rewriter.getInternalConn(ctx)
+	cancel()
+	if err != nil {
+		return nil, false, err
+	}
+	defer conn.Close()
+
+	for _, key := range keys {
+		err = conn.Send(lengthCommand, key)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	err = conn.Flush()
+	if err != nil {
+		return nil, false, err
+	}
+
+	var nonEmptyKey interface{}
+	var found bool
+
+	for _, key := range keys {
+		length, err := redis.Int64(conn.Receive())
+		if err != nil {
+			return nil, false, err
+		}
+		if !found && length > 0 {
+			nonEmptyKey = key
+			found = true
+		}
+	}
+
+	return nonEmptyKey, found, nil
+}
+
+// nilReplySendLedisFunc returns a SendLedisFunc which does not send any
+// command and produces a nil reply.
+func nilReplySendLedisFunc() SendLedisFunc {
+	return SendLedisFunc(func(_ redis.Conn) (Slot, error) {
+		return Slot{
+			RepliesCount: 0,
+			ProcessFunc: func(_ []interface{}) (interface{}, error) {
+				return nil, nil
+			},
+		}, nil
+	})
+}
+
+// HrandfieldCommandTransformer performs transformations for the
+// HRANDFIELD Redis command.
+//
+// All fields of the hash are retrieved using HKEYS (or HGETALL if
+// WITHVALUES is passed). The random selection is performed while processing
+// the reply. As in Redis, a positive count selects distinct fields while a
+// negative count allows the same field to be selected multiple times.
+//
+// The reply is built in memory. Negative counts below
+// -hrandfieldMaxRepeatedCount result in an error reply, just as Redis rejects
+// negative counts below -(2^63-1)/2.
+func HrandfieldCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	commandInfo, err := parseHrandfieldCommand(args)
+	if err != nil {
+		if replyErr, ok := err.(redis.Error); ok {
+			return replySendLedisFunc(replyErr), nil
+		}
+		return nil, err
+	}
+
+	return SendLedisFunc(func(ledisConn redis.Conn) (Slot, error) {
+		var err error
+
+		if commandInfo.WITHVALUESSet {
+			err = ledisConn.Send("HGETALL", args[0])
+		} else {
+			err = ledisConn.Send("HKEYS", args[0])
+		}
+		if err != nil {
+			return Slot{}, err
+		}
+
+		return Slot{
+			RepliesCount: 1,
+			ProcessFunc: func(replies []interface{}) (interface{}, error) {
+				if err, ok := replies[0].(redis.Error); ok {
+					return err, nil
+				}
+
+				values, err := redis.Values(replies[0], nil)
+				if err != nil {
+					return nil, err
+				}
+
+				return selectRandomFields(values, commandInfo), nil
+			},
+		}, nil
+	}), nil
+}
+
+// selectRandomFields performs the selection of HRANDFIELD. If commandInfo
+// indicates WITHVALUES, values must contain alternating fields and values.
+// Otherwise values must contain fields only.
+func selectRandomFields(values []interface{}, commandInfo hrandfieldCommandInfo) interface{} {
+	stride := 1
+	if commandInfo.WITHVALUESSet {
+		stride = 2
+	}
+	fieldsCount := len(values) / stride
+
+	if !commandInfo.CountSet {
+		if fieldsCount == 0 {
+			return nil
+		}
+
+		return values[rand.Intn(fieldsCount)*stride]
+	}
+
+	if fieldsCount == 0 || commandInfo.Count == 0 {
+		return []interface{}{}
+	}
+
+	var indices []int
+	if commandInfo.Count > 0 {
+		indices = rand.Perm(fieldsCount)
+		if int64(len(indices)) > commandInfo.Count {
+			indices = indices[:commandInfo.Count]
+		}
+	} else {
+		indices = make([]int, -commandInfo.Count)
+		for i := range indices {
+			indices[i] = rand.Intn(fieldsCount)
+		}
+	}
+
+	selected := make([]interface{}, 0, len(indices)*stride)
+	for _, index := range indices {
+		selected = append(selected, values[index*stride:(index+1)*stride]...)
+	}
+
+	return selected
+}
+
+// hrandfieldMaxRepeatedCount is the greatest number of fields selected by
+// HRANDFIELD with a negative count, see HrandfieldCommandTransformer.
+const hrandfieldMaxRepeatedCount = 1 << 24
+
+var errHrandfieldCountOutOfRange = redis.Error("ERR value is out of range")
+
+type hrandfieldCommandInfo struct {
+	CountSet      bool
+	Count         int64
+	WITHVALUESSet bool
+}
+
+func parseHrandfieldCommand(args []interface{}) (info hrandfieldCommandInfo, err error) {
+	if len(args) < 1 || len(args) > 3 {
+		err = ErrInvalidSyntax
+		return
+	}
+
+	if len(args) >= 2 {
+		countInfo := rewledisArgs.Parse(args[1])
+		info.Count, err = countInfo.ConvertToInt()
+		if err != nil {
+			return
+		}
+		if info.Count < -hrandfieldMaxRepeatedCount {
+			err = errHrandfieldCountOutOfRange
+			return
+		}
+		info.CountSet = true
+	}
+
+	if len(args) == 3 {
+		argInfo := rewledisArgs.Parse(args[2])
+
+		if !argInfo.IsStringLike() {
+			err = ErrInvalidArgumentType
+			return
+		}
+
+		if argInfo.EqualFoldEither(stringWITHVALUES, bytesWITHVALUES) {
+			info.WITHVALUESSet = true
+		} else {
+			err = ErrInvalidSyntax
+			return
+		}
+	}
+
+	return
+}
+
+// GeoRadiusNativeCommandTransformer performs transformations for the
+// GEORADIUS Redis command on LedisDB builds implementing GEORADIUS natively.
+// This transformer is not used by default, see GeoRadiusCommandTransformer.
+// It may be installed for a rewriter by registering a copy of
+// RedisCommandGEORADIUS using it:
+//
+//     command := rewledis.RedisCommandGEORADIUS
+//     command.TransformFunc = rewledis.GeoRadiusNativeCommandTransformer
+//     rewriter.RegisterCommand(&command)
+//
+// The command is passed on to LedisDB as is. The items of the reply are
+// normalised to match the format of Redis: Distances are formatted with 4
+// decimal places and coordinates with up to 17 decimal places, both as bulk
+// strings.
+//
+// When STORE or STOREDIST is passed, the cache entry of the destination key
+// is updated after the command succeeded. The destination is a sorted set if
+// any items were stored and deleted otherwise.
+func GeoRadiusNativeCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	commandInfo, err := parseGeoRadiusCommand(args)
+	if err != nil {
+		return nil, err
+	}
+
+	withSet := commandInfo.WITHCOORDSet || commandInfo.WITHDISTSet || commandInfo.WITHHASHSet
+	storeSet := commandInfo.STORESet || commandInfo.STOREDISTSet
+	if withSet && storeSet {
+		return nil, ErrInvalidArgumentCombination
+	}
+
+	sendLedisFunc, err := noneTransformerInstance(rewriter, command, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if storeSet {
+		// The cache entry of the destination is only updated if it has not
+		// been updated since the command was issued.
+		version := rewriter.loadCacheVersion(commandInfo.Destination)
+
+		return chainProcessFunc(sendLedisFunc, func(reply interface{}) (interface{}, error) {
+			if _, ok := reply.(redis.Error); ok {
+				return reply, nil
+			}
+
+			storedCount, err := redis.Int64(reply, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			if storedCount > 0 {
+				rewriter.setCacheEntryIfVersion(commandInfo.Destination, CacheEntryStateExists, LedisTypeZSet, version)
+			} else {
+				rewriter.setCacheEntryIfVersion(commandInfo.Destination, CacheEntryStateDeleted, LedisTypeNone, version)
+			}
+
+			return reply, nil
+		}), nil
+	}
+
+	if !commandInfo.WITHDISTSet && !commandInfo.WITHCOORDSet {
+		return sendLedisFunc, nil
+	}
+
+	return chainProcessFunc(sendLedisFunc, func(reply interface{}) (interface{}, error) {
+		if _, ok := reply.(redis.Error); ok {
+			return reply, nil
+		}
+
+		return normaliseGeoRadiusReply(reply, commandInfo)
+	}), nil
+}
+
+// Error replies returned by the geo command emulation. The messages match
+// those of Redis.
+var (
+	errGeoUnsupportedUnit  = redis.Error("ERR unsupported unit provided. please use M, KM, FT, MI")
+	errGeoNegativeRadius   = redis.Error("ERR radius cannot be negative")
+	errGeoCountNotPositive = redis.Error("ERR COUNT must be > 0")
+	errGeoAnyWithoutCount  = redis.Error("ERR the ANY argument requires COUNT argument")
+)
+
+// parseGeoCoordinates parses a longitude and a latitude argument. An error
+// reply is returned as the third value if the coordinates cannot be
+// encoded.
+func parseGeoCoordinates(longitudeArg, latitudeArg interface{}) (float64, float64, error, error) {
+	longitudeInfo := rewledisArgs.Parse(longitudeArg)
+	longitude, err := longitudeInfo.ConvertToFloat()
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	latitudeInfo := rewledisArgs.Parse(latitudeArg)
+	latitude, err := latitudeInfo.ConvertToFloat()
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	if !geoValidCoordinates(longitude, latitude) {
+		return 0, 0, redis.Error(fmt.Sprintf("ERR invalid longitude,latitude pair %f,%f", longitude, latitude)), nil
+	}
+
+	return longitude, latitude, nil, nil
+}
+
+// parseGeoUnit parses a distance unit argument and returns the length of the
+// unit in metres. An error reply is returned as the second value if the unit
+// is not supported.
+func parseGeoUnit(unitArg interface{}) (float64, error, error) {
+	unitInfo := rewledisArgs.Parse(unitArg)
+	unit, err := unitInfo.ConvertToRedisString()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	factor, ok := geoUnitFactors[strings.ToLower(unit)]
+	if !ok {
+		return 0, errGeoUnsupportedUnit, nil
+	}
+
+	return factor, nil, nil
+}
+
+// GeoaddCommandTransformer emulates the GEOADD Redis command. Each member is
+// added to the sorted set at key with its coordinates encoded as a 52 bit
+// geohash score, just as Redis does. The NX, XX and CH options are not
+// supported, as LedisDB's ZADD lacks them.
+func GeoaddCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	if len(args) < 4 {
+		return nil, ErrInvalidSyntax
+	}
+
+	argInfo := rewledisArgs.Parse(args[1])
+	if argInfo.EqualFoldEither(stringNX, bytesNX) ||
+		argInfo.EqualFoldEither(stringXX, bytesXX) ||
+		argInfo.EqualFoldEither(stringCH, bytesCH) {
+		return nil, ErrNoEmulationPossible
+	}
+
+	if (len(args)-1)%3 != 0 {
+		return nil, ErrInvalidSyntax
+	}
+
+	transformedArgs := make([]interface{}, 0, 1+(len(args)-1)/3*2)
+	transformedArgs = append(transformedArgs, args[0])
+
+	for i := 1; i < len(args); i += 3 {
+		longitude, latitude, replyErr, err := parseGeoCoordinates(args[i], args[i+1])
+		if err != nil {
+			return nil, err
+		}
+		if replyErr != nil {
+			return replySendLedisFunc(replyErr), nil
+		}
+
+		// LedisDB stores sorted set scores as integers.
+		transformedArgs = append(transformedArgs, int64(geoEncode(longitude, latitude)), args[i+2])
+	}
+
+	key := rewledisArgs.AsSimpleString(args[0])
+
+	return SendLedisFunc(func(ledisConn redis.Conn) (Slot, error) {
+		err := ledisConn.Send("ZADD", transformedArgs...)
+		if err != nil {
+			return Slot{}, err
+		}
+
+		return Slot{
+			RepliesCount: 1,
+			ProcessFunc: func(replies []interface{}) (interface{}, error) {
+				if _, ok := replies[0].(redis.Error); ok {
+					return replies[0], nil
+				}
+
+				rewriter.trySetCacheEntry(key, CacheEntryStateExists, LedisTypeZSet)
+
+				return replies[0], nil
+			},
+		}, nil
+	}), nil
+}
+
+// GeodistCommandTransformer emulates the GEODIST Redis command. The scores
+// of both members are retrieved and decoded, the distance between them is
+// computed using the Haversine formula. nil is returned if either member
+// does not exist.
+func GeodistCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	if len(args) != 3 && len(args) != 4 {
+		return nil, ErrInvalidSyntax
+	}
+
+	unitFactor := 1.0
+	if len(args) == 4 {
+		var replyErr, err error
+		unitFactor, replyErr, err = parseGeoUnit(args[3])
+		if err != nil {
+			return nil, err
+		}
+		if replyErr != nil {
+			return replySendLedisFunc(replyErr), nil
+		}
+	}
+
+	return SendLedisFunc(func(ledisConn redis.Conn) (Slot, error) {
+		err := ledisConn.Send("ZSCORE", args[0], args[1])
+		if err != nil {
+			return Slot{}, err
+		}
+		err = ledisConn.Send("ZSCORE", args[0], args[2])
+		if err != nil {
+			return Slot{}, err
+		}
+
+		return Slot{
+			RepliesCount: 2,
+			ProcessFunc: func(replies []interface{}) (interface{}, error) {
+				var coordinates [2][2]float64
+				for i, reply := range replies {
+					if _, ok := reply.(redis.Error); ok {
+						return reply, nil
+					}
+					if reply == nil {
+						return nil, nil
+					}
+
+					bits, err := redis.Uint64(reply, nil)
+					if err != nil {
+						return nil, err
+					}
+					coordinates[i][0], coordinates[i][1] = geoDecode(bits)
+				}
+
+				distance := geoDistance(
+					coordinates[0][0], coordinates[0][1],
+					coordinates[1][0], coordinates[1][1],
+				)
+
+				return []byte(strconv.FormatFloat(distance/unitFactor, 'f', 4, 64)), nil
+			},
+		}, nil
+	}), nil
+}
+
+// geoRadiusItem is a member of a geospatial index found by the GEORADIUS
+// emulation.
+type geoRadiusItem struct {
+	Member    []byte
+	Bits      uint64
+	Longitude float64
+	Latitude  float64
+	// Distance is the distance to the centre of the search in metres.
+	Distance float64
+}
+
+// GeoRadiusCommandTransformer emulates the GEORADIUS Redis command. All
+// members of the sorted set at key are retrieved and their geohash scores
+// decoded. Members within radius of the centre are returned, distances are
+// computed using the Haversine formula. All options of GEORADIUS are
+// supported, except STOREDIST: LedisDB stores sorted set scores as integers,
+// distances cannot be stored. Replies are formatted just as by Redis.
+//
+// The emulation takes O(N) time, where N is the number of members of the
+// sorted set. If STORE is passed, the search and the storing of the result
+// are performed on an internal connection while the command is rewritten.
+//
+// See GeoRadiusNativeCommandTransformer for LedisDB builds implementing
+// GEORADIUS.
+func GeoRadiusCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	commandInfo, err := parseGeoRadiusCommand(args)
+	if err != nil {
+		return nil, err
+	}
+
+	withSet := commandInfo.WITHCOORDSet || commandInfo.WITHDISTSet || commandInfo.WITHHASHSet
+	storeSet := commandInfo.STORESet || commandInfo.STOREDISTSet
+	if withSet && storeSet {
+		return nil, ErrInvalidArgumentCombination
+	}
+	if commandInfo.STOREDISTSet {
+		return nil, fmt.Errorf("%w: GEORADIUS STOREDIST: LedisDB sorted set scores are integers", ErrNoEmulationPossible)
+	}
+
+	longitude, latitude, replyErr, err := parseGeoCoordinates(args[1], args[2])
+	if err != nil {
+		return nil, err
+	}
+	if replyErr != nil {
+		return replySendLedisFunc(replyErr), nil
+	}
+
+	radiusInfo := rewledisArgs.Parse(args[3])
+	radius, err := radiusInfo.ConvertToFloat()
+	if err != nil {
+		return nil, err
+	}
+	if radius < 0 {
+		return replySendLedisFunc(errGeoNegativeRadius), nil
+	}
+
+	unitFactor, replyErr, err := parseGeoUnit(args[4])
+	if err != nil {
+		return nil, err
+	}
+	if replyErr != nil {
+		return replySendLedisFunc(replyErr), nil
+	}
+
+	if commandInfo.COUNTSet && commandInfo.COUNT <= 0 {
+		return replySendLedisFunc(errGeoCountNotPositive), nil
+	}
+	if commandInfo.ANYSet && !commandInfo.COUNTSet {
+		return replySendLedisFunc(errGeoAnyWithoutCount), nil
+	}
+
+	search := func(reply interface{}) ([]geoRadiusItem, error) {
+		return geoRadiusSearch(reply, longitude, latitude, radius*unitFactor, commandInfo)
+	}
+
+	if commandInfo.STORESet {
+		return geoRadiusStore(rewriter, args[0], commandInfo.Destination, search)
+	}
+
+	return SendLedisFunc(func(ledisConn redis.Conn) (Slot, error) {
+		err := ledisConn.Send("ZRANGE", args[0], 0, -1, "WITHSCORES")
+		if err != nil {
+			return Slot{}, err
+		}
+
+		return Slot{
+			RepliesCount: 1,
+			ProcessFunc: func(replies []interface{}) (interface{}, error) {
+				if _, ok := replies[0].(redis.Error); ok {
+					return replies[0], nil
+				}
+
+				items, err := search(replies[0])
+				if err != nil {
+					return nil, err
+				}
+
+				return geoRadiusReply(items, unitFactor, commandInfo), nil
+			},
+		}, nil
+	}), nil
+}
+
+// geoRadiusSearch returns the items of reply, the reply to ZRANGE WITHSCORES
+// on a geospatial index, within radius metres of the centre. The items are
+// sorted and limited as requested in commandInfo.
+func geoRadiusSearch(
+	reply interface{},
+	longitude, latitude, radius float64,
+	commandInfo geoRadiusCommandInfo,
+) ([]geoRadiusItem, error) {
+	values, err := redis.Values(reply, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(values)%2 != 0 {
+		return nil, replyutil.ErrUnexpectedReplyFormat
+	}
+
+	// Redis sorts by distance if COUNT is passed without ANY, even if no
+	// order has been requested.
+	sortSet := commandInfo.ASCSet || commandInfo.DESCSet || (commandInfo.COUNTSet && !commandInfo.ANYSet)
+
+	var items []geoRadiusItem
+	for i := 0; i < len(values); i += 2 {
+		member, err := redis.Bytes(values[i], nil)
+		if err != nil {
+			return nil, err
+		}
+		bits, err := redis.Uint64(values[i+1], nil)
+		if err != nil {
+			return nil, err
+		}
+
+		itemLongitude, itemLatitude := geoDecode(bits)
+		distance := geoDistance(longitude, latitude, itemLongitude, itemLatitude)
+		if distance > radius {
+			continue
+		}
+
+		items = append(items, geoRadiusItem{
+			Member:    member,
+			Bits:      bits,
+			Longitude: itemLongitude,
+			Latitude:  itemLatitude,
+			Distance:  distance,
+		})
+
+		if commandInfo.ANYSet && int64(len(items)) >= commandInfo.COUNT {
+			break
+		}
+	}
+
+	if sortSet {
+		sort.SliceStable(items, func(i, j int) bool {
+			if commandInfo.DESCSet {
+				return items[i].Distance > items[j].Distance
+			}
+			return items[i].Distance < items[j].Distance
+		})
+	}
+
+	if commandInfo.COUNTSet && int64(len(items)) > commandInfo.COUNT {
+		items = items[:commandInfo.COUNT]
+	}
+
+	return items, nil
+}
+
+// geoRadiusReply formats items as Redis formats the reply to GEORADIUS.
+func geoRadiusReply(items []geoRadiusItem, unitFactor float64, commandInfo geoRadiusCommandInfo) []interface{} {
+	reply := make([]interface{}, len(items))
+
+	for i, item := range items {
+		if !commandInfo.WITHDISTSet && !commandInfo.WITHHASHSet && !commandInfo.WITHCOORDSet {
+			reply[i] = item.Member
+			continue
+		}
+
+		// The item layout is: member [dist] [hash] [[longitude latitude]]
+		itemReply := []interface{}{item.Member}
+		if commandInfo.WITHDISTSet {
+			itemReply = append(itemReply, []byte(strconv.FormatFloat(item.Distance/unitFactor, 'f', 4, 64)))
+		}
+		if commandInfo.WITHHASHSet {
+			itemReply = append(itemReply, int64(item.Bits))
+		}
+		if commandInfo.WITHCOORDSet {
+			itemReply = append(itemReply, []interface{}{
+				[]byte(formatGeoCoordinate(item.Longitude)),
+				[]byte(formatGeoCoordinate(item.Latitude)),
+			})
+		}
+
+		reply[i] = itemReply
+	}
+
+	return reply
+}
+
+// geoRadiusStore performs the GEORADIUS emulation with the STORE option. The
+// members of the sorted set at keyArg are read on a connection of the
+// internal sub pool. The found members are stored with their geohash scores
+// in the sorted set at destination, replacing any previous value. The writes
+// are sent by the returned SendLedisFunc, so that they are ordered with other
+// pipelined commands. The SendLedisFunc yields the number of stored members.
+func geoRadiusStore(
+	rewriter *Rewriter,
+	keyArg interface{},
+	destination string,
+	search func(reply interface{}) ([]geoRadiusItem, error),
+) (SendLedisFunc, error) {
+	destinationType, err := resolveKeyType(rewriter, destination)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	conn, err := rewriter.getInternalConn(ctx)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	reply, err := conn.Do("ZRANGE", keyArg, 0, -1, "WITHSCORES")
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := search(reply)
+	if err != nil {
+		return nil, err
+	}
+
+	if destinationType == LedisTypeNone && len(items) == 0 {
+		rewriter.trySetCacheEntry(destination, CacheEntryStateDeleted, LedisTypeNone)
+		return replySendLedisFunc(int64(0)), nil
+	}
+
+	return SendLedisFunc(func(ledisConn redis.Conn) (Slot, error) {
+		repliesCount := 0
+
+		if destinationType != LedisTypeNone {
+			err := ledisConn.Send(moveClearCommands.ForType(destinationType), destination)
+			if err != nil {
+				return Slot{}, err
+			}
+			repliesCount++
+		}
+
+		if len(items) > 0 {
+			zaddArgs := make([]interface{}, 0, 1+2*len(items))
+			zaddArgs = append(zaddArgs, destination)
+			for _, item := range items {
+				zaddArgs = append(zaddArgs, int64(item.Bits), item.Member)
+			}
+
+			err := ledisConn.Send("ZADD", zaddArgs...)
+			if err != nil {
+				return Slot{}, err
+			}
+			repliesCount++
+		}
+
+		return Slot{
+			RepliesCount: repliesCount,
+			ProcessFunc: func(replies []interface{}) (interface{}, error) {
+				for _, reply := range replies {
+					if _, ok := reply.(redis.Error); ok {
+						rewriter.invalidateCacheEntry(destination)
+						return reply, nil
+					}
+				}
+
+				if len(items) > 0 {
+					rewriter.trySetCacheEntry(destination, CacheEntryStateExists, LedisTypeZSet)
+				} else {
+					rewriter.trySetCacheEntry(destination, CacheEntryStateDeleted, LedisTypeNone)
+				}
+
+				return int64(len(items)), nil
+			},
+		}, nil
+	}), nil
+}
+
+func normaliseGeoRadiusReply(reply interface{}, commandInfo geoRadiusCommandInfo) (interface{}, error) {
+	items, err := redis.Values(reply, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range items {
+		item, err := redis.Values(items[i], nil)
+		if err != nil {
+			return nil, err
+		}
+
+		// The item layout is: member [dist] [hash] [[longitude latitude]]
+		index := 1
+		if commandInfo.WITHDISTSet {
+			if index >= len(item) {
+				return nil, ErrInvalidSyntax
+			}
+			dist, err := redis.Float64(item[index], nil)
+			if err != nil {
+				return nil, err
+			}
+			item[index] = []byte(strconv.FormatFloat(dist, 'f', 4, 64))
+			index++
+		}
+		if commandInfo.WITHHASHSet {
+			index++
+		}
+		if commandInfo.WITHCOORDSet {
+			if index >= len(item) {
+				return nil, ErrInvalidSyntax
+			}
+			coords, err := redis.Float64s(item[index], nil)
+			if err != nil {
+				return nil, err
+			}
+			normalisedCoords := make([]interface{}, len(coords))
+			for j, coord := range coords {
+				normalisedCoords[j] = []byte(formatGeoCoordinate(coord))
+			}
+			item[index] = normalisedCoords
+		}
+
+		items[i] = item
+	}
+
+	return items, nil
+}
+
+// formatGeoCoordinate formats coord in the same way as Redis formats
+// coordinates in replies: with 17 decimal places, omitting trailing zeros.
+func formatGeoCoordinate(coord float64) string {
+	formatted := strconv.FormatFloat(coord, 'f', 17, 64)
+	formatted = strings.TrimRight(formatted, "0")
+	formatted = strings.TrimSuffix(formatted, ".")
+
+	return formatted
+}
+
+type geoRadiusCommandInfo struct {
+	WITHCOORDSet bool
+	WITHDISTSet  bool
+	WITHHASHSet  bool
+	COUNTSet     bool
+	COUNT        int64
+	ANYSet       bool
+	ASCSet       bool
+	DESCSet      bool
+	STORESet     bool
+	STOREDISTSet bool
+	// Destination is the key passed to STORE or STOREDIST. If both are
+	// passed, the last one takes precedence, mirroring Redis behaviour.
+	Destination string
+}
+
+func parseGeoRadiusCommand(args []interface{}) (info geoRadiusCommandInfo, err error) {
+	if len(args) < 5 {
+		err = ErrInvalidSyntax
+		return
+	}
+
+	for i := 5; i < len(args); i++ {
+		argInfo := rewledisArgs.Parse(args[i])
+
+		if !argInfo.IsStringLike() {
+			err = ErrInvalidArgumentType
+			return
+		}
+
+		if argInfo.EqualFoldEither(stringWITHCOORD, bytesWITHCOORD) {
+			info.WITHCOORDSet = true
+		} else if argInfo.EqualFoldEither(stringWITHDIST, bytesWITHDIST) {
+			info.WITHDISTSet = true
+		} else if argInfo.EqualFoldEither(stringWITHHASH, bytesWITHHASH) {
+			info.WITHHASHSet = true
+		} else if argInfo.EqualFoldEither(stringCOUNT, bytesCOUNT) {
+			if i+1 >= len(args) {
+				err = ErrInvalidSyntax
+				return
+			}
+
+			i++
+			valueInfo := rewledisArgs.Parse(args[i])
+			info.COUNT, err = valueInfo.ConvertToInt()
+			if err != nil {
+				return
+			}
+			info.COUNTSet = true
+		} else if argInfo.EqualFoldEither(stringANY, bytesANY) {
+			info.ANYSet = true
+		} else if argInfo.EqualFoldEither(stringASC, bytesASC) {
+			info.ASCSet = true
+			info.DESCSet = false
+		} else if argInfo.EqualFoldEither(stringDESC, bytesDESC) {
+			info.DESCSet = true
+			info.ASCSet = false
+		} else if argInfo.EqualFoldEither(stringSTORE, bytesSTORE) {
+			if i+1 >= len(args) {
+				err = ErrInvalidSyntax
+				return
+			}
+
+			i++
+			valueInfo := rewledisArgs.Parse(args[i])
+			info.Destination, err = valueInfo.ConvertToRedisString()
+			if err != nil {
+				return
+			}
+			info.STORESet = true
+		} else if argInfo.EqualFoldEither(stringSTOREDIST, bytesSTOREDIST) {
+			if i+1 >= len(args) {
+				err = ErrInvalidSyntax
+				return
+			}
+
+			i++
+			valueInfo := rewledisArgs.Parse(args[i])
+			info.Destination, err = valueInfo.ConvertToRedisString()
+			if err != nil {
+				return
+			}
+			info.STOREDISTSet = true
+		} else {
+			err = ErrInvalidSyntax
+			return
+		}
+	}
+
+	return
+}
+
+// HgetallSortedCommandTransformer performs transformations for the HGETALL
+// Redis command. The field-value pairs of the reply are sorted by field.
+//
+// Redis does not guarantee any order of the reply, neither does LedisDB. This
+// transformer is not used by default. It may be installed for a rewriter by
+// registering a copy of RedisCommandHGETALL using it:
+//
+//     command := rewledis.RedisCommandHGETALL
+//     command.TransformFunc = rewledis.HgetallSortedCommandTransformer
+//     rewriter.RegisterCommand(&command)
+func HgetallSortedCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	if len(args) != 1 {
+		return nil, ErrInvalidSyntax
+	}
+
+	sendLedisFunc, err := noneTransformerInstance(rewriter, command, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return chainProcessFunc(sendLedisFunc, func(reply interface{}) (interface{}, error) {
+		if _, ok := reply.(redis.Error); ok {
+			return reply, nil
+		}
+
+		values, err := redis.Values(reply, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(values)%2 != 0 {
+			return nil, replyutil.ErrUnexpectedReplyFormat
+		}
+
+		sort.Sort(fieldValuePairs(values))
+
+		return values, nil
+	}), nil
+}
+
+// fieldValuePairs implements sort.Interface for a slice of alternating
+// fields and values, sorting pairs by field.
+type fieldValuePairs []interface{}
+
+func (f fieldValuePairs) Len() int {
+	return len(f) / 2
+}
+
+func (f fieldValuePairs) Less(i, j int) bool {
+	return rewledisArgs.AsSimpleString(f[2*i]) < rewledisArgs.AsSimpleString(f[2*j])
+}
+
+func (f fieldValuePairs) Swap(i, j int) {
+	f[2*i], f[2*j] = f[2*j], f[2*i]
+	f[2*i+1], f[2*j+1] = f[2*j+1], f[2*i+1]
+}
+
+// ZrangebylexCommandTransformer performs transformations for the
+// ZRANGEBYLEX Redis command.
+//
+// The LIMIT clause is not passed on to LedisDB. Instead, the complete range
+// is retrieved and LIMIT is applied to the reply. As in Redis, a negative
+// count returns all elements starting at offset.
+func ZrangebylexCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	commandInfo, err := parseZrangebylexCommand(args)
+	if err != nil {
+		return nil, err
+	}
+
+	if !commandInfo.LIMITSet {
+		return noneTransformerInstance(rewriter, command, args)
+	}
+
+	sendLedisFunc, err := noneTransformerInstance(rewriter, command, args[:3])
+	if err != nil {
+		return nil, err
+	}
+
+	return chainProcessFunc(sendLedisFunc, func(reply interface{}) (interface{}, error) {
+		if _, ok := reply.(redis.Error); ok {
+			return reply, nil
+		}
+
+		values, err := redis.Values(reply, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		return applyLimit(values, commandInfo.Offset, commandInfo.Count), nil
+	}), nil
+}
+
+// ZrevrangebylexCommandTransformer performs transformations for the
+// ZREVRANGEBYLEX Redis command.
+//
+// ZREVRANGEBYLEX is emulated by issuing ZRANGEBYLEX with the max and min
+// bounds swapped. The reply is reversed and the LIMIT clause, if any, is
+// applied to the reversed reply.
+func ZrevrangebylexCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	commandInfo, err := parseZrangebylexCommand(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return SendLedisFunc(func(ledisConn redis.Conn) (Slot, error) {
+		err := ledisConn.Send("ZRANGEBYLEX", args[0], args[2], args[1])
+		if err != nil {
+			return Slot{}, err
+		}
+
+		return Slot{
+			RepliesCount: 1,
+			ProcessFunc: func(replies []interface{}) (interface{}, error) {
+				if err, ok := replies[0].(redis.Error); ok {
+					return err, nil
+				}
+
+				values, err := redis.Values(replies[0], nil)
+				if err != nil {
+					return nil, err
+				}
+
+				for i, j := 0, len(values)-1; i < j; i, j = i+1, j-1 {
+					values[i], values[j] = values[j], values[i]
+				}
+
+				if commandInfo.LIMITSet {
+					values = applyLimit(values, commandInfo.Offset, commandInfo.Count)
+				}
+
+				return values, nil
+			},
+		}, nil
+	}), nil
+}
+
+// applyLimit returns the sub-slice of values selected by a LIMIT offset
+// count clause. A negative count selects all values starting at offset, a
+// negative offset selects no values.
+func applyLimit(values []interface{}, offset, count int64) []interface{} {
+	if offset < 0 || offset >= int64(len(values)) {
+		return values[:0]
+	}
+
+	values = values[offset:]
+	if count >= 0 && count < int64(len(values)) {
+		values = values[:count]
+	}
+
+	return values
+}
+
+type zrangebylexCommandInfo struct {
+	LIMITSet bool
+	Offset   int64
+	Count    int64
+}
+
+func parseZrangebylexCommand(args []interface{}) (info zrangebylexCommandInfo, err error) {
+	if len(args) != 3 && len(args) != 6 {
+		err = ErrInvalidSyntax
+		return
+	}
+
+	if len(args) == 6 {
+		argInfo := rewledisArgs.Parse(args[3])
+
+		if !argInfo.IsStringLike() {
+			err = ErrInvalidArgumentType
+			return
+		}
+		if !argInfo.EqualFoldEither(stringLIMIT, bytesLIMIT) {
+			err = ErrInvalidSyntax
+			return
+		}
+
+		offsetInfo := rewledisArgs.Parse(args[4])
+		info.Offset, err = offsetInfo.ConvertToInt()
+		if err != nil {
+			return
+		}
+
+		countInfo := rewledisArgs.Parse(args[5])
+		info.Count, err = countInfo.ConvertToInt()
+		if err != nil {
+			return
+		}
+
+		info.LIMITSet = true
+	}
+
+	return
+}
+
+func ZaddCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	commandInfo, err := parseZaddCommand(args)
+	if err != nil {
+		return nil, err
+	}
+
+	if commandInfo.XXSet && commandInfo.NXSet {
+		return nil, ErrInvalidArgumentCombination
+	}
+
+	if commandInfo.XXSet || commandInfo.NXSet || commandInfo.CHSet {
+		return nil, ErrNoEmulationPossible
+	}
+
+	if commandInfo.INCRSet {
+		if len(args)-(commandInfo.NumFlags+1) != 2 {
+			return nil, ErrInvalidSyntax
+		}
+	}
+
+	return SendLedisFunc(func(ledisConn redis.Conn) (Slot, error) {
+		var err error
+
+		if commandInfo.INCRSet {
+			err = ledisConn.Send(
+				"ZINCRBY",
+				args[0],
+				args[commandInfo.NumFlags+1],
+				args[commandInfo.NumFlags+2],
+			)
+			if err != nil {
+				return Slot{}, err
+			}
+		} else {
+			var transformedArgsArray [13]interface{}
+
+			transformedArgs := append(transformedArgsArray[:0], args[0])
+			transformedArgs = append(transformedArgs, args[commandInfo.NumFlags+1:]...)
+
+			err = ledisConn.Send("ZADD", transformedArgs...)
+			if err != nil {
+				return Slot{}, err
+			}
+		}
+
+		return Slot{
+			RepliesCount: 1,
+			ProcessFunc: func(replies []interface{}) (interface{}, error) {
+				return replies[0], nil
+			},
+		}, nil
+	}), nil
+}
+
+type zaddCommandInfo struct {
+	NumFlags int
+	NXSet    bool
+	XXSet    bool
+	INCRSet  bool
+	CHSet    bool
+}
+
+func parseZaddCommand(args []interface{}) (info zaddCommandInfo, err error) {
+	if len(args) < 3 {
+		err = ErrInvalidSyntax
+		return
+	}
+
+	for i := 1; i < len(args); i++ {
+		argInfo := rewledisArgs.Parse(args[i])
+
+		if !argInfo.IsStringLike() {
+			break
+		}
+
+		info.NumFlags++
+
+		if argInfo.EqualFoldEither(stringNX, bytesNX) {
+			info.NXSet = true
+		} else if argInfo.EqualFoldEither(stringXX, bytesXX) {
+			info.XXSet = true
+		} else if argInfo.EqualFoldEither(stringINCR, bytesINCR) {
+			info.INCRSet = true
+		} else if argInfo.EqualFoldEither(stringCH, bytesCH) {
+			info.CHSet = true
+		} else {
+			break
+		}
+	}
+
+	if (len(args)-(info.NumFlags+1))%2 != 0 {
+		err = ErrInvalidSyntax
+		return
+	}
+
+	return
+}
+
+// RestoreCommandTransformer performs transformations for the RESTORE Redis
+// command.
+//
+// The IDLETIME and FREQ modifiers are ignored and removed when passing on the
+// command to LedisDB.
+//
+// If the DumpFormat of the rewriter is DumpFormatRedis, the serialised value
+// is transcoded before it is passed on, see transcodeRedisDump. Values which
+// cannot be transcoded result in an error reply.
+//
+// TODO: Figure out how LedisDB performs RESTORE and incorporate into REPLACE
+// handling.
+func RestoreCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	commandInfo, err := parseRestoreCommand(args)
+	if err != nil {
+		return nil, err
+	}
+
+	serialised := args[2]
+	if rewriter.root().DumpFormat == DumpFormatRedis {
+		argInfo := rewledisArgs.Parse(args[2])
+		redisSerialised, err := argInfo.ConvertToRedisBytesString()
+		if err != nil {
+			return nil, err
+		}
+
+		serialised, err = transcodeRedisDump(redisSerialised)
+		if err != nil {
+			return replySendLedisFunc(err), nil
+		}
+	}
+
+	// if commandInfo.REPLACESet {
+	// 	// TODO
+	// }
+
+	var setExpireAt bool
+	var expireAtTimestamp int64
+	if commandInfo.ABSTTLSet {
+		setExpireAt = true
+		argInfo := rewledisArgs.Parse(args[1])
+		expireAtTimestamp, err = argInfo.ConvertToInt()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return SendLedisFunc(func(ledisConn redis.Conn) (Slot, error) {
+		var err error
+		var repliesCount int
+
+		if commandInfo.ABSTTLSet {
+			repliesCount++
+			err = ledisConn.Send("RESTORE", args[0], 0, serialised)
+			if err != nil {
+				return Slot{}, err
+			}
+
+			if setExpireAt {
+				repliesCount++
+				err = ledisConn.Send("EXPIREAT", args[0], expireAtTimestamp)
+				if err != nil {
+					return Slot{}, err
+				}
+			}
+		} else {
+			repliesCount++
+			err = ledisConn.Send("RESTORE", args[0], args[1], serialised)
+			if err != nil {
+				return Slot{}, err
+			}
+		}
+
+		return Slot{
+			RepliesCount: repliesCount,
+			ProcessFunc: func(replies []interface{}) (interface{}, error) {
+				return replies[0], nil
+			},
+		}, nil
+	}), nil
+}
+
+type restoreCommandInfo struct {
+	REPLACESet  bool
+	ABSTTLSet   bool
+	IDLETIMESet bool
+	IDLETIME    int64
+	FREQSet     bool
+	FREQ        int64
+}
+
+func parseRestoreCommand(args []interface{}) (info restoreCommandInfo, err error) {
+	if len(args) < 3 {
+		err = ErrInvalidSyntax
+		return
+	}
+
+	for i := 1; i < len(args); i++ {
+		argInfo := rewledisArgs.Parse(args[i])
+
+		if !argInfo.IsStringLike() {
+			err = ErrInvalidArgumentType
+			return
+		}
+
+		if argInfo.EqualFoldEither(stringREPLACE, bytesREPLACE) {
+			info.REPLACESet = true
+		} else if argInfo.EqualFoldEither(stringABSTTL, bytesABSTTL) {
+			info.ABSTTLSet = true
+		} else if argInfo.EqualFoldEither(stringIDLETIME, bytesIDLETIME) {
+			if i+1 >= len(args) {
+				err = ErrInvalidSyntax
+				return
+			}
+
+			i++
+			valueInfo := rewledisArgs.Parse(args[i])
+			info.IDLETIME, err = valueInfo.ConvertToInt()
+			if err != nil {
+				return
+			}
+			info.IDLETIMESet = true
+		} else if argInfo.EqualFoldEither(stringFREQ, bytesFREQ) {
+			if i+1 >= len(args) {
+				err = ErrInvalidSyntax
+				return
+			}
+
+			i++
+			valueInfo := rewledisArgs.Parse(args[i])
+			info.FREQ, err = valueInfo.ConvertToInt()
+			if err != nil {
+				return
+			}
+			info.IDLETIMESet = true
+		} else {
+			err = ErrInvalidSyntax
+			return
+		}
+	}
+
+	return
+}
+
+// Type-specific LedisDB commands used by MoveCommandTransformer.
+var (
+	moveDumpCommands = TypeSpecificCommands{
+		KV:   "DUMP",
+		List: "LDUMP",
+		Hash: "HDUMP",
+		Set:  "SDUMP",
+		ZSet: "ZDUMP",
+	}
+	moveTTLCommands = TypeSpecificCommands{
+		KV:   "TTL",
+		List: "LTTL",
+		Hash: "HTTL",
+		Set:  "STTL",
+		ZSet: "ZTTL",
+	}
+	moveClearCommands = TypeSpecificCommands{
+		KV:   "DEL",
+		List: "LCLEAR",
+		Hash: "HCLEAR",
+		Set:  "SCLEAR",
+		ZSet: "ZCLEAR",
+	}
+)
+
+// errSameObject is the error reply returned by Redis for MOVE when the
+// source and destination databases are the same.
+var errSameObject = redis.Error("ERR source and destination objects are the same")
+
+// errNotPipelinable is the error reply returned for commands emulated on
+// internal connections while the transformation takes place, if they are
+// issued on a LedisConn with pending replies. See hasPendingReplies.
+var errNotPipelinable = redis.Error("ERR command cannot be pipelined, issue it using Do without pending replies")
+
+// hasPendingReplies returns true if rewriter is scoped to a LedisConn with
+// commands whose replies have not been received yet. Such commands may not
+// have been executed by LedisDB yet. Emulations executing commands on
+// internal connections during the transformation would observe the state
+// before these commands and must not be used.
+func hasPendingReplies(rewriter *Rewriter) bool {
+	return rewriter.conn != nil && rewriter.conn.slots.Len() > 0
+}
+
+// MoveCommandTransformer performs transformations for the MOVE Redis
+// command.
+//
+// LedisDB does not implement MOVE. The key is serialised in the current
+// database using the type-specific DUMP command and restored in the
+// destination database using RESTORE, both on connections of the internal
+// sub pool. Afterwards, the key is deleted from the current database. The
+// remaining time to live of the key is carried over with a precision of
+// seconds.
+//
+// In contrast to Redis, the emulation is not atomic. Modifications of the key
+// taking place while the command is processed may be lost and the key may
+// exist in both databases for a short while.
+//
+// As DUMP and RESTORE are executed during the transformation, MOVE cannot be
+// pipelined. If MOVE is issued on a LedisConn with pending replies, i.e.
+// after commands have been sent using Send but their replies have not been
+// received, an error reply is returned without moving the key.
+func MoveCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	if len(args) != 2 {
+		return nil, ErrInvalidSyntax
+	}
+
+	if hasPendingReplies(rewriter) {
+		return replySendLedisFunc(errNotPipelinable), nil
+	}
+
+	argInfo := rewledisArgs.Parse(args[1])
+	db, err := argInfo.ConvertToInt()
+	if err != nil {
+		return nil, err
+	}
+	if int(db) == rewriter.db {
+		return replySendLedisFunc(errSameObject), nil
+	}
+
+	keyType, err := resolveKeyType(rewriter, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if keyType == LedisTypeNone {
+		return replySendLedisFunc(int64(0)), nil
+	}
+
+	destinationRewriter := rewriter.forDB(int(db))
+	destinationKeyType, err := resolveKeyType(destinationRewriter, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if destinationKeyType != LedisTypeNone {
+		return replySendLedisFunc(int64(0)), nil
+	}
+
+	serialised, ttl, err := dumpKey(rewriter, keyType, args[0])
+	if err != nil {
+		if redisErr, ok := err.(redis.Error); ok {
+			return replySendLedisFunc(redisErr), nil
+		}
+		return nil, err
+	}
+	if serialised == nil {
+		// The key has been deleted in the meantime.
+		return replySendLedisFunc(int64(0)), nil
+	}
+	if ttl < 0 {
+		ttl = 0
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	conn, err := destinationRewriter.getInternalConn(ctx)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	_, err = conn.Do("RESTORE", args[0], ttl, serialised)
+	conn.Close()
+	if err != nil {
+		if redisErr, ok := err.(redis.Error); ok {
+			return replySendLedisFunc(redisErr), nil
+		}
+		return nil, err
+	}
+
+	key := rewledisArgs.AsSimpleString(args[0])
+	destinationRewriter.trySetCacheEntry(key, CacheEntryStateExists, keyType)
+
+	clearCommand := moveClearCommands.ForType(keyType)
+
+	return SendLedisFunc(func(ledisConn redis.Conn) (Slot, error) {
+		err := ledisConn.Send(clearCommand, args[0])
+		if err != nil {
+			return Slot{}, err
+		}
+
+		return Slot{
+			RepliesCount: 1,
+			ProcessFunc: func(replies []interface{}) (interface{}, error) {
+				if err, ok := replies[0].(redis.Error); ok {
+					return err, nil
+				}
+
+				rewriter.trySetCacheEntry(key, CacheEntryStateDeleted, LedisTypeNone)
+
+				return int64(1), nil
+			},
+		}, nil
+	}), nil
+}
+
+// dumpKey serialises the key keyArg of type keyType and retrieves its
+// remaining time to live in seconds. A connection of the internal sub pool
+// of rewriter is used. The returned serialised value is nil if the key does
+// not exist.
+func dumpKey(rewriter *Rewriter, keyType LedisType, keyArg interface{}) ([]byte, int64, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	conn, err := rewriter.getInternalConn(ctx)
+	cancel()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer conn.Close()
+
+	err = conn.Send(moveDumpCommands.ForType(keyType), keyArg)
+	if err != nil {
+		return nil, 0, err
+	}
+	err = conn.Send(moveTTLCommands.ForType(keyType), keyArg)
+	if err != nil {
+		return nil, 0, err
+	}
+	err = conn.Flush()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	serialised, err := redis.Bytes(conn.Receive())
+	if err == redis.ErrNil {
+		serialised = nil
+	} else if err != nil {
+		return nil, 0, err
+	}
+	ttl, err := redis.Int64(conn.Receive())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return serialised, ttl, nil
+}
+
+// PingCommandTransformer performs transformations for the PING Redis command.
+func PingCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	if len(args) == 0 {
+		return noneTransformerInstance(rewriter, command, args)
+	} else if len(args) == 1 {
+		argInfo := rewledisArgs.Parse(args[0])
+
+		message, err := argInfo.ConvertToRedisString()
+		if err != nil {
+			return nil, err
+		}
+
+		return SendLedisFunc(func(ledisConn redis.Conn) (Slot, error) {
+			err := ledisConn.Send(command.Name)
+			if err != nil {
+				return Slot{}, err
+			}
+
+			return Slot{
+				RepliesCount: 1,
+				ProcessFunc: func(_ []interface{}) (interface{}, error) {
+					return message, nil
+				},
+			}, nil
+		}), nil
+	} else {
+		return nil, ErrInvalidSyntax
+	}
+}
+
+// LolwutCommandTransformer performs transformations for the LOLWUT Redis
+// command.
+//
+// Instead of computer art, the reply contains Version and the server time
+// reported by LedisDB's TIME command. LOLWUT may thus be used as a health
+// check verifying the connectivity to LedisDB. The VERSION option is accepted
+// but ignored.
+func LolwutCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	if len(args) != 0 {
+		if len(args) != 2 {
+			return nil, ErrInvalidSyntax
+		}
+
+		argInfo := rewledisArgs.Parse(args[0])
+		if !argInfo.EqualFoldEither(stringVERSION, bytesVERSION) {
+			return nil, ErrInvalidSyntax
+		}
+	}
+
+	return SendLedisFunc(func(ledisConn redis.Conn) (Slot, error) {
+		err := ledisConn.Send("TIME")
+		if err != nil {
+			return Slot{}, err
+		}
+
+		return Slot{
+			RepliesCount: 1,
+			ProcessFunc: func(replies []interface{}) (interface{}, error) {
+				if err, ok := replies[0].(redis.Error); ok {
+					return err, nil
+				}
+
+				serverTime, err := redis.Strings(replies[0], nil)
+				if err != nil {
+					return nil, err
+				}
+				if len(serverTime) != 2 {
+					return nil, replyutil.ErrUnexpectedReplyFormat
+				}
+
+				return fmt.Sprintf(
+					"%s, LedisDB server time: %s.%s\n",
+					Version, serverTime[0], serverTime[1],
+				), nil
+			},
+		}, nil
+	}), nil
+}
+
+// CommandCommandTransformer performs transformations for the COMMAND Redis
+// command. Only COMMAND without arguments and the INFO sub-command are
+// supported, both are answered by rewledis without contacting LedisDB.
+//
+// Unlike Redis, the reply for each command is an array containing the
+// command's name, syntax and time complexity (as emulated by rewledis).
+// COMMAND without arguments replies with the information of all commands
+// known to the rewriter. Unknown command names passed to COMMAND INFO yield
+// nil.
+func CommandCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	if len(args) < 1 {
+		knownCommands := rewriter.knownCommands()
+
+		infos := make([]interface{}, 0, len(knownCommands))
+		for _, redisCommand := range knownCommands {
+			infos = append(infos, commandInfoReply(redisCommand))
+		}
+
+		return replySendLedisFunc(infos), nil
+	}
+
+	argInfo := rewledisArgs.Parse(args[0])
+	if !argInfo.IsStringLike() {
+		return nil, ErrInvalidArgumentType
+	}
+
+	if !argInfo.EqualFoldEither(stringINFO, bytesINFO) {
+		return nil, ErrSubCommandNotImplemented
+	}
+
+	infos := make([]interface{}, 0, len(args)-1)
+	for _, arg := range args[1:] {
+		name, err := redis.String(arg, nil)
+		if err != nil {
+			return nil, ErrInvalidArgumentType
+		}
+
+		redisCommand, err := rewriter.lookupCommand(name)
+		if err != nil {
+			infos = append(infos, nil)
+			continue
+		}
+
+		infos = append(infos, commandInfoReply(redisCommand))
+	}
+
+	return replySendLedisFunc(infos), nil
+}
+
+// commandInfoReply returns the information about redisCommand replied by
+// COMMAND, see CommandCommandTransformer.
+func commandInfoReply(redisCommand *RedisCommand) []interface{} {
+	return []interface{}{
+		strings.ToLower(redisCommand.Name),
+		redisCommand.Syntax,
+		redisCommand.Complexity,
+	}
+}
+
+// SwapdbCommandTransformer performs transformations for the SWAPDB Redis
+// command. The command is forwarded to LedisDB. As the key spaces of both
+// databases change, the entire cache is cleared once the command succeeds.
+func SwapdbCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	if len(args) != 2 {
+		return nil, ErrInvalidSyntax
+	}
+
+	sendLedisFunc, err := noneTransformerInstance(rewriter, command, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return chainProcessFunc(sendLedisFunc, func(reply interface{}) (interface{}, error) {
+		if _, ok := reply.(redis.Error); ok {
+			return reply, nil
+		}
+
+		rewriter.root().typeCache().Clear()
+
+		return reply, nil
+	}), nil
+}
+
+// ClusterCommandTransformer performs transformations for the CLUSTER Redis
+// command. LedisDB does not support clustering, the sub-commands are
+// emulated by rewledis.
+//
+//     Implemented:
+//       CLUSTER KEYSLOT key
+//     Not implemented:
+//       All other sub-commands
+//
+// CLUSTER KEYSLOT returns the hash slot of key as computed by Redis Cluster,
+// see clusterKeySlot. This is useful for inspecting the distribution of keys
+// even though LedisDB is not clustered.
+func ClusterCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	if len(args) < 1 {
+		return nil, ErrInvalidSyntax
+	}
+
+	argInfo := rewledisArgs.Parse(args[0])
+	if !argInfo.IsStringLike() {
+		return nil, ErrInvalidArgumentType
+	}
+
+	if !argInfo.EqualFoldEither(stringKEYSLOT, bytesKEYSLOT) {
+		return nil, ErrSubCommandNotImplemented
+	}
+
+	if len(args) != 2 {
+		return nil, ErrInvalidSyntax
+	}
+
+	keyInfo := rewledisArgs.Parse(args[1])
+	key, err := keyInfo.ConvertToRedisBytesString()
+	if err != nil {
+		return nil, err
+	}
+
+	return replySendLedisFunc(int64(clusterKeySlot(key))), nil
+}
+
+// syntheticClientListEntryFormat is the format of the entry reported by
+// CLIENT LIST for the connection issuing the command. The verbs are replaced
+// by the flags, the selected database and the number of channel and pattern
+// subscriptions.
+const syntheticClientListEntryFormat = "id=1 addr=127.0.0.1:0 fd=5 name= age=0 idle=0 flags=%s db=%d sub=%d psub=%d multi=-1 qbuf=0 qbuf-free=0 obl=0 oll=0 omem=0 events=r cmd=client\n"
+
+// syntheticClientListEntry formats the CLIENT LIST entry for the connection
+// rewriter is scoped to. pubSub is true if the connection is subscribed to
+// any channel or pattern, i.e. CLIENT LIST has been issued in subscription
+// mode.
+func syntheticClientListEntry(rewriter *Rewriter) (entry string, pubSub bool) {
+	var subscriptions, patternSubscriptions int
+	if rewriter.conn != nil {
+		subscriptions, patternSubscriptions = rewriter.conn.Subscriptions()
+	}
+
+	flags := "N"
+	pubSub = subscriptions > 0 || patternSubscriptions > 0
+	if pubSub {
+		flags = "P"
+	}
+
+	entry = fmt.Sprintf(syntheticClientListEntryFormat, flags, rewriter.db, subscriptions, patternSubscriptions)
+	return
+}
+
+// ClientCommandTransformer performs transformations for the CLIENT Redis
+// command.
+//
+// CLIENT is not implemented in LedisDB. Sub-commands are emulated without
+// contacting LedisDB. Issuing a not supported sub-command results in a
+// ErrSubCommandNotImplemented error.
+//
+//     Implemented:
+//       CLIENT KILL ip:port
+//       CLIENT KILL filter value [filter value ...]
+//       CLIENT LIST [TYPE normal|master|replica|pubsub]
+//       CLIENT NO-EVICT ON|OFF
+//       CLIENT PAUSE timeout [WRITE|ALL]
+//     Not implemented:
+//       All other sub-commands
+func ClientCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	if len(args) < 1 {
+		return nil, ErrInvalidSyntax
+	}
+
+	argInfo := rewledisArgs.Parse(args[0])
+	if !argInfo.IsStringLike() {
+		return nil, ErrInvalidArgumentType
+	}
+
+	if argInfo.EqualFoldEither(stringKILL, bytesKILL) {
+		return clientKillTransformer(rewriter, command, args)
+	} else if argInfo.EqualFoldEither(stringLIST, bytesLIST) {
+		return clientListTransformer(rewriter, command, args)
+	} else if argInfo.EqualFoldEither(stringNOEVICT, bytesNOEVICT) {
+		return clientNoEvictTransformer(rewriter, command, args)
+	} else if argInfo.EqualFoldEither(stringPAUSE, bytesPAUSE) {
+		return clientPauseTransformer(rewriter, command, args)
+	} else {
+		return nil, ErrSubCommandNotImplemented
+	}
+}
+
+// clientKillTransformer emulates the CLIENT KILL sub-command. LedisDB
+// connections cannot be killed through rewledis, a warning is logged using
+// the rewriter's logger and the command is acknowledged without any effect.
+// The legacy ip:port form replies with "OK", the filter form replies with 0
+// killed clients.
+func clientKillTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	if len(args) < 2 {
+		return nil, ErrInvalidSyntax
+	}
+
+	if len(args) > 2 && (len(args)-1)%2 != 0 {
+		return nil, ErrInvalidSyntax
+	}
+
+	rewriter.log("rewledis: CLIENT KILL has no effect, connections cannot be killed through rewledis")
+
+	if len(args) == 2 {
+		return okReplySendLedisFunc(), nil
+	}
+
+	return SendLedisFunc(func(_ redis.Conn) (Slot, error) {
+		return Slot{
+			RepliesCount: 0,
+			ProcessFunc: func(_ []interface{}) (interface{}, error) {
+				return int64(0), nil
+			},
+		}, nil
+	}), nil
+}
+
+// clientListTransformer emulates the CLIENT LIST sub-command. The reply
+// contains a single synthetic entry describing the current connection. Apart
+// from the selected database and the subscription counts, connection
+// properties are not tracked, the entry consists of fixed values. CLIENT
+// LIST may be issued in subscription mode, the entry is then of type pubsub.
+func clientListTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	entry, pubSub := syntheticClientListEntry(rewriter)
+
+	if len(args) == 3 {
+		argInfo := rewledisArgs.Parse(args[1])
+		if !argInfo.IsStringLike() {
+			return nil, ErrInvalidArgumentType
+		}
+		if !argInfo.EqualFoldEither(stringTYPE, bytesTYPE) {
+			return nil, ErrInvalidSyntax
+		}
+
+		typeInfo := rewledisArgs.Parse(args[2])
+		if !typeInfo.IsStringLike() {
+			return nil, ErrInvalidArgumentType
+		}
+		if pubSub {
+			if !typeInfo.EqualFoldEither(stringPUBSUB, bytesPUBSUB) {
+				entry = ""
+			}
+		} else if !typeInfo.EqualFoldEither(stringNORMAL, bytesNORMAL) {
+			entry = ""
+		}
+	} else if len(args) != 1 {
+		return nil, ErrInvalidSyntax
+	}
+
+	return SendLedisFunc(func(_ redis.Conn) (Slot, error) {
+		return Slot{
+			RepliesCount: 0,
+			ProcessFunc: func(_ []interface{}) (interface{}, error) {
+				return []byte(entry), nil
+			},
+		}, nil
+	}), nil
+}
+
+// clientNoEvictTransformer emulates the CLIENT NO-EVICT sub-command.
+// LedisDB does not evict keys, the command is acknowledged without any
+// effect.
+func clientNoEvictTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	if len(args) != 2 {
+		return nil, ErrInvalidSyntax
+	}
+
+	argInfo := rewledisArgs.Parse(args[1])
+	if !argInfo.IsStringLike() {
+		return nil, ErrInvalidArgumentType
+	}
+	if !argInfo.EqualFoldEither(stringON, bytesON) && !argInfo.EqualFoldEither(stringOFF, bytesOFF) {
+		return nil, ErrInvalidSyntax
+	}
+
+	return okReplySendLedisFunc(), nil
+}
+
+// clientPauseTransformer emulates the CLIENT PAUSE sub-command. The
+// arguments are validated and the command is acknowledged. Pausing is not
+// enforced, clients continue to be served by LedisDB.
+func clientPauseTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	if len(args) != 2 && len(args) != 3 {
+		return nil, ErrInvalidSyntax
+	}
+
+	timeoutInfo := rewledisArgs.Parse(args[1])
+	timeout, err := timeoutInfo.ConvertToInt()
+	if err != nil {
+		return nil, err
+	}
+	if timeout < 0 {
+		return nil, ErrInvalidSyntax
+	}
+
+	if len(args) == 3 {
+		argInfo := rewledisArgs.Parse(args[2])
+		if !argInfo.IsStringLike() {
+			return nil, ErrInvalidArgumentType
+		}
+		if !argInfo.EqualFoldEither(stringWRITE, bytesWRITE) && !argInfo.EqualFoldEither(stringALL, bytesALL) {
+			return nil, ErrInvalidSyntax
+		}
+	}
+
+	return okReplySendLedisFunc(), nil
+}
+
+// DebugCommandTransformer performs transformations for the DEBUG Redis
+// command.
+//
+// DEBUG is not implemented in LedisDB. Sub-commands which only tune Redis
+// internals are acknowledged without contacting LedisDB. Issuing a not
+// supported sub-command results in a ErrSubCommandNotImplemented error.
+//
+//     Implemented:
+//       DEBUG QUICKLIST-PACKED-THRESHOLD bytes
+//     Not implemented:
+//       All other sub-commands
+func DebugCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	if len(args) < 1 {
+		return nil, ErrInvalidSyntax
+	}
+
+	argInfo := rewledisArgs.Parse(args[0])
+	if !argInfo.IsStringLike() {
+		return nil, ErrInvalidArgumentType
+	}
+
+	if argInfo.EqualFoldEither(stringQUICKLISTPACKEDTHRESHOLD, bytesQUICKLISTPACKEDTHRESHOLD) {
+		if len(args) != 2 {
+			return nil, ErrInvalidSyntax
+		}
+
+		return okReplySendLedisFunc(), nil
+	} else {
+		return nil, ErrSubCommandNotImplemented
+	}
+}
+
+// replySendLedisFunc returns a SendLedisFunc which does not send any command
+// and yields reply.
+func replySendLedisFunc(reply interface{}) SendLedisFunc {
+	return SendLedisFunc(func(_ redis.Conn) (Slot, error) {
+		return Slot{
+			RepliesCount: 0,
+			ProcessFunc: func(_ []interface{}) (interface{}, error) {
+				return reply, nil
+			},
+		}, nil
+	})
+}
+
+// okReplySendLedisFunc returns a SendLedisFunc which does not send any
+// command and produces an "OK" status reply.
+func okReplySendLedisFunc() SendLedisFunc {
+	return SendLedisFunc(func(_ redis.Conn) (Slot, error) {
+		return Slot{
+			RepliesCount: 0,
+			ProcessFunc: func(_ []interface{}) (interface{}, error) {
+				return "OK", nil
+			},
+		}, nil
+	})
+}
+
+// errNoReplicas is the error reply returned by Redis for FAILOVER when no
+// replicas are connected.
+var errNoReplicas = redis.Error("ERR This instance has no replicas")
+
+// FailoverCommandTransformer performs transformations for the FAILOVER Redis
+// command.
+//
+// Failover cannot be emulated on LedisDB. Instead of failing with
+// ErrNoEmulationPossible, which renders the connection unusable, the error
+// reply of a Redis instance without replicas is returned. LedisDB is not
+// contacted.
+func FailoverCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	return SendLedisFunc(func(_ redis.Conn) (Slot, error) {
+		return Slot{
+			RepliesCount: 0,
+			ProcessFunc: func(_ []interface{}) (interface{}, error) {
+				return errNoReplicas, nil
+			},
+		}, nil
+	}), nil
+}
+
+// InfoCommandTransformer performs transformations for the INFO Redis
+// command.
+//
+// LedisDB does not report a keyspace section. When the keyspace section is
+// requested, explicitly or through INFO without arguments, "default", "all"
+// or "everything", it is synthesised by issuing XDBSIZE for each LedisDB
+// type and summing up the key counts into a single entry for the database
+// currently selected. The other sections are requested from LedisDB. As
+// LedisDB accepts at most one section, INFO is issued once per section if
+// multiple sections are requested. The sections are concatenated in the
+// order of the replies, followed by the keyspace section.
+func InfoCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	var sections []interface{}
+	keyspace := len(args) == 0
+	allSections := len(args) == 0
+
+	for _, arg := range args {
+		argInfo := rewledisArgs.Parse(arg)
+		if !argInfo.IsStringLike() {
+			return nil, ErrInvalidArgumentType
+		}
+
+		if argInfo.EqualFoldEither(stringKEYSPACE, bytesKEYSPACE) {
+			keyspace = true
+		} else if argInfo.EqualFoldEither(stringDEFAULT, bytesDEFAULT) ||
+			argInfo.EqualFoldEither(stringALL, bytesALL) ||
+			argInfo.EqualFoldEither(stringEVERYTHING, bytesEVERYTHING) {
+			keyspace = true
+			allSections = true
+		} else {
+			sections = append(sections, arg)
+		}
+	}
+
+	if !keyspace && len(sections) == 1 {
+		return noneTransformerInstance(rewriter, command, args)
+	}
+
+	db := rewriter.db
+
+	return SendLedisFunc(func(ledisConn redis.Conn) (Slot, error) {
+		infoCount := 0
+
+		if allSections {
+			err := ledisConn.Send("INFO")
+			if err != nil {
+				return Slot{}, err
+			}
+			infoCount = 1
+		} else {
+			for _, section := range sections {
+				err := ledisConn.Send("INFO", section)
+				if err != nil {
+					return Slot{}, err
+				}
+			}
+			infoCount = len(sections)
+		}
+
+		keyspaceCount := 0
+		if keyspace {
+			for _, ledisTypeName := range scanLedisTypes {
+				err := ledisConn.Send("XDBSIZE", ledisTypeName)
+				if err != nil {
+					return Slot{}, err
+				}
+			}
+			keyspaceCount = len(scanLedisTypes)
+		}
+
+		return Slot{
+			RepliesCount: infoCount + keyspaceCount,
+			ProcessFunc: func(replies []interface{}) (interface{}, error) {
+				for _, reply := range replies {
+					if err, ok := reply.(redis.Error); ok {
+						return err, nil
+					}
+				}
+
+				var info strings.Builder
+
+				for _, reply := range replies[:infoCount] {
+					section, err := redis.String(reply, nil)
+					if err != nil {
+						return nil, err
+					}
+
+					section = strings.TrimRight(section, "\r\n")
+					if len(section) > 0 {
+						info.WriteString(section)
+						info.WriteString("\r\n\r\n")
+					}
+				}
+
+				if !keyspace {
+					return strings.TrimSuffix(info.String(), "\r\n"), nil
+				}
+
+				var keysCount int64
+				for _, reply := range replies[infoCount:] {
+					count, err := redis.Int64(reply, nil)
+					if err != nil {
+						return nil, err
+					}
+					keysCount += count
+				}
+
+				fmt.Fprintf(&info, "# Keyspace\r\ndb%d:keys=%d,expires=0,avg_ttl=0\r\n", db, keysCount)
+
+				return info.String(), nil
+			},
+		}, nil
+	}), nil
+}
+
+// HashZiplistMaxFields is the default maximum number of fields of a hash
+// reported with the "ziplist" encoding by OBJECT ENCODING. This mirrors the
+// default hash-max-ziplist-entries setting of Redis.
+const HashZiplistMaxFields = 128
+
+// IntsetMaxEntries is the default maximum number of members of a set
+// reported with the "intset" encoding by OBJECT ENCODING. This mirrors the
+// default set-max-intset-entries setting of Redis.
+const IntsetMaxEntries = 512
+
+// StringEmbstrMaxLength is the default maximum length of a string reported
+// with the "embstr" encoding by OBJECT ENCODING. This mirrors the limit
+// hard-coded in Redis.
+const StringEmbstrMaxLength = 44
+
+// ListListpackMaxEntries is the default maximum number of elements of a list
+// reported with the "listpack" encoding by OBJECT ENCODING.
+const ListListpackMaxEntries = 128
+
+// ListListpackMaxValueLength is the default maximum length of the sampled
+// element of a list reported with the "listpack" encoding by OBJECT
+// ENCODING.
+const ListListpackMaxValueLength = 64
+
+// ZSetZiplistMaxMembers is the default maximum number of members of a sorted
+// set reported with the "ziplist" encoding by OBJECT ENCODING. This mirrors
+// the default zset-max-ziplist-entries setting of Redis.
+const ZSetZiplistMaxMembers = 128
+
+// ObjectEncodingConfig contains the thresholds used for emulating the
+// OBJECT ENCODING sub-command. LedisDB does not use Redis' encodings, the
+// encoding reported is derived from the size of the value.
+type ObjectEncodingConfig struct {
+	// StringEmbstrMaxLength is the maximum length of a string not
+	// representing an integer for which "embstr" is reported. "raw" is
+	// reported for longer strings.
+	StringEmbstrMaxLength int64
+	// HashZiplistMaxFields is the maximum number of fields of a hash for
+	// which "ziplist" is reported. "hashtable" is reported for larger hashes.
+	HashZiplistMaxFields int64
+	// IntsetMaxEntries is the maximum number of members of a set consisting
+	// only of integers for which "intset" is reported. "hashtable" is
+	// reported for all other sets.
+	IntsetMaxEntries int64
+	// ListListpackMaxEntries is the maximum number of elements of a list for
+	// which "listpack" is reported. "quicklist" is reported for longer lists.
+	ListListpackMaxEntries int64
+	// ListListpackMaxValueLength is the maximum length of the first element
+	// of a list for which "listpack" is reported. "quicklist" is reported if
+	// the first element is longer.
+	ListListpackMaxValueLength int64
+	// ZSetZiplistMaxMembers is the maximum number of members of a sorted set
+	// for which "ziplist" is reported. "skiplist" is reported for larger
+	// sorted sets.
+	ZSetZiplistMaxMembers int64
+}
+
+var (
+	objectCommandTransformerInstance = ObjectTransformer(&ObjectEncodingConfig{
+		StringEmbstrMaxLength:      StringEmbstrMaxLength,
+		HashZiplistMaxFields:       HashZiplistMaxFields,
+		IntsetMaxEntries:           IntsetMaxEntries,
+		ListListpackMaxEntries:     ListListpackMaxEntries,
+		ListListpackMaxValueLength: ListListpackMaxValueLength,
+		ZSetZiplistMaxMembers:      ZSetZiplistMaxMembers,
+	})
+)
+
+// ObjectCommandTransformer performs transformations for the OBJECT Redis
+// command. It uses the default ObjectEncodingConfig, see ObjectTransformer
+// for details.
+func ObjectCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	return objectCommandTransformerInstance(rewriter, command, args)
+}
+
+// ObjectTransformer returns a TransformFunc performing transformations for
+// the OBJECT Redis command. config is used for emulating OBJECT ENCODING.
+//
+// OBJECT is not implemented in LedisDB, so all sub-commands are emulated.
+// Issuing a not supported sub-command results in a
+// ErrSubCommandNotImplemented error.
+//
+//     Implemented:
+//       OBJECT ENCODING key
+//       OBJECT FREQ key
+//       OBJECT HELP
+//       OBJECT REFCOUNT key
+//     Not implemented:
+//       OBJECT IDLETIME key
+func ObjectTransformer(config *ObjectEncodingConfig) TransformFunc {
+	return TransformFunc(
+		func(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+			if len(args) < 1 {
+				return nil, ErrInvalidSyntax
+			}
+
+			argInfo := rewledisArgs.Parse(args[0])
+			if !argInfo.IsStringLike() {
+				return nil, ErrInvalidArgumentType
+			}
+
+			if argInfo.EqualFoldEither(stringENCODING, bytesENCODING) {
+				return objectEncodingTransformer(config, rewriter, command, args)
+			} else if argInfo.EqualFoldEither(stringFREQ, bytesFREQ) {
+				return objectFreqTransformer(rewriter, command, args)
+			} else if argInfo.EqualFoldEither(stringHELP, bytesHELP) {
+				return objectHelpTransformer(rewriter, command, args)
+			} else if argInfo.EqualFoldEither(stringREFCOUNT, bytesREFCOUNT) {
+				return objectRefcountTransformer(rewriter, command, args)
+			} else {
+				return nil, ErrSubCommandNotImplemented
+			}
+		},
+	)
+}
+
+// objectEncodingTransformer emulates the OBJECT ENCODING sub-command. The
+// encoding is derived from the type and size of the value stored at key.
+func objectEncodingTransformer(
+	config *ObjectEncodingConfig,
+	rewriter *Rewriter,
+	command *RedisCommand,
+	args []interface{},
+) (SendLedisFunc, error) {
+	if len(args) != 2 {
+		return nil, ErrInvalidSyntax
+	}
+
+	keyType, err := resolveKeyType(rewriter, args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	switch keyType {
+	case LedisTypeNone:
+		return nilReplySendLedisFunc(), nil
+	case LedisTypeKV:
+		// Only the first 20 bytes are retrieved, no longer string may
+		// represent a 64 bit integer.
+		return SendLedisFunc(func(ledisConn redis.Conn) (Slot, error) {
+			err := ledisConn.Send("STRLEN", args[1])
+			if err != nil {
+				return Slot{}, err
+			}
+			err = ledisConn.Send("GETRANGE", args[1], 0, 19)
+			if err != nil {
+				return Slot{}, err
+			}
+
+			return Slot{
+				RepliesCount: 2,
+				ProcessFunc: func(replies []interface{}) (interface{}, error) {
+					for _, reply := range replies {
+						if err, ok := reply.(redis.Error); ok {
+							return err, nil
+						}
+					}
+
+					length, err := redis.Int64(replies[0], nil)
+					if err != nil {
+						return nil, err
+					}
+
+					if length <= 20 {
+						prefix, err := redis.String(replies[1], nil)
+						if err != nil {
+							return nil, err
+						}
+
+						// Redis only uses the "int" encoding for strings in
+						// the canonical integer representation.
+						value, err := strconv.ParseInt(prefix, 10, 64)
+						if err == nil && strconv.FormatInt(value, 10) == prefix {
+							return "int", nil
+						}
+					}
+
+					if length <= config.StringEmbstrMaxLength {
+						return "embstr", nil
+					}
+					return "raw", nil
+				},
+			}, nil
+		}), nil
+	case LedisTypeList:
+		// Only the first element is sampled, this is a heuristic. Redis
+		// considers the size of all elements.
+		return SendLedisFunc(func(ledisConn redis.Conn) (Slot, error) {
+			err := ledisConn.Send("LLEN", args[1])
+			if err != nil {
+				return Slot{}, err
+			}
+			err = ledisConn.Send("LRANGE", args[1], 0, 0)
+			if err != nil {
+				return Slot{}, err
+			}
+
+			return Slot{
+				RepliesCount: 2,
+				ProcessFunc: func(replies []interface{}) (interface{}, error) {
+					for _, reply := range replies {
+						if err, ok := reply.(redis.Error); ok {
+							return err, nil
+						}
+					}
+
+					length, err := redis.Int64(replies[0], nil)
+					if err != nil {
+						return nil, err
+					}
+
+					elements, err := redis.ByteSlices(replies[1], nil)
+					if err != nil {
+						return nil, err
+					}
+
+					if length > config.ListListpackMaxEntries {
+						return "quicklist", nil
+					}
+					if len(elements) > 0 && int64(len(elements[0])) > config.ListListpackMaxValueLength {
+						return "quicklist", nil
+					}
+					return "listpack", nil
+				},
+			}, nil
+		}), nil
+	case LedisTypeHash:
+		return SendLedisFunc(func(ledisConn redis.Conn) (Slot, error) {
+			err := ledisConn.Send("HLEN", args[1])
+			if err != nil {
+				return Slot{}, err
+			}
+
+			return Slot{
+				RepliesCount: 1,
+				ProcessFunc: func(replies []interface{}) (interface{}, error) {
+					if err, ok := replies[0].(redis.Error); ok {
+						return err, nil
+					}
+
+					fieldsCount, err := redis.Int64(replies[0], nil)
+					if err != nil {
+						return nil, err
+					}
+
+					if fieldsCount <= config.HashZiplistMaxFields {
+						return "ziplist", nil
+					}
+					return "hashtable", nil
+				},
+			}, nil
+		}), nil
+	case LedisTypeSet:
+		// All members are retrieved, but only up to IntsetMaxEntries members
+		// are inspected. Larger sets are reported as "hashtable" right away.
+		return SendLedisFunc(func(ledisConn redis.Conn) (Slot, error) {
+			err := ledisConn.Send("SMEMBERS", args[1])
+			if err != nil {
+				return Slot{}, err
+			}
+
+			return Slot{
+				RepliesCount: 1,
+				ProcessFunc: func(replies []interface{}) (interface{}, error) {
+					if err, ok := replies[0].(redis.Error); ok {
+						return err, nil
+					}
+
+					members, err := redis.ByteSlices(replies[0], nil)
+					if err != nil {
+						return nil, err
+					}
+
+					if int64(len(members)) > config.IntsetMaxEntries {
+						return "hashtable", nil
+					}
+					for _, member := range members {
+						if _, err := strconv.ParseInt(string(member), 10, 64); err != nil {
+							return "hashtable", nil
+						}
+					}
+					return "intset", nil
+				},
+			}, nil
+		}), nil
+	case LedisTypeZSet:
+		return SendLedisFunc(func(ledisConn redis.Conn) (Slot, error) {
+			err := ledisConn.Send("ZCARD", args[1])
 			if err != nil {
 				return Slot{}, err
 			}
-		} else {
-			var transformedArgsArray [13]interface{}
 
-			transformedArgs := append(transformedArgsArray[:0], args[0])
-			transformedArgs = append(transformedArgs, args[commandInfo.NumFlags+1:]...)
+			return Slot{
+				RepliesCount: 1,
+				ProcessFunc: func(replies []interface{}) (interface{}, error) {
+					if err, ok := replies[0].(redis.Error); ok {
+						return err, nil
+					}
 
-			err = ledisConn.Send("ZADD", transformedArgs...)
-			if err != nil {
-				return Slot{}, err
-			}
-		}
+					membersCount, err := redis.Int64(replies[0], nil)
+					if err != nil {
+						return nil, err
+					}
+
+					if membersCount <= config.ZSetZiplistMaxMembers {
+						return "ziplist", nil
+					}
+					return "skiplist", nil
+				},
+			}, nil
+		}), nil
+	default:
+		return nil, ErrNoEmulationPossible
+	}
+}
+
+// objectFreqTransformer emulates the OBJECT FREQ sub-command. LedisDB does
+// not maintain LFU counters, so 0 is returned for every existing key.
+func objectFreqTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	if len(args) != 2 {
+		return nil, ErrInvalidSyntax
+	}
+
+	keyType, err := resolveKeyType(rewriter, args[1])
+	if err != nil {
+		return nil, err
+	}
 
+	return SendLedisFunc(func(_ redis.Conn) (Slot, error) {
 		return Slot{
-			RepliesCount: 1,
-			ProcessFunc: func(replies []interface{}) (interface{}, error) {
-				return replies[0], nil
+			RepliesCount: 0,
+			ProcessFunc: func(_ []interface{}) (interface{}, error) {
+				if keyType == LedisTypeNone {
+					return errNoSuchKey, nil
+				}
+
+				return int64(0), nil
 			},
 		}, nil
 	}), nil
 }
 
-type zaddCommandInfo struct {
-	NumFlags int
-	NXSet    bool
-	XXSet    bool
-	INCRSet  bool
-	CHSet    bool
+// objectHelpLines is the reply to OBJECT HELP. The text matches the one of
+// Redis.
+var objectHelpLines = []string{
+	"OBJECT <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+	"ENCODING <key>",
+	"    Return the kind of internal representation used in order to store the value",
+	"    associated with a <key>.",
+	"FREQ <key>",
+	"    Return the access frequency index of the <key>. The returned integer is",
+	"    proportional to the logarithm of the recent access frequency of the key.",
+	"IDLETIME <key>",
+	"    Return the idle time of the <key>, that is the approximated number of",
+	"    seconds elapsed since the last access to the key.",
+	"REFCOUNT <key>",
+	"    Return the number of references of the value associated with the specified",
+	"    <key>.",
+	"HELP",
+	"    Prints this help.",
 }
 
-func parseZaddCommand(args []interface{}) (info zaddCommandInfo, err error) {
-	if len(args) < 3 {
-		err = ErrInvalidSyntax
-		return
+// objectHelpTransformer emulates the OBJECT HELP sub-command. The static
+// objectHelpLines are returned without contacting LedisDB.
+func objectHelpTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	if len(args) != 1 {
+		return nil, ErrInvalidSyntax
 	}
 
-	for i := 1; i < len(args); i++ {
-		argInfo := rewledisArgs.Parse(args[i])
-
-		if !argInfo.IsStringLike() {
-			break
-		}
+	reply := make([]interface{}, len(objectHelpLines))
+	for i, line := range objectHelpLines {
+		reply[i] = line
+	}
 
-		info.NumFlags++
+	return replySendLedisFunc(reply), nil
+}
 
-		if argInfo.EqualFoldEither(stringNX, bytesNX) {
-			info.NXSet = true
-		} else if argInfo.EqualFoldEither(stringXX, bytesXX) {
-			info.XXSet = true
-		} else if argInfo.EqualFoldEither(stringINCR, bytesINCR) {
-			info.INCRSet = true
-		} else if argInfo.EqualFoldEither(stringCH, bytesCH) {
-			info.CHSet = true
-		} else {
-			break
-		}
+// objectRefcountTransformer performs transformations for the OBJECT REFCOUNT
+// sub-command. The command is forwarded if LedisDB supports it, see
+// Rewriter.supportsObjectRefcount. Otherwise it is emulated, 1 is returned
+// for every existing key and nil for not existing keys.
+func objectRefcountTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	if len(args) != 2 {
+		return nil, ErrInvalidSyntax
 	}
 
-	if (len(args)-(info.NumFlags+1))%2 != 0 {
-		err = ErrInvalidSyntax
-		return
+	if rewriter.supportsObjectRefcount() {
+		return noneTransformerInstance(rewriter, command, args)
 	}
 
-	return
-}
-
-// RestoreCommandTransformer performs transformations for the RESTORE Redis
-// command.
-//
-// The IDLETIME and FREQ modifiers are ignored and removed when passing on the
-// command to LedisDB.
-//
-// TODO: Figure out how LedisDB performs RESTORE and incorporate into REPLACE
-// handling.
-func RestoreCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
-	commandInfo, err := parseRestoreCommand(args)
+	keyType, err := resolveKeyType(rewriter, args[1])
 	if err != nil {
 		return nil, err
 	}
 
-	// if commandInfo.REPLACESet {
-	// 	// TODO
-	// }
-
-	var setExpireAt bool
-	var expireAtTimestamp int64
-	if commandInfo.ABSTTLSet {
-		setExpireAt = true
-		argInfo := rewledisArgs.Parse(args[1])
-		expireAtTimestamp, err = argInfo.ConvertToInt()
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	return SendLedisFunc(func(ledisConn redis.Conn) (Slot, error) {
-		var err error
-		var repliesCount int
-
-		if commandInfo.ABSTTLSet {
-			repliesCount++
-			err = ledisConn.Send("RESTORE", args[0], 0, args[2])
-			if err != nil {
-				return Slot{}, err
-			}
-
-			if setExpireAt {
-				repliesCount++
-				err = ledisConn.Send("EXPIREAT", args[0], expireAtTimestamp)
-				if err != nil {
-					return Slot{}, err
+	return SendLedisFunc(func(_ redis.Conn) (Slot, error) {
+		return Slot{
+			RepliesCount: 0,
+			ProcessFunc: func(_ []interface{}) (interface{}, error) {
+				if keyType == LedisTypeNone {
+					return nil, nil
 				}
-			}
-		} else {
-			repliesCount++
-			err = ledisConn.Send("RESTORE", args[0], args[1], args[2])
-			if err != nil {
-				return Slot{}, err
-			}
-		}
 
-		return Slot{
-			RepliesCount: repliesCount,
-			ProcessFunc: func(replies []interface{}) (interface{}, error) {
-				return replies[0], nil
+				return int64(1), nil
 			},
 		}, nil
 	}), nil
 }
 
-type restoreCommandInfo struct {
-	REPLACESet  bool
-	ABSTTLSet   bool
-	IDLETIMESet bool
-	IDLETIME    int64
-	FREQSet     bool
-	FREQ        int64
-}
+// errNoSuchKey is the error reply returned by Redis when a command requires
+// an existing key.
+var errNoSuchKey = redis.Error("ERR no such key")
 
-func parseRestoreCommand(args []interface{}) (info restoreCommandInfo, err error) {
-	if len(args) < 3 {
-		err = ErrInvalidSyntax
-		return
+// resolveKeyType resolves the LedisType of the key passed as keyArg using
+// the Resolver of rewriter.
+func resolveKeyType(rewriter *Rewriter, keyArg interface{}) (LedisType, error) {
+	argInfo := rewledisArgs.Parse(keyArg)
+	key, err := argInfo.ConvertToRedisString()
+	if err != nil {
+		return LedisTypeNone, err
 	}
 
-	for i := 1; i < len(args); i++ {
-		argInfo := rewledisArgs.Parse(args[i])
-
-		if !argInfo.IsStringLike() {
-			err = ErrInvalidArgumentType
-			return
-		}
-
-		if argInfo.EqualFoldEither(stringREPLACE, bytesREPLACE) {
-			info.REPLACESet = true
-		} else if argInfo.EqualFoldEither(stringABSTTL, bytesABSTTL) {
-			info.ABSTTLSet = true
-		} else if argInfo.EqualFoldEither(stringIDLETIME, bytesIDLETIME) {
-			if i+1 >= len(args) {
-				err = ErrInvalidSyntax
-				return
-			}
-
-			i++
-			valueInfo := rewledisArgs.Parse(args[i])
-			info.IDLETIME, err = valueInfo.ConvertToInt()
-			if err != nil {
-				return
-			}
-			info.IDLETIMESet = true
-		} else if argInfo.EqualFoldEither(stringFREQ, bytesFREQ) {
-			if i+1 >= len(args) {
-				err = ErrInvalidSyntax
-				return
-			}
-
-			i++
-			valueInfo := rewledisArgs.Parse(args[i])
-			info.FREQ, err = valueInfo.ConvertToInt()
-			if err != nil {
-				return
-			}
-			info.IDLETIMESet = true
-		} else {
-			err = ErrInvalidSyntax
-			return
-		}
+	resolver := rewriter.Resolver()
+	ctx, cancel := context.WithCancel(context.Background())
+	keyType, err := resolver.ResolveOne(ctx, key)
+	cancel()
+	if err != nil {
+		return LedisTypeNone, err
 	}
 
-	return
-}
-
-// PingCommandTransformer performs transformations for the PING Redis command.
-func PingCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
-	if len(args) == 0 {
-		return noneTransformerInstance(rewriter, command, args)
-	} else if len(args) == 1 {
-		argInfo := rewledisArgs.Parse(args[0])
-
-		message, err := argInfo.ConvertToRedisString()
-		if err != nil {
-			return nil, err
-		}
-
-		return SendLedisFunc(func(ledisConn redis.Conn) (Slot, error) {
-			err := ledisConn.Send(command.Name)
-			if err != nil {
-				return Slot{}, err
-			}
-
-			return Slot{
-				RepliesCount: 1,
-				ProcessFunc: func(_ []interface{}) (interface{}, error) {
-					return message, nil
-				},
-			}, nil
-		}), nil
-	} else {
-		return nil, ErrInvalidSyntax
-	}
+	return keyType, nil
 }
 
 // TransactionTransformer performs transformations for transaction related
@@ -895,9 +5120,16 @@ func TransactionTransformer(rewriter *Rewriter, command *RedisCommand, args []in
 //       SCRIPT EXISTS sha1 [sha1 ...]
 //       SCRIPT FLUSH
 //       SCRIPT LOAD script
+//       SCRIPT KILL
 //     Not implemented:
 //       SCRIPT DEBUG YES|SYNC|NO
-//       SCRIPT KILL
+//
+// SCRIPT KILL must not be issued on the connection running the script: That
+// connection is blocked until the script terminates. A LedisConn may have
+// sent the script itself, e.g. when pipelining EVAL and SCRIPT KILL, so
+// SCRIPT KILL is issued on a new connection from the internal sub pool
+// instead. This is best-effort, whether a script can be killed depends on
+// the LedisDB server.
 func ScriptCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
 	if len(args) < 1 {
 		return nil, ErrInvalidSyntax
@@ -914,13 +5146,65 @@ func ScriptCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []
 		return noneTransformerInstance(rewriter, command, args)
 	} else if argInfo.EqualFoldEither(stringLOAD, bytesLOAD) {
 		return noneTransformerInstance(rewriter, command, args)
+	} else if argInfo.EqualFoldEither(stringKILL, bytesKILL) {
+		return scriptKillTransformer(rewriter, command, args)
 	} else {
 		return nil, ErrSubCommandNotImplemented
 	}
 }
 
+// scriptKillTransformer issues SCRIPT KILL on a connection of the internal
+// sub pool of rewriter, see ScriptCommandTransformer. The reply is returned
+// without sending any command on the LedisConn.
+func scriptKillTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
+	if len(args) != 1 {
+		return nil, ErrInvalidSyntax
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	conn, err := rewriter.getInternalConn(ctx)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	reply, err := conn.Do(command.Name, args...)
+	if replyErr, ok := err.(redis.Error); ok {
+		return replySendLedisFunc(replyErr), nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return replySendLedisFunc(reply), nil
+}
+
 // UnsafeCommandTransformer performs transformations for the UNSAFE Redis
 // command provided by rewledis.
+//
+//     UNSAFE LEDIS command [arg ...]
+//     UNSAFE SELF
+//     UNSAFE DIAGNOSTIC
+//
+// UNSAFE LEDIS sends command with its arguments to LedisDB as is and returns
+// the reply unaltered. This gives access to LedisDB specific commands, e.g.
+// for a type-preserving dump and restore of a key:
+//
+//     UNSAFE LEDIS XDUMP key
+//     UNSAFE LEDIS XRESTORE key ttl data
+//
+// Whether such a command is available depends on the LedisDB server. UNSAFE
+// LEDIS bypasses the cache of the rewriter. The types of keys created or
+// deleted this way are only picked up once their cache entries have been
+// invalidated.
+//
+// UNSAFE SELF returns the raw connection to LedisDB the command was issued
+// on. SubPool relies on this to hand out raw connections for internal
+// operations.
+//
+// UNSAFE DIAGNOSTIC returns the RewriterDiagnostic value of the rewriter, see
+// Rewriter.Diagnostic. The diagnostic is not returned by UNSAFE SELF, as
+// SubPool already uses UNSAFE SELF to obtain the raw connection.
 func UnsafeCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []interface{}) (SendLedisFunc, error) {
 	if len(args) < 1 {
 		return nil, ErrInvalidSyntax
@@ -969,6 +5253,19 @@ func UnsafeCommandTransformer(rewriter *Rewriter, command *RedisCommand, args []
 				},
 			}, nil
 		}), nil
+	} else if argInfo.EqualFoldEither(stringDIAGNOSTIC, bytesDIAGNOSTIC) {
+		if len(args) != 1 {
+			return nil, ErrInvalidSyntax
+		}
+
+		return SendLedisFunc(func(_ redis.Conn) (Slot, error) {
+			return Slot{
+				RepliesCount: 0,
+				ProcessFunc: func(_ []interface{}) (interface{}, error) {
+					return rewriter.Diagnostic(), nil
+				},
+			}, nil
+		}), nil
 	} else {
 		return nil, ErrSubCommandUnknown
 	}
@@ -0,0 +1,630 @@
+package rewledis
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// recordingConn is a redis.Conn recording all commands sent on it. Receive
+// is not supported, Do does not send any command and replies with nil.
+type recordingConn struct {
+	commands [][]interface{}
+}
+
+var _ redis.Conn = &recordingConn{}
+
+func (c *recordingConn) Close() error { return nil }
+func (c *recordingConn) Err() error   { return nil }
+func (c *recordingConn) Flush() error { return nil }
+
+func (c *recordingConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	return nil, nil
+}
+
+func (c *recordingConn) Send(commandName string, args ...interface{}) error {
+	c.commands = append(c.commands, append([]interface{}{commandName}, args...))
+	return nil
+}
+
+func (c *recordingConn) Receive() (interface{}, error) {
+	panic("recordingConn: Receive() not supported")
+}
+
+// doConn is a replyConn on which Do is handled by the function do.
+type doConn struct {
+	replyConn
+	do func(commandName string, args ...interface{}) (interface{}, error)
+}
+
+func (c *doConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	return c.do(commandName, args...)
+}
+
+// rewriteAndProcess rewrites the command using rewriter, sends it on a
+// recordingConn and passes replies to the ProcessFunc of the resulting Slot.
+// The commands sent and the processed reply are returned.
+func rewriteAndProcess(t *testing.T, rewriter *Rewriter, replies []interface{}, commandName string, args ...interface{}) ([][]interface{}, interface{}, error) {
+	t.Helper()
+
+	sendLedisFunc, err := rewriter.Rewrite(commandName, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conn := &recordingConn{}
+	slot, err := sendLedisFunc(conn)
+	if err != nil {
+		t.Fatalf("%s: sending failed: %v", commandName, err)
+	}
+	if slot.RepliesCount != len(replies) {
+		t.Fatalf("%s: RepliesCount = %d, want %d", commandName, slot.RepliesCount, len(replies))
+	}
+
+	reply, err := slot.ProcessFunc(replies)
+	return conn.commands, reply, err
+}
+
+func TestObjectFreq(t *testing.T) {
+	rewriter := &Rewriter{}
+	rewriter.SetCacheNegativeTTL(time.Minute)
+
+	rewriter.trySetCacheEntry("kv", CacheEntryStateExists, LedisTypeKV)
+	rewriter.trySetCacheEntry("list", CacheEntryStateExists, LedisTypeList)
+	rewriter.trySetCacheEntry("hash", CacheEntryStateExists, LedisTypeHash)
+	rewriter.trySetCacheEntry("set", CacheEntryStateExists, LedisTypeSet)
+	rewriter.trySetCacheEntry("zset", CacheEntryStateExists, LedisTypeZSet)
+	rewriter.trySetCacheEntry("missing", CacheEntryStateDeleted, LedisTypeNone)
+
+	tests := []struct {
+		key   interface{}
+		reply interface{}
+	}{
+		{"kv", int64(0)},
+		{"list", int64(0)},
+		{"hash", int64(0)},
+		{"set", int64(0)},
+		{[]byte("zset"), int64(0)},
+		{"missing", errNoSuchKey},
+	}
+
+	for _, test := range tests {
+		commands, reply, err := rewriteAndProcess(t, rewriter, nil, "OBJECT", "FREQ", test.key)
+		if err != nil {
+			t.Errorf("OBJECT FREQ %s: unexpected error: %v", test.key, err)
+			continue
+		}
+		if len(commands) != 0 {
+			t.Errorf("OBJECT FREQ %s: sent %v, want no commands", test.key, commands)
+		}
+		if !reflect.DeepEqual(reply, test.reply) {
+			t.Errorf("OBJECT FREQ %s = %#v, want %#v", test.key, reply, test.reply)
+		}
+	}
+
+	_, _, err := rewriteAndProcess(t, rewriter, nil, "OBJECT", "FREQ")
+	if err != ErrInvalidSyntax {
+		t.Errorf("OBJECT FREQ without key: err = %v, want %v", err, ErrInvalidSyntax)
+	}
+}
+
+func TestKeyScanCursors(t *testing.T) {
+	// pages maps the cursors accepted by the emulated LedisDB to the next
+	// cursor and the elements returned.
+	pages := map[string]struct {
+		next     string
+		elements []interface{}
+	}{
+		// An element "0" is the cursor of the next page.
+		"":  {"0", []interface{}{[]byte("a"), []byte("0")}},
+		"0": {"d", []interface{}{[]byte("c"), []byte("d")}},
+		"d": {"", []interface{}{[]byte("e")}},
+	}
+
+	tests := []struct {
+		command string
+		cursor  interface{}
+	}{
+		{"SSCAN", 0},
+		{"SSCAN", int64(0)},
+		{"SSCAN", "0"},
+		{"SSCAN", []byte("0")},
+		{"ZSCAN", 0},
+		{"ZSCAN", int64(0)},
+		{"ZSCAN", "0"},
+		{"ZSCAN", []byte("0")},
+	}
+
+	rewriter := &Rewriter{}
+
+	for _, test := range tests {
+		var elements []string
+		cursor := test.cursor
+
+		for i := 0; i < len(pages)+1; i++ {
+			sendLedisFunc, err := rewriter.Rewrite(test.command, "key", cursor, "COUNT", 2)
+			if err != nil {
+				t.Fatalf("%s %#v: unexpected error: %v", test.command, cursor, err)
+			}
+
+			conn := &recordingConn{}
+			slot, err := sendLedisFunc(conn)
+			if err != nil {
+				t.Fatalf("%s %#v: sending failed: %v", test.command, cursor, err)
+			}
+
+			sent := conn.commands[0]
+			expectedSent := []interface{}{test.command, "key", sent[2], "COUNT", 2}
+			if !reflect.DeepEqual(sent, expectedSent) {
+				t.Fatalf("%s %#v: sent %v, want %v", test.command, cursor, sent, expectedSent)
+			}
+			ledisCursor, ok := sent[2].(string)
+			if !ok {
+				t.Fatalf("%s %#v: sent cursor %#v, want string", test.command, cursor, sent[2])
+			}
+			page, ok := pages[ledisCursor]
+			if !ok {
+				t.Fatalf("%s %#v: sent unknown cursor %q", test.command, cursor, ledisCursor)
+			}
+
+			reply, err := slot.ProcessFunc([]interface{}{
+				[]interface{}{[]byte(page.next), page.elements},
+			})
+			if err != nil {
+				t.Fatalf("%s %#v: processing failed: %v", test.command, cursor, err)
+			}
+
+			scanReply, err := redis.Values(reply, nil)
+			if err != nil || len(scanReply) != 2 {
+				t.Fatalf("%s %#v: reply %v is not a SCAN reply", test.command, cursor, reply)
+			}
+			pageElements, err := redis.Strings(scanReply[1], nil)
+			if err != nil {
+				t.Fatalf("%s %#v: reply elements: %v", test.command, cursor, err)
+			}
+			elements = append(elements, pageElements...)
+
+			cursor = scanReply[0]
+			if string(scanReply[0].([]byte)) == "0" {
+				break
+			}
+		}
+
+		expected := []string{"a", "0", "c", "d", "e"}
+		if !reflect.DeepEqual(elements, expected) {
+			t.Errorf("%s starting at %#v: iterated %v, want %v", test.command, test.cursor, elements, expected)
+		}
+		if string(cursor.([]byte)) != "0" {
+			t.Errorf("%s starting at %#v: iteration did not finish", test.command, test.cursor)
+		}
+	}
+
+	for _, command := range []string{"HSCAN", "SSCAN", "ZSCAN"} {
+		_, err := rewriter.Rewrite(command, "key", "b")
+		if err != ErrInvalidScanCursor {
+			t.Errorf("%s with cursor \"b\": err = %v, want %v", command, err, ErrInvalidScanCursor)
+		}
+	}
+}
+
+func TestCommandWithoutArguments(t *testing.T) {
+	_, reply, err := rewriteAndProcess(t, &Rewriter{}, nil, "COMMAND")
+	if err != nil {
+		t.Fatalf("COMMAND: unexpected error: %v", err)
+	}
+
+	infos, ok := reply.([]interface{})
+	if !ok {
+		t.Fatalf("COMMAND = %#v, want array", reply)
+	}
+
+	names := make(map[string]bool, len(infos))
+	for _, info := range infos {
+		names[info.([]interface{})[0].(string)] = true
+	}
+
+	for _, name := range []string{"get", "getrange", "command"} {
+		if !names[name] {
+			t.Errorf("COMMAND does not list %q", name)
+		}
+	}
+	if names["substr"] {
+		t.Error("COMMAND lists the alias \"substr\"")
+	}
+}
+
+func TestUnsafeLedisDumpRestore(t *testing.T) {
+	serialised := []byte("\x00serialised")
+
+	tests := []struct {
+		args     []interface{}
+		sent     []interface{}
+		ledis    interface{}
+		expected interface{}
+	}{
+		{
+			args:     []interface{}{"LEDIS", "XDUMP", "key"},
+			sent:     []interface{}{"XDUMP", "key"},
+			ledis:    serialised,
+			expected: serialised,
+		},
+		{
+			args:     []interface{}{"LEDIS", []byte("XRESTORE"), "key", 0, serialised},
+			sent:     []interface{}{"XRESTORE", "key", 0, serialised},
+			ledis:    "OK",
+			expected: "OK",
+		},
+		{
+			args:     []interface{}{"ledis", "XDUMP", "missing"},
+			sent:     []interface{}{"XDUMP", "missing"},
+			ledis:    nil,
+			expected: nil,
+		},
+		{
+			args:     []interface{}{"LEDIS", "XRESTORE", "key", 0, "invalid"},
+			sent:     []interface{}{"XRESTORE", "key", 0, "invalid"},
+			ledis:    redis.Error("ERR invalid dump data"),
+			expected: redis.Error("ERR invalid dump data"),
+		},
+	}
+
+	rewriter := &Rewriter{}
+
+	for _, test := range tests {
+		commands, reply, err := rewriteAndProcess(t, rewriter, []interface{}{test.ledis}, "UNSAFE", test.args...)
+		if err != nil {
+			t.Errorf("UNSAFE %v: unexpected error: %v", test.args, err)
+			continue
+		}
+		if len(commands) != 1 || !reflect.DeepEqual(commands[0], test.sent) {
+			t.Errorf("UNSAFE %v: sent %v, want %v", test.args, commands, test.sent)
+		}
+		if !reflect.DeepEqual(reply, test.expected) {
+			t.Errorf("UNSAFE %v = %#v, want %#v", test.args, reply, test.expected)
+		}
+	}
+}
+
+func TestXaddFailedZaddReleasesID(t *testing.T) {
+	rewriter := &Rewriter{}
+	rewriter.streamIDs = map[lruKey]streamIDState{
+		{DB: 0, Key: "stream"}: {Last: streamID{MS: 1}},
+	}
+
+	tests := []struct {
+		id      string
+		replies []interface{}
+		reply   interface{}
+	}{
+		{"1-1", []interface{}{redis.Error("ERR failed")}, redis.Error("ERR failed")},
+		{"1-1", []interface{}{int64(1)}, []byte("1-1")},
+		{"1-1", nil, errStreamIDTooSmall},
+		{"1-*", []interface{}{int64(1)}, []byte("1-2")},
+	}
+
+	for i, test := range tests {
+		_, reply, err := rewriteAndProcess(t, rewriter, test.replies, "XADD", "stream", test.id, "field", "value")
+		if err != nil {
+			t.Fatalf("XADD #%d: unexpected error: %v", i, err)
+		}
+		if !reflect.DeepEqual(reply, test.reply) {
+			t.Errorf("XADD #%d: reply = %#v, want %#v", i, reply, test.reply)
+		}
+	}
+}
+
+func TestXaddLoadsLastIDOfSameMillisecond(t *testing.T) {
+	var members [][]byte
+	for seq := 0; seq < 12; seq++ {
+		member, err := json.Marshal(emulatedStreamEntry{
+			ID:     "123-" + strconv.Itoa(seq),
+			Fields: map[string]string{"field": "value"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		members = append(members, member)
+	}
+	// LedisDB orders members with equal scores by their bytes.
+	sort.Slice(members, func(i, j int) bool {
+		return bytes.Compare(members[i], members[j]) < 0
+	})
+
+	rewriter := &Rewriter{}
+	rewriter.internalSubPool.Pool = &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return rewriter.WrapConn(&doConn{
+				do: func(commandName string, args ...interface{}) (interface{}, error) {
+					switch commandName {
+					case "ZREVRANGE":
+						return []interface{}{members[len(members)-1], []byte("123")}, nil
+					case "ZRANGEBYSCORE":
+						reply := make([]interface{}, len(members))
+						for i := range members {
+							reply[i] = members[i]
+						}
+						return reply, nil
+					}
+					return nil, nil
+				},
+			}), nil
+		},
+	}
+
+	tests := []struct {
+		id      string
+		replies []interface{}
+		reply   interface{}
+	}{
+		{"123-11", nil, errStreamIDTooSmall},
+		{"123-*", []interface{}{int64(1)}, []byte("123-12")},
+	}
+
+	for i, test := range tests {
+		_, reply, err := rewriteAndProcess(t, rewriter, test.replies, "XADD", "stream", test.id, "field", "value")
+		if err != nil {
+			t.Fatalf("XADD #%d: unexpected error: %v", i, err)
+		}
+		if !reflect.DeepEqual(reply, test.reply) {
+			t.Errorf("XADD #%d: reply = %#v, want %#v", i, reply, test.reply)
+		}
+	}
+}
+
+func TestHrandfieldNegativeCount(t *testing.T) {
+	fields := []interface{}{[]byte("a"), []byte("b")}
+
+	tests := []struct {
+		count   interface{}
+		replies []interface{}
+		length  int
+		err     error
+	}{
+		{int64(-3), []interface{}{fields}, 3, nil},
+		{"-3", []interface{}{fields}, 3, nil},
+		{int64(-hrandfieldMaxRepeatedCount - 1), nil, 0, errHrandfieldCountOutOfRange},
+		{int64(-10000000000), nil, 0, errHrandfieldCountOutOfRange},
+		{int64(math.MinInt64), nil, 0, errHrandfieldCountOutOfRange},
+	}
+
+	rewriter := &Rewriter{}
+
+	for _, test := range tests {
+		_, reply, err := rewriteAndProcess(t, rewriter, test.replies, "HRANDFIELD", "hash", test.count)
+		if err != nil {
+			t.Errorf("HRANDFIELD hash %v: unexpected error: %v", test.count, err)
+			continue
+		}
+
+		if test.err != nil {
+			if reply != test.err {
+				t.Errorf("HRANDFIELD hash %v = %#v, want %#v", test.count, reply, test.err)
+			}
+			continue
+		}
+
+		values, ok := reply.([]interface{})
+		if !ok || len(values) != test.length {
+			t.Errorf("HRANDFIELD hash %v = %#v, want %d fields", test.count, reply, test.length)
+		}
+	}
+}
+
+func TestMpop(t *testing.T) {
+	rewriter := &Rewriter{}
+	rewriter.internalSubPool.Pool = &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return rewriter.WrapConn(&doConn{
+				// Replies to LLEN / ZCARD of the keys "a" and "b".
+				replyConn: replyConn{replies: []interface{}{int64(0), int64(3)}},
+				do: func(commandName string, args ...interface{}) (interface{}, error) {
+					// The scripts are reported as loaded.
+					return []interface{}{int64(1)}, nil
+				},
+			}), nil
+		},
+	}
+
+	tests := []struct {
+		command string
+		args    []interface{}
+		sent    []interface{}
+		replies []interface{}
+		reply   interface{}
+	}{
+		{
+			command: "LMPOP",
+			args:    []interface{}{2, "a", "b", "RIGHT", "COUNT", 2},
+			sent:    []interface{}{"EVALSHA", popCountScript.Hash(), 1, "b", "RPOP", int64(2)},
+			replies: []interface{}{[]interface{}{[]byte("x"), []byte("y")}},
+			reply:   []interface{}{"b", []interface{}{[]byte("x"), []byte("y")}},
+		},
+		{
+			command: "LMPOP",
+			args:    []interface{}{2, "a", "b", "LEFT"},
+			sent:    []interface{}{"EVALSHA", popCountScript.Hash(), 1, "b", "LPOP", int64(1)},
+			replies: []interface{}{nil},
+			reply:   nil,
+		},
+		{
+			command: "ZMPOP",
+			args:    []interface{}{2, "a", "b", "MIN", "COUNT", 2},
+			sent:    []interface{}{"EVALSHA", zpopScript.Hash(), 1, "b", "ZRANGEBYSCORE", int64(2), "-inf", "+inf"},
+			replies: []interface{}{[]interface{}{[]byte("m"), []byte("1")}},
+			reply:   []interface{}{"b", []interface{}{[]interface{}{[]byte("m"), []byte("1")}}},
+		},
+		{
+			command: "ZMPOP",
+			args:    []interface{}{2, "a", "b", "MAX"},
+			sent:    []interface{}{"EVALSHA", zpopScript.Hash(), 1, "b", "ZREVRANGEBYSCORE", int64(1), "+inf", "-inf"},
+			replies: []interface{}{redis.Error("ERR failed")},
+			reply:   redis.Error("ERR failed"),
+		},
+	}
+
+	for _, test := range tests {
+		commands, reply, err := rewriteAndProcess(t, rewriter, test.replies, test.command, test.args...)
+		if err != nil {
+			t.Errorf("%s %v: unexpected error: %v", test.command, test.args, err)
+			continue
+		}
+		if len(commands) != 1 || !reflect.DeepEqual(commands[0], test.sent) {
+			t.Errorf("%s %v: sent %v, want %v", test.command, test.args, commands, test.sent)
+		}
+		if !reflect.DeepEqual(reply, test.reply) {
+			t.Errorf("%s %v = %#v, want %#v", test.command, test.args, reply, test.reply)
+		}
+	}
+}
+
+func TestMpopWithPendingReplies(t *testing.T) {
+	conn := &LedisConn{}
+	conn.slots.PushBack(Slot{})
+	rewriter := (&Rewriter{}).forConn(conn)
+
+	for _, command := range []string{"LMPOP", "ZMPOP"} {
+		direction := "LEFT"
+		if command == "ZMPOP" {
+			direction = "MIN"
+		}
+
+		commands, reply, err := rewriteAndProcess(t, rewriter, nil, command, 1, "key", direction)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", command, err)
+			continue
+		}
+		if len(commands) != 0 {
+			t.Errorf("%s: sent %v, want nothing", command, commands)
+		}
+		if reply != errNotPipelinable {
+			t.Errorf("%s = %#v, want %#v", command, reply, errNotPipelinable)
+		}
+	}
+}
+
+func TestInfoKeyspace(t *testing.T) {
+	server := []byte("# Server\r\nledis_version:0.6\r\n")
+	clients := []byte("# Clients\r\nclients:1\r\n")
+	keyspace := "# Keyspace\r\ndb0:keys=3,expires=0,avg_ttl=0\r\n"
+
+	xdbsize := [][]interface{}{
+		{"XDBSIZE", "KV"},
+		{"XDBSIZE", "LIST"},
+		{"XDBSIZE", "HASH"},
+		{"XDBSIZE", "SET"},
+		{"XDBSIZE", "ZSET"},
+	}
+	sizes := []interface{}{int64(1), int64(2), int64(0), int64(0), int64(0)}
+
+	tests := []struct {
+		args    []interface{}
+		sent    [][]interface{}
+		replies []interface{}
+		reply   interface{}
+	}{
+		{
+			args:    nil,
+			sent:    append([][]interface{}{{"INFO"}}, xdbsize...),
+			replies: append([]interface{}{server}, sizes...),
+			reply:   string(server) + "\r\n" + keyspace,
+		},
+		{
+			args:    []interface{}{"all"},
+			sent:    append([][]interface{}{{"INFO"}}, xdbsize...),
+			replies: append([]interface{}{server}, sizes...),
+			reply:   string(server) + "\r\n" + keyspace,
+		},
+		{
+			args:    []interface{}{[]byte("EVERYTHING")},
+			sent:    append([][]interface{}{{"INFO"}}, xdbsize...),
+			replies: append([]interface{}{server}, sizes...),
+			reply:   string(server) + "\r\n" + keyspace,
+		},
+		{
+			args:    []interface{}{"default"},
+			sent:    append([][]interface{}{{"INFO"}}, xdbsize...),
+			replies: append([]interface{}{server}, sizes...),
+			reply:   string(server) + "\r\n" + keyspace,
+		},
+		{
+			args:    []interface{}{"keyspace"},
+			sent:    xdbsize,
+			replies: sizes,
+			reply:   keyspace,
+		},
+		{
+			args:    []interface{}{"server", "keyspace"},
+			sent:    append([][]interface{}{{"INFO", "server"}}, xdbsize...),
+			replies: append([]interface{}{server}, sizes...),
+			reply:   string(server) + "\r\n" + keyspace,
+		},
+		{
+			args:    []interface{}{"server", "clients"},
+			sent:    [][]interface{}{{"INFO", "server"}, {"INFO", "clients"}},
+			replies: []interface{}{server, clients},
+			reply:   string(server) + "\r\n" + string(clients),
+		},
+		{
+			args:    []interface{}{"server"},
+			sent:    [][]interface{}{{"INFO", "server"}},
+			replies: []interface{}{server},
+			reply:   server,
+		},
+		{
+			args:    []interface{}{"keyspace"},
+			sent:    xdbsize,
+			replies: []interface{}{int64(1), redis.Error("ERR failed"), int64(0), int64(0), int64(0)},
+			reply:   redis.Error("ERR failed"),
+		},
+	}
+
+	rewriter := &Rewriter{}
+
+	for _, test := range tests {
+		commands, reply, err := rewriteAndProcess(t, rewriter, test.replies, "INFO", test.args...)
+		if err != nil {
+			t.Errorf("INFO %v: unexpected error: %v", test.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(commands, test.sent) {
+			t.Errorf("INFO %v: sent %v, want %v", test.args, commands, test.sent)
+		}
+		if !reflect.DeepEqual(reply, test.reply) {
+			t.Errorf("INFO %v = %q, want %q", test.args, reply, test.reply)
+		}
+	}
+}
+
+func TestExpireOptions(t *testing.T) {
+	tests := []struct {
+		command string
+		option  interface{}
+		err     error
+	}{
+		{"EXPIRE", "NX", ErrNoEmulationPossible},
+		{"EXPIRE", "xx", ErrNoEmulationPossible},
+		{"EXPIRE", []byte("GT"), ErrNoEmulationPossible},
+		{"EXPIRE", "lt", ErrNoEmulationPossible},
+		{"EXPIRE", "FOO", ErrInvalidSyntax},
+		{"EXPIREAT", "NX", ErrNoEmulationPossible},
+		{"EXPIREAT", "gt", ErrNoEmulationPossible},
+		{"EXPIREAT", "FOO", ErrInvalidSyntax},
+	}
+
+	rewriter := &Rewriter{}
+
+	for _, test := range tests {
+		_, err := rewriter.Rewrite(test.command, "key", 10, test.option)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s key 10 %v: err = %v, want %v", test.command, test.option, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,5 @@
+package rewledis
+
+// Version is the version string of rewledis. It is reported by the LOLWUT
+// command.
+const Version = "rewledis v0.1.0"